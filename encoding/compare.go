@@ -0,0 +1,25 @@
+package encoding
+
+// CompareBytes returns the offset of the first byte the given byte slices
+// differ at, or -1 if they are equal.
+//
+// Should one of them be a prefix of the other, they differ at the offset
+// the shorter one ends at.
+func CompareBytes(a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+
+	if len(a) != len(b) {
+		return n
+	}
+
+	return -1
+}
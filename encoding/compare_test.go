@@ -0,0 +1,59 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareBytes(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		a    []byte
+		b    []byte
+		want int
+	}{
+		{name: "equal", a: []byte{0x61, 0x62, 0x63}, b: []byte{0x61, 0x62, 0x63}, want: -1},
+		{name: "both empty", a: nil, b: []byte{}, want: -1},
+		{name: "differing first byte", a: []byte{0x61, 0x62}, b: []byte{0x62, 0x62}, want: 0},
+		{name: "differing last byte", a: []byte{0x63, 0x61, 0x66, 0xE9}, b: []byte{0x63, 0x61, 0x66, 0x3F}, want: 3},
+		{name: "shorter first", a: []byte{0x61}, b: []byte{0x61, 0x62}, want: 1},
+		{name: "shorter second", a: []byte{0x61, 0x62}, b: []byte{0x61}, want: 1},
+		{name: "one empty", a: []byte{}, b: []byte{0x61}, want: 0},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, CompareBytes(tc.a, tc.b))
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoded = new TextEncoder("windows-1252").encode("caf\u00e9");
+			assert_equals(compareBytes(encoded, new Uint8Array([0x63, 0x61, 0x66, 0xe9])), -1, "equal bytes");
+			assert_equals(compareBytes(encoded, new TextEncoder().encode("caf\u00e9")), 3, "differing bytes");
+			assert_equals(compareBytes(encoded.buffer, encoded.subarray(0, 2)), 2, "different lengths");
+			assert_equals(compareBytes(new ArrayBuffer(0), new Uint8Array([])), -1, "empty buffers");
+
+			let error;
+			try {
+				compareBytes(encoded, "caf\u00e9");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "a string should throw a TypeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
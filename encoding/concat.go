@@ -0,0 +1,43 @@
+package encoding
+
+import "strings"
+
+// ConcatDecode decodes each of the given buffers independently, using the
+// encoding the given label resolves to, and returns the concatenation of the
+// decoded text.
+//
+// Unlike decoding the buffers as a stream, each buffer is considered a complete
+// unit: a sequence a buffer ends in the middle of is not completed by the bytes
+// of the next one, but substituted with a replacement character. Likewise, each
+// buffer may start with a byte order mark.
+func ConcatDecode(buffers [][]byte, label string, options concatDecodeOptions) (string, error) {
+	td, err := NewTextDecoder(nil, label, textDecoderOptions{
+		Fatal:     options.Fatal,
+		IgnoreBOM: options.IgnoreBOM,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, buffer := range buffers {
+		decoded, err := td.Decode(buffer, decodeOptions{})
+		if err != nil {
+			return "", err
+		}
+
+		text.WriteString(decoded)
+	}
+
+	return text.String(), nil
+}
+
+type concatDecodeOptions struct {
+	// Fatal holds a boolean value indicating if decoding
+	// invalid data must throw a `TypeError`.
+	Fatal bool `js:"fatal"`
+
+	// IgnoreBOM holds a boolean value indicating
+	// whether the byte order mark is ignored.
+	IgnoreBOM bool `js:"ignoreBOM"`
+}
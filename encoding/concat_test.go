@@ -0,0 +1,84 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcatDecode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		buffers [][]byte
+		label   string
+		want    string
+	}{
+		{
+			name:    "no buffers",
+			buffers: nil,
+			label:   UTF8EncodingFormat,
+			want:    "",
+		},
+		{
+			name:    "complete buffers",
+			buffers: [][]byte{{0x61, 0xE6, 0xB0, 0xB4}, {0x62}},
+			label:   UTF8EncodingFormat,
+			want:    "a\u6C34b",
+		},
+		{
+			name:    "buffer ending mid-sequence",
+			buffers: [][]byte{{0x61, 0xE6, 0xB0}, {0xB4, 0x62}},
+			label:   UTF8EncodingFormat,
+			want:    "a\uFFFD\uFFFDb",
+		},
+		{
+			name:    "byte order mark of each buffer",
+			buffers: [][]byte{{0xEF, 0xBB, 0xBF, 0x61}, {0xEF, 0xBB, 0xBF, 0x62}},
+			label:   UTF8EncodingFormat,
+			want:    "ab",
+		},
+		{
+			name:    "utf-16le buffer ending with an odd byte",
+			buffers: [][]byte{{0x61, 0x00, 0x62}, {0x63, 0x00}},
+			label:   UTF16LEEncodingFormat,
+			want:    "a\uFFFDc",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoded, err := ConcatDecode(tc.buffers, tc.label, concatDecodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+		})
+	}
+}
+
+func TestConcatDecodeJS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const frames = [new Uint8Array([0x61, 0xe6, 0xb0]), new Uint8Array([0xb4, 0x62]).buffer];
+		assert_equals(concatDecode(frames, "utf-8"), "a\uFFFD\uFFFDb", "frames should not be joined");
+		assert_equals(new TextDecoder().decodeAll(frames), "a\u6C34b", "streamed frames should be joined");
+		assert_equals(concatDecode([new Uint8Array([0xe9])], "latin1", {}), "\u00e9");
+
+		let error;
+		try {
+			concatDecode([new Uint8Array([0x61])], "not-an-encoding");
+		} catch (e) {
+			error = e;
+		}
+		assert_true(error instanceof RangeError, "an unknown label should throw a RangeError");
+	`)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,555 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// encodingEntry describes an encoding supported by the TextDecoder,
+// along with the labels it can be referred to by.
+type encodingEntry struct {
+	// name holds the canonical name of the encoding.
+	name EncodingName
+
+	// labels holds the lowercase labels resolving to the encoding,
+	// as defined by the [WHATWG Encoding Standard].
+	//
+	// [WHATWG Encoding Standard]: https://encoding.spec.whatwg.org/#names-and-labels
+	labels []string
+
+	// newEncoding returns the [encoding.Encoding] implementing the encoding.
+	//
+	// Note that byte order marks are handled by the TextDecoder, hence
+	// the returned encoding should neither strip nor interpret them.
+	newEncoding func() encoding.Encoding
+
+	// encodable indicates whether the TextEncoder supports the encoding.
+	encodable bool
+
+	// asciiCompatible indicates whether the encoding decodes each byte below
+	// 0x80 to the ASCII character of the same value, whatever its context.
+	asciiCompatible bool
+}
+
+// encodingsTable holds the encodings supported by the TextDecoder.
+//
+//nolint:gochecknoglobals
+var encodingsTable = []encodingEntry{
+	{
+		name: UTF8EncodingFormat,
+		labels: []string{
+			"unicode-1-1-utf-8",
+			"unicode11utf8",
+			"unicode20utf8",
+			"utf-8",
+			"utf8",
+			"x-unicode20utf8",
+		},
+		newEncoding:     func() encoding.Encoding { return unicode.UTF8 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: UTF16LEEncodingFormat,
+		labels: []string{
+			"csunicode",
+			"iso-10646-ucs-2",
+			"ucs-2",
+			"unicode",
+			"unicodefeff",
+			"utf-16",
+			"utf-16le",
+		},
+		newEncoding: func() encoding.Encoding {
+			return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+		},
+		encodable: true,
+	},
+	{
+		name: UTF16BEEncodingFormat,
+		labels: []string{
+			"unicodefffe",
+			"utf-16be",
+		},
+		newEncoding: func() encoding.Encoding {
+			return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+		},
+		encodable: true,
+	},
+	{
+		name: Windows1252EncodingFormat,
+		labels: []string{
+			"ansi_x3.4-1968",
+			"ascii",
+			"cp1252",
+			"cp819",
+			"csisolatin1",
+			"ibm819",
+			"iso-8859-1",
+			"iso-ir-100",
+			"iso8859-1",
+			"iso88591",
+			"iso_8859-1",
+			"iso_8859-1:1987",
+			"l1",
+			"latin1",
+			"us-ascii",
+			"windows-1252",
+			"x-cp1252",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.Windows1252 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: Windows1250EncodingFormat,
+		labels: []string{
+			"cp1250",
+			"windows-1250",
+			"x-cp1250",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.Windows1250 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: Windows1251EncodingFormat,
+		labels: []string{
+			"cp1251",
+			"windows-1251",
+			"x-cp1251",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.Windows1251 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: Windows1253EncodingFormat,
+		labels: []string{
+			"cp1253",
+			"windows-1253",
+			"x-cp1253",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.Windows1253 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: Windows1254EncodingFormat,
+		labels: []string{
+			"cp1254",
+			"csisolatin5",
+			"iso-8859-9",
+			"iso-ir-148",
+			"iso8859-9",
+			"iso88599",
+			"iso_8859-9",
+			"iso_8859-9:1989",
+			"l5",
+			"latin5",
+			"windows-1254",
+			"x-cp1254",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.Windows1254 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: Windows1255EncodingFormat,
+		labels: []string{
+			"cp1255",
+			"windows-1255",
+			"x-cp1255",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.Windows1255 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: Windows1256EncodingFormat,
+		labels: []string{
+			"cp1256",
+			"windows-1256",
+			"x-cp1256",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.Windows1256 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: Windows1257EncodingFormat,
+		labels: []string{
+			"cp1257",
+			"windows-1257",
+			"x-cp1257",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.Windows1257 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: Windows1258EncodingFormat,
+		labels: []string{
+			"cp1258",
+			"windows-1258",
+			"x-cp1258",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.Windows1258 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: IBM866EncodingFormat,
+		labels: []string{
+			"866",
+			"cp866",
+			"csibm866",
+			"ibm866",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.CodePage866 },
+		asciiCompatible: true,
+	},
+	{
+		name: ISO88592EncodingFormat,
+		labels: []string{
+			"csisolatin2",
+			"iso-8859-2",
+			"iso-ir-101",
+			"iso8859-2",
+			"iso88592",
+			"iso_8859-2",
+			"iso_8859-2:1987",
+			"l2",
+			"latin2",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.ISO8859_2 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: ISO885915EncodingFormat,
+		labels: []string{
+			"csisolatin9",
+			"iso-8859-15",
+			"iso8859-15",
+			"iso885915",
+			"iso_8859-15",
+			"l9",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.ISO8859_15 },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: KOI8REncodingFormat,
+		labels: []string{
+			"cskoi8r",
+			"koi",
+			"koi8",
+			"koi8-r",
+			"koi8_r",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.KOI8R },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: KOI8UEncodingFormat,
+		labels: []string{
+			"koi8-ru",
+			"koi8-u",
+		},
+		newEncoding:     func() encoding.Encoding { return charmap.KOI8U },
+		encodable:       true,
+		asciiCompatible: true,
+	},
+	{
+		name: Big5EncodingFormat,
+		labels: []string{
+			"big5",
+			"big5-hkscs",
+			"cn-big5",
+			"csbig5",
+			"x-x-big5",
+		},
+		// The encoding is based on the WHATWG Big5 index, which
+		// includes the Hong Kong Supplementary Character Set.
+		newEncoding: func() encoding.Encoding { return traditionalchinese.Big5 },
+	},
+	{
+		name: GBKEncodingFormat,
+		labels: []string{
+			"chinese",
+			"csgb2312",
+			"csiso58gb231280",
+			"gb2312",
+			"gb_2312",
+			"gb_2312-80",
+			"gbk",
+			"iso-ir-58",
+			"x-gbk",
+		},
+		// GB2312 is a subset of GBK, under whose name
+		// the specification decodes it, as browsers do.
+		newEncoding: func() encoding.Encoding { return simplifiedchinese.GBK },
+	},
+	{
+		name: EUCJPEncodingFormat,
+		labels: []string{
+			"cseucpkdfmtjapanese",
+			"euc-jp",
+			"x-euc-jp",
+		},
+		newEncoding: func() encoding.Encoding { return japanese.EUCJP },
+	},
+	{
+		name: ISO2022JPEncodingFormat,
+		labels: []string{
+			"csiso2022jp",
+			"iso-2022-jp",
+		},
+		newEncoding: func() encoding.Encoding { return japanese.ISO2022JP },
+		encodable:   true,
+	},
+	{
+		name: ShiftJISEncodingFormat,
+		labels: []string{
+			"csshiftjis",
+			"ms932",
+			"ms_kanji",
+			"shift-jis",
+			"shift_jis",
+			"sjis",
+			"windows-31j",
+			"x-sjis",
+		},
+		newEncoding: func() encoding.Encoding { return japanese.ShiftJIS },
+	},
+	{
+		name: ReplacementEncodingFormat,
+		labels: []string{
+			"csiso2022kr",
+			"hz-gb-2312",
+			"iso-2022-cn",
+			"iso-2022-cn-ext",
+			"iso-2022-kr",
+			"replacement",
+		},
+		newEncoding: func() encoding.Encoding { return replacementEncoding{} },
+	},
+	{
+		name:            XUserDefinedEncodingFormat,
+		labels:          []string{"x-user-defined"},
+		newEncoding:     func() encoding.Encoding { return xUserDefined },
+		asciiCompatible: true,
+	},
+}
+
+// encodingsByLabel indexes the entries of the encodingsTable by label.
+//
+//nolint:gochecknoglobals
+var encodingsByLabel = indexEncodingsByLabel(encodingsTable)
+
+// indexEncodingsByLabel returns a map of each label of the given
+// entries to the entry it belongs to.
+func indexEncodingsByLabel(entries []encodingEntry) map[string]*encodingEntry {
+	index := make(map[string]*encodingEntry)
+	for i := range entries {
+		for _, label := range entries[i].labels {
+			index[label] = &entries[i]
+		}
+	}
+
+	return index
+}
+
+// codePages maps the numeric identifiers of Windows code pages
+// to the canonical name of the encoding they correspond to.
+//
+//nolint:gochecknoglobals
+var codePages = map[string]EncodingName{
+	"866":   IBM866EncodingFormat,
+	"932":   ShiftJISEncodingFormat,
+	"936":   GBKEncodingFormat,
+	"950":   Big5EncodingFormat,
+	"1200":  UTF16LEEncodingFormat,
+	"1201":  UTF16BEEncodingFormat,
+	"1250":  Windows1250EncodingFormat,
+	"1251":  Windows1251EncodingFormat,
+	"1252":  Windows1252EncodingFormat,
+	"1253":  Windows1253EncodingFormat,
+	"1254":  Windows1254EncodingFormat,
+	"1255":  Windows1255EncodingFormat,
+	"1256":  Windows1256EncodingFormat,
+	"1257":  Windows1257EncodingFormat,
+	"1258":  Windows1258EncodingFormat,
+	"20866": KOI8REncodingFormat,
+	"20932": EUCJPEncodingFormat,
+	"21866": KOI8UEncodingFormat,
+	"28592": ISO88592EncodingFormat,
+	"28605": ISO885915EncodingFormat,
+	"50220": ISO2022JPEncodingFormat,
+	"65001": UTF8EncodingFormat,
+}
+
+// customEncodings holds the encodings registered at runtime, indexed by label.
+//
+// As encodings can be registered by any VU, accesses are synchronized.
+//
+//nolint:gochecknoglobals
+var customEncodings = struct {
+	sync.RWMutex
+	byLabel map[string]*encodingEntry
+}{
+	byLabel: make(map[string]*encodingEntry),
+}
+
+// registerEncoding installs the given entry, so that its labels resolve to it.
+//
+// Registering an entry with a label of a built-in encoding, including the
+// numeric code page identifiers resolving to one, is an error, while
+// registering an entry with the label of a previously registered entry replaces it.
+func registerEncoding(entry *encodingEntry) error {
+	for _, label := range entry.labels {
+		_, isBuiltin := encodingsByLabel[label]
+		_, isCodePage := codePages[label]
+		if isBuiltin || isCodePage {
+			return NewError(RangeError, fmt.Sprintf("unable to register encoding; reason: %s is a built-in encoding", label))
+		}
+	}
+
+	customEncodings.Lock()
+	defer customEncodings.Unlock()
+
+	for _, label := range entry.labels {
+		customEncodings.byLabel[label] = entry
+	}
+
+	return nil
+}
+
+// normalizeLabel returns the given label, stripped of its leading and
+// trailing whitespaces, and lowercased.
+func normalizeLabel(label string) string {
+	return strings.TrimSpace(strings.ToLower(label))
+}
+
+// lookupEncoding returns the entry of the encoding the given label resolves to.
+//
+// As per the specification, the label is matched case-insensitively,
+// and regardless of its leading and trailing whitespaces. Numeric
+// Windows code page identifiers, such as 1252, are accepted too.
+func lookupEncoding(label string) (*encodingEntry, bool) {
+	label = normalizeLabel(label)
+	if name, ok := codePages[label]; ok {
+		label = name
+	}
+
+	if entry, ok := encodingsByLabel[label]; ok {
+		return entry, true
+	}
+
+	customEncodings.RLock()
+	defer customEncodings.RUnlock()
+
+	entry, ok := customEncodings.byLabel[label]
+	return entry, ok
+}
+
+// CanonicalName returns the canonical name of the encoding the given
+// label resolves to, as reported by the encoding property of browsers'
+// TextDecoder and TextEncoder instances.
+func CanonicalName(label string) (EncodingName, error) {
+	entry, ok := lookupEncoding(label)
+	if !ok {
+		return "", NewError(RangeError, fmt.Sprintf("unsupported encoding: %s", label))
+	}
+
+	return entry.name, nil
+}
+
+// LabelsFor returns all the labels resolving to the same encoding
+// as the given label, including its canonical name.
+func LabelsFor(label string) ([]string, error) {
+	entry, ok := lookupEncoding(label)
+	if !ok {
+		return nil, NewError(RangeError, fmt.Sprintf("unsupported encoding: %s", label))
+	}
+
+	labels := make([]string, len(entry.labels))
+	copy(labels, entry.labels)
+
+	return labels, nil
+}
+
+// byteOrderMark returns the byte order mark of the given encoding,
+// or nil if the encoding has none.
+func byteOrderMark(name EncodingName) []byte {
+	switch name {
+	case UTF8EncodingFormat:
+		return []byte{0xEF, 0xBB, 0xBF}
+	case UTF16LEEncodingFormat:
+		return []byte{0xFF, 0xFE}
+	case UTF16BEEncodingFormat:
+		return []byte{0xFE, 0xFF}
+	default:
+		return nil
+	}
+}
+
+// utf16ByteOrder returns the byte order of the given encoding,
+// and whether it is a UTF-16 encoding at all.
+func utf16ByteOrder(name EncodingName) (binary.ByteOrder, bool) {
+	switch name {
+	case UTF16LEEncodingFormat:
+		return binary.LittleEndian, true
+	case UTF16BEEncodingFormat:
+		return binary.BigEndian, true
+	default:
+		return nil, false
+	}
+}
+
+// sniffBOM returns the name of the encoding announced by the byte order mark
+// the given data starts with, along with the length of that byte order mark.
+//
+// If the data does not start with a byte order mark, an empty name is returned.
+func sniffBOM(data []byte) (EncodingName, int) {
+	for _, name := range []EncodingName{UTF8EncodingFormat, UTF16LEEncodingFormat, UTF16BEEncodingFormat} {
+		if bom := byteOrderMark(name); bytes.HasPrefix(data, bom) {
+			return name, len(bom)
+		}
+	}
+
+	return "", 0
+}
+
+// PeekEncoding returns a best guess of the canonical name of the encoding
+// the given data is encoded with, without decoding it.
+//
+// The heuristic is deliberately minimal: a byte order mark, if any, always
+// wins. Otherwise, data consisting of valid UTF-8 is assumed to be utf-8.
+// In any other case, the encoding is deemed unknown, and an empty string
+// is returned.
+func PeekEncoding(data []byte) EncodingName {
+	if name, _ := sniffBOM(data); name != "" {
+		return name
+	}
+
+	if utf8.Valid(data) {
+		return UTF8EncodingFormat
+	}
+
+	return ""
+}
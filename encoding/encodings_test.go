@@ -0,0 +1,263 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelsFor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("canonical name", func(t *testing.T) {
+		t.Parallel()
+
+		labels, err := LabelsFor("utf-16le")
+		require.NoError(t, err)
+		assert.Contains(t, labels, "unicode")
+		assert.Contains(t, labels, "ucs-2")
+	})
+
+	t.Run("alias", func(t *testing.T) {
+		t.Parallel()
+
+		labels, err := LabelsFor(" UTF8 ")
+		require.NoError(t, err)
+		assert.Contains(t, labels, "utf-8")
+		assert.Contains(t, labels, "unicode-1-1-utf-8")
+	})
+
+	t.Run("unknown label", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := LabelsFor("not-an-encoding")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+	})
+
+	t.Run("every label resolves back to its encoding", func(t *testing.T) {
+		t.Parallel()
+
+		for _, entry := range encodingsTable {
+			for _, label := range entry.labels {
+				got, ok := lookupEncoding(label)
+				require.True(t, ok, label)
+				assert.Equal(t, entry.name, got.name, label)
+			}
+		}
+	})
+}
+
+func TestLookupEncodingCodePages(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		codePage string
+		want     EncodingName
+	}{
+		{codePage: "1252", want: Windows1252EncodingFormat},
+		{codePage: " 1252 ", want: Windows1252EncodingFormat},
+		{codePage: "1250", want: Windows1250EncodingFormat},
+		{codePage: "1251", want: Windows1251EncodingFormat},
+		{codePage: "1257", want: Windows1257EncodingFormat},
+		{codePage: "65001", want: UTF8EncodingFormat},
+		{codePage: "1200", want: UTF16LEEncodingFormat},
+		{codePage: "1201", want: UTF16BEEncodingFormat},
+		{codePage: "866", want: IBM866EncodingFormat},
+		{codePage: "932", want: ShiftJISEncodingFormat},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.codePage, func(t *testing.T) {
+			t.Parallel()
+
+			entry, ok := lookupEncoding(tc.codePage)
+			require.True(t, ok)
+			assert.Equal(t, tc.want, entry.name)
+		})
+	}
+
+	t.Run("unknown code page", func(t *testing.T) {
+		t.Parallel()
+
+		_, ok := lookupEncoding("1234")
+		assert.False(t, ok)
+	})
+
+	t.Run("decoder", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			assert_equals(new TextDecoder("1252").encoding, "windows-1252");
+			assert_equals(new TextDecoder("65001").encoding, "utf-8");
+			assert_equals(new TextDecoder("866").decode(new Uint8Array([0x8F, 0xE0, 0xA8])), "\u041f\u0440\u0438");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestCanonicalName(t *testing.T) {
+	t.Parallel()
+
+	// A representative label of each encoding, along with the canonical
+	// name browsers report for it, as per the WHATWG Encoding Standard.
+	testCases := []struct {
+		label string
+		want  EncodingName
+	}{
+		{label: "unicode-1-1-utf-8", want: "utf-8"},
+		{label: "utf-16", want: "utf-16le"},
+		{label: "ucs-2", want: "utf-16le"},
+		{label: "unicodefffe", want: "utf-16be"},
+		{label: "cp866", want: "ibm866"},
+		{label: "koi8", want: "koi8-r"},
+		{label: "koi8-ru", want: "koi8-u"},
+		{label: "latin2", want: "iso-8859-2"},
+		{label: "l9", want: "iso-8859-15"},
+		{label: "latin1", want: "windows-1252"},
+		{label: "us-ascii", want: "windows-1252"},
+		{label: "x-cp1250", want: "windows-1250"},
+		{label: "cp1251", want: "windows-1251"},
+		{label: "x-cp1255", want: "windows-1255"},
+		{label: "cp1256", want: "windows-1256"},
+		{label: "cp1257", want: "windows-1257"},
+		{label: "cp1258", want: "windows-1258"},
+		{label: "big5-hkscs", want: "big5"},
+		{label: "gb2312", want: "gbk"},
+		{label: "iso-ir-58", want: "gbk"},
+		{label: "x-euc-jp", want: "euc-jp"},
+		{label: "csiso2022jp", want: "iso-2022-jp"},
+		{label: "sjis", want: "shift_jis"},
+		{label: "windows-31j", want: "shift_jis"},
+		{label: "iso-2022-kr", want: "replacement"},
+		{label: "x-user-defined", want: "x-user-defined"},
+		{label: " UTF8 ", want: "utf-8"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.label, func(t *testing.T) {
+			t.Parallel()
+
+			name, err := CanonicalName(tc.label)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, name)
+
+			td, err := NewTextDecoder(nil, tc.label, textDecoderOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, td.Encoding)
+		})
+	}
+
+	t.Run("unknown label", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := CanonicalName("utf-7")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			assert_equals(canonicalName("latin1"), "windows-1252");
+			assert_equals(canonicalName("utf-16"), "utf-16le");
+			assert_equals(canonicalName("latin1"), new TextDecoder("latin1").encoding);
+
+			let error;
+			try {
+				canonicalName("utf-7");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof RangeError, "an unknown label should throw a RangeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestLabelsForJS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const labels = labelsFor("utf-16le");
+		assert_true(Array.isArray(labels), "labelsFor should return an array");
+		assert_in_array("unicode", labels, "labels should contain unicode");
+		assert_in_array("ucs-2", labels, "labels should contain ucs-2");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestPeekEncoding(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		data []byte
+		want EncodingName
+	}{
+		{
+			name: "utf-8 BOM",
+			data: []byte{0xEF, 0xBB, 0xBF, 0x61},
+			want: UTF8EncodingFormat,
+		},
+		{
+			name: "utf-16le BOM",
+			data: []byte{0xFF, 0xFE, 0x61, 0x00},
+			want: UTF16LEEncodingFormat,
+		},
+		{
+			name: "utf-16be BOM",
+			data: []byte{0xFE, 0xFF, 0x00, 0x61},
+			want: UTF16BEEncodingFormat,
+		},
+		{
+			name: "valid utf-8 without BOM",
+			data: []byte{0x7A, 0xC2, 0xA2, 0xE6, 0xB0, 0xB4, 0xF0, 0x9D, 0x84, 0x9E},
+			want: UTF8EncodingFormat,
+		},
+		{
+			name: "non utf-8 bytes",
+			data: []byte{0x63, 0x61, 0x66, 0xE9, 0x20, 0xFF},
+			want: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, PeekEncoding(tc.data))
+		})
+	}
+}
+
+func TestPeekEncodingJS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		assert_equals(peekEncoding(new Uint8Array([0xef, 0xbb, 0xbf, 0x61])), "utf-8", "utf-8 BOM");
+		assert_equals(peekEncoding(new Uint8Array([0x61, 0xc2, 0xa2]).buffer), "utf-8", "valid utf-8");
+		assert_equals(peekEncoding(new Uint8Array([0x61, 0xe9, 0xff])), "", "non utf-8 bytes");
+	`)
+	assert.NoError(t, err)
+}
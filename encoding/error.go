@@ -1,6 +1,12 @@
 package encoding
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
 
 // ErrorName is a type alias for the name of an encoding error.
 //
@@ -17,6 +23,10 @@ const (
 	// TypeError is thrown if the value if the Decoder fatal option
 	// is set and the input data cannot be decoded.
 	TypeError ErrorName = "TypeError"
+
+	// SyntaxError is thrown if text decoded to be parsed
+	// as JSON does not hold valid JSON.
+	SyntaxError ErrorName = "SyntaxError"
 )
 
 // Error represents an encoding error.
@@ -41,4 +51,47 @@ func NewError(name, message string) *Error {
 	}
 }
 
+// ToJSError returns the native JS error object, of the same name and
+// message, corresponding to the error.
+//
+// As any JS error, the returned object holds the stack
+// of the runtime at the point it was created.
+func (e *Error) ToJSError(rt *goja.Runtime) *goja.Object {
+	if e.Name == TypeError {
+		return rt.NewTypeError(e.Message)
+	}
+
+	obj, err := rt.New(rt.Get(e.Name), rt.ToValue(e.Message))
+	if err != nil {
+		return rt.NewGoError(e)
+	}
+
+	return obj
+}
+
 var _ error = (*Error)(nil)
+
+// jsError returns the JS value the given error is thrown as by throw, for
+// the functions reporting errors by rejecting a promise, rather than throwing.
+func jsError(rt *goja.Runtime, err error) goja.Value {
+	var encodingErr *Error
+	if errors.As(err, &encodingErr) {
+		return encodingErr.ToJSError(rt)
+	}
+
+	return rt.NewGoError(err)
+}
+
+// throw throws the given error in the given runtime.
+//
+// Encoding errors are thrown as the native JS errors they correspond to, so
+// that scripts can tell them apart with instanceof, and log their stack.
+// Other errors are thrown as Go errors, as with [common.Throw].
+func throw(rt *goja.Runtime, err error) {
+	var encodingErr *Error
+	if errors.As(err, &encodingErr) {
+		panic(encodingErr.ToJSError(rt))
+	}
+
+	common.Throw(rt, err)
+}
@@ -0,0 +1,42 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrownErrors(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		function decodeWithUnknownLabel() {
+			return new TextDecoder("not-an-encoding");
+		}
+
+		try {
+			decodeWithUnknownLabel();
+			assert_true(false, "constructing a decoder with an unknown label should throw");
+		} catch (e) {
+			assert_true(e instanceof RangeError, "error should be a RangeError");
+			assert_equals(e.name, "RangeError");
+			assert_equals(e.message, "unsupported encoding: not-an-encoding");
+			assert_equals(typeof e.stack, "string", "error should hold a stack");
+			assert_true(e.stack.length > 0, "stack should not be empty");
+			assert_true(e.stack.includes("decodeWithUnknownLabel"), "stack should include the calling function");
+		}
+
+		try {
+			new TextDecoder().decode(new Uint8Array([0x61]), { output: "array" });
+			assert_true(false, "decoding with an unknown output should throw");
+		} catch (e) {
+			assert_true(e instanceof TypeError, "error should be a TypeError");
+			assert_equals(e.name, "TypeError");
+			assert_equals(typeof e.stack, "string", "error should hold a stack");
+			assert_true(e.stack.length > 0, "stack should not be empty");
+		}
+	`)
+	assert.NoError(t, err)
+}
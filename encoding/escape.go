@@ -0,0 +1,89 @@
+package encoding
+
+import "unicode/utf8"
+
+// escapedByteBase is the first of the Private Use Area code points, U+F700 to
+// U+F7FF, the bytes of malformed sequences are escaped to when decoding with
+// the escapeInvalid option, each byte being added to it.
+//
+// Bytes 0x80 to 0xFF are thus escaped to the code points the x-user-defined
+// encoding decodes them to.
+const escapedByteBase = 0xF700
+
+// escapeMalformed decodes the given data, made of complete sequences only, as
+// a fresh transformer would, but for the bytes of malformed sequences, which are
+// escaped rather than substituted with replacement characters.
+//
+// The decoded text is written to dest, which is grown as needed.
+func (td *TextDecoder) escapeMalformed(dest, data []byte) []byte {
+	dest = dest[:0]
+	td.decodeCharacters(data, func(_ int, decoded, source []byte, substituted bool) {
+		if substituted {
+			dest = appendEscapedBytes(dest, source)
+			return
+		}
+
+		dest = append(dest, decoded...)
+	})
+
+	return dest
+}
+
+// appendEscapedBytes appends the code points the given bytes escape to, to dest.
+func appendEscapedBytes(dest, source []byte) []byte {
+	for _, b := range source {
+		dest = utf8.AppendRune(dest, escapedByteBase+rune(b))
+	}
+
+	return dest
+}
+
+// unescapeByte returns the byte the given code point escapes, if it is one of
+// the code points bytes are escaped to.
+func unescapeByte(r rune) (byte, bool) {
+	if r < escapedByteBase || r > escapedByteBase+0xFF {
+		return 0, false
+	}
+
+	return byte(r - escapedByteBase), true
+}
+
+// encodeEscaped encodes the given text as Encode does, but for the code points
+// bytes are escaped to, which are written as the bytes they escape.
+//
+// The text in between escaped bytes is encoded independently, so that stateful
+// encodings end each run of it in their initial state.
+func (te *TextEncoder) encodeEscaped(text string) ([]byte, error) {
+	encoded := make([]byte, 0, len(text))
+
+	start := 0
+	for i, r := range text {
+		b, ok := unescapeByte(r)
+		if !ok {
+			continue
+		}
+
+		if start < i {
+			run, err := te.newEncoder().Bytes([]byte(text[start:i]))
+			if err != nil {
+				return nil, NewError(TypeError, "unable to encode text; reason: "+err.Error())
+			}
+
+			encoded = append(encoded, run...)
+		}
+
+		encoded = append(encoded, b)
+		start = i + utf8.RuneLen(r)
+	}
+
+	if start < len(text) {
+		run, err := te.newEncoder().Bytes([]byte(text[start:]))
+		if err != nil {
+			return nil, NewError(TypeError, "unable to encode text; reason: "+err.Error())
+		}
+
+		encoded = append(encoded, run...)
+	}
+
+	return encoded, nil
+}
@@ -0,0 +1,133 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		label       string
+		data        []byte
+		wantDecoded string
+	}{
+		{
+			name:        "utf-8 invalid bytes",
+			label:       UTF8EncodingFormat,
+			data:        []byte{0x61, 0xFF, 0x62, 0xC0, 0xAF, 0x63},
+			wantDecoded: "a\uF7FFb\uF7C0\uF7AFc",
+		},
+		{
+			name:        "utf-8 truncated sequence",
+			label:       UTF8EncodingFormat,
+			data:        []byte{0x61, 0xE6, 0xB0},
+			wantDecoded: "a\uF7E6\uF7B0",
+		},
+		{
+			name:        "utf-8 replacement character encoded as such",
+			label:       UTF8EncodingFormat,
+			data:        []byte{0xEF, 0xBF, 0xBD, 0x80},
+			wantDecoded: "�\uF780",
+		},
+		{
+			name:        "utf-8 without invalid bytes",
+			label:       UTF8EncodingFormat,
+			data:        []byte{0x63, 0x61, 0x66, 0xC3, 0xA9},
+			wantDecoded: "café",
+		},
+		{
+			name:        "utf-16le lone surrogate and truncated code unit",
+			label:       UTF16LEEncodingFormat,
+			data:        []byte{0x41, 0x00, 0x00, 0xD8, 0x42, 0x00, 0x43},
+			wantDecoded: "A\uF700\uF7D8B\uF743",
+		},
+		{
+			name:        "shift_jis invalid lead byte",
+			label:       ShiftJISEncodingFormat,
+			data:        []byte{0x82, 0xA0, 0xA0, 0x61},
+			wantDecoded: "あ\uF7A0a",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.label, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(tc.data, decodeOptions{EscapeInvalid: true})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantDecoded, decoded)
+
+			// Only the encodings the TextEncoder supports can recover the original bytes
+			te, err := NewTextEncoder(tc.label, textEncoderOptions{EscapeInvalid: true})
+			if err != nil {
+				return
+			}
+
+			encoded, err := te.Encode(decoded)
+			require.NoError(t, err)
+			assert.Equal(t, tc.data, encoded)
+
+			shared, err := te.EncodeShared(decoded)
+			require.NoError(t, err)
+			assert.Equal(t, tc.data, shared)
+
+			destination := make([]byte, len(tc.data))
+			_, written, err := te.EncodeInto(decoded, destination)
+			require.NoError(t, err)
+			assert.Equal(t, len(tc.data), written)
+			assert.Equal(t, tc.data, destination)
+		})
+	}
+
+	t.Run("escaped bytes are encoded as themselves by default", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder(UTF8EncodingFormat, textEncoderOptions{})
+		require.NoError(t, err)
+
+		encoded, err := te.Encode("\uF7FF")
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0xEF, 0x9F, 0xBF}, encoded)
+	})
+
+	t.Run("escaped bytes amid text of another encoding", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder(Windows1252EncodingFormat, textEncoderOptions{EscapeInvalid: true})
+		require.NoError(t, err)
+
+		encoded, err := te.Encode("é\uF781€")
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0xE9, 0x81, 0x80}, encoded)
+	})
+}
+
+func TestEscapeInvalidJS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const bytes = new Uint8Array([0x61, 0xff, 0xe6, 0xb0, 0xb4, 0xc3]);
+
+		const decoded = new TextDecoder().decode(bytes, { escapeInvalid: true });
+		assert_equals(decoded, "a\uF7FF水\uF7C3");
+		assert_equals(new TextDecoder().decode(bytes), "a�水�", "escapeInvalid should default to false");
+
+		const encoder = new TextEncoder("utf-8", { escapeInvalid: true });
+		assert_true(encoder.escapeInvalid, "escapeInvalid property should be set");
+		assert_equals(encoder.encode(decoded).join(","), bytes.join(","), "re-encoded bytes");
+		assert_false(new TextEncoder().escapeInvalid, "escapeInvalid property should not be set");
+	`)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,36 @@
+package encoding
+
+// DecodeOrFallback decodes the given data using the encoding the primary
+// label resolves to, in fatal mode, and falls back to decoding it using the
+// encoding the fallback label resolves to, in replacement mode, should the
+// data not be valid in the primary encoding.
+//
+// It returns the decoded text, along with the name of the encoding used to
+// decode it. This is the "utf-8, else windows-1252" heuristic browsers and
+// editors apply to documents of unknown encoding.
+func DecodeOrFallback(data []byte, primaryLabel, fallbackLabel string) (string, EncodingName, error) {
+	primary, err := NewTextDecoder(nil, primaryLabel, textDecoderOptions{Fatal: true})
+	if err != nil {
+		return "", "", err
+	}
+
+	// Resolve the fallback label upfront, so that an invalid
+	// one is reported regardless of the data.
+	fallback, err := NewTextDecoder(nil, fallbackLabel, textDecoderOptions{})
+	if err != nil {
+		return "", "", err
+	}
+
+	// Decoding in fatal mode fails on any malformed sequence
+	decoded, err := primary.Decode(data, decodeOptions{})
+	if err == nil {
+		return decoded, primary.Encoding, nil
+	}
+
+	decoded, err = fallback.Decode(data, decodeOptions{})
+	if err != nil {
+		return "", "", err
+	}
+
+	return decoded, fallback.Encoding, nil
+}
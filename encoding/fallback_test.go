@@ -0,0 +1,84 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeOrFallback(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		wantDecoded  string
+		wantEncoding EncodingName
+	}{
+		{
+			name:         "valid utf-8 uses the primary encoding",
+			data:         []byte{0x63, 0x61, 0x66, 0xC3, 0xA9},
+			wantDecoded:  "café",
+			wantEncoding: UTF8EncodingFormat,
+		},
+		{
+			name:         "genuine replacement character uses the primary encoding",
+			data:         []byte{0xEF, 0xBF, 0xBD},
+			wantDecoded:  "�",
+			wantEncoding: UTF8EncodingFormat,
+		},
+		{
+			name:         "invalid utf-8 falls back to windows-1252",
+			data:         []byte{0x63, 0x61, 0x66, 0xE9},
+			wantDecoded:  "café",
+			wantEncoding: Windows1252EncodingFormat,
+		},
+		{
+			name:         "truncated utf-8 falls back to windows-1252",
+			data:         []byte{0x61, 0xE2, 0x82},
+			wantDecoded:  "aâ‚",
+			wantEncoding: Windows1252EncodingFormat,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoded, name, err := DecodeOrFallback(tc.data, "utf-8", "latin1")
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantDecoded, decoded)
+			assert.Equal(t, tc.wantEncoding, name)
+		})
+	}
+
+	t.Run("unknown fallback label", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := DecodeOrFallback([]byte{0x61}, "utf-8", "not-an-encoding")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+	})
+}
+
+func TestDecodeOrFallbackJS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		let result = decodeOrFallback(new Uint8Array([0x63, 0x61, 0x66, 0xc3, 0xa9]), "utf-8", "windows-1252");
+		assert_equals(result.value, "café");
+		assert_equals(result.encoding, "utf-8");
+
+		result = decodeOrFallback(new Uint8Array([0x63, 0x61, 0x66, 0xe9]).buffer, "utf-8", "windows-1252");
+		assert_equals(result.value, "café");
+		assert_equals(result.encoding, "windows-1252");
+	`)
+	assert.NoError(t, err)
+}
@@ -1,8 +1,12 @@
 package encoding
 
 import (
-	"errors"
 	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/dop251/goja"
 	"go.k6.io/k6/js/common"
@@ -23,31 +27,299 @@ func setReadOnlyPropertyOf(obj *goja.Object, name string, value goja.Value) erro
 	return nil
 }
 
-// exportArrayBuffer interprets the given value as an ArrayBuffer, TypedArray or DataView
-// and returns a copy of the underlying byte slice.
-func exportArrayBuffer(rt *goja.Runtime, v goja.Value) ([]byte, error) {
-	if common.IsNullish(v) {
-		return nil, NewError(TypeError, "data is null or undefined")
+// setReadOnlyAccessorPropertyOf sets a read-only property, whose value
+// is computed by the given getter function, on the given [goja.Object].
+func setReadOnlyAccessorPropertyOf(obj *goja.Object, name string, getter goja.Value) error {
+	err := obj.DefineAccessorProperty(name,
+		getter,
+		nil,
+		goja.FLAG_FALSE,
+		goja.FLAG_TRUE,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to define %s read-only accessor property; reason: %w", name, err)
+	}
+
+	return nil
+}
+
+// isString returns true if the given value is a string primitive.
+func isString(v goja.Value) bool {
+	return !common.IsNullish(v) && v.ExportType() == reflect.TypeOf("")
+}
+
+// hasLoneSurrogates returns true if the given string value holds UTF-16
+// surrogate code units which are not part of a surrogate pair.
+func hasLoneSurrogates(rt *goja.Runtime, v goja.Value) bool {
+	return len(loneSurrogateIndices(rt, v)) > 0
+}
+
+// loneSurrogateIndices returns the indices, in UTF-16 code units, of the
+// surrogate code units of the given string value which are not part of a
+// surrogate pair.
+//
+// As converting such a string to a Go string substitutes each lone surrogate
+// with a replacement character, only the replacement characters found in the
+// converted string are checked against the original string's code units.
+func loneSurrogateIndices(rt *goja.Runtime, v goja.Value) []int {
+	s := v.String()
+	if !strings.ContainsRune(s, utf8.RuneError) {
+		return nil
+	}
+
+	charCodeAt, ok := goja.AssertFunction(v.ToObject(rt).Get("charCodeAt"))
+	if !ok {
+		return nil
+	}
+
+	var indices []int
+
+	// index holds the position of the current code point in UTF-16 code units
+	var index int
+	for _, r := range s {
+		if r == utf8.RuneError {
+			code, err := charCodeAt(v, rt.ToValue(index))
+			if err == nil && code.ToInteger() != utf8.RuneError {
+				indices = append(indices, index)
+			}
+		}
+
+		// Code points outside the BMP are encoded as a surrogate pair
+		index++
+		if r > 0xFFFF {
+			index++
+		}
+	}
+
+	return indices
+}
+
+// hasLoneSurrogatesAcross returns true if the concatenation of the given
+// string values, whose first and last UTF-16 code units are given as their
+// edges, holds surrogate code units which are not part of a surrogate pair.
+//
+// Surrogate pairs split across values, empty values aside, are not lone.
+func hasLoneSurrogatesAcross(rt *goja.Runtime, values []goja.Value, edges [][2]uint16) bool {
+	// nextFirst returns the first code unit of the first
+	// non-empty value following the i-th one, if any.
+	nextFirst := func(i int) uint16 {
+		for j := i + 1; j < len(values); j++ {
+			if values[j].ToObject(rt).Get("length").ToInteger() > 0 {
+				return edges[j][0]
+			}
+		}
+
+		return 0
+	}
+
+	var previousLast uint16
+	for i, v := range values {
+		length := int(v.ToObject(rt).Get("length").ToInteger())
+		if length == 0 {
+			continue
+		}
+
+		for _, index := range loneSurrogateIndices(rt, v) {
+			switch {
+			case index == 0 && isLowSurrogate(edges[i][0]) && isHighSurrogate(previousLast):
+			case index == length-1 && isHighSurrogate(edges[i][1]) && isLowSurrogate(nextFirst(i)):
+			default:
+				return true
+			}
+		}
+
+		previousLast = edges[i][1]
+	}
+
+	return false
+}
+
+// newCodePointsArray returns a JS array holding the code points of the given string.
+func newCodePointsArray(rt *goja.Runtime, s string) *goja.Object {
+	codePoints := make([]interface{}, 0, utf8.RuneCountInString(s))
+	for _, r := range s {
+		codePoints = append(codePoints, int64(r))
+	}
+
+	return rt.NewArray(codePoints...)
+}
+
+// newCodeUnitsArray returns a JS Uint16Array holding the UTF-16 code units
+// of the given string, astral code points being split into surrogate pairs.
+func newCodeUnitsArray(rt *goja.Runtime, s string) (*goja.Object, error) {
+	units := utf16.Encode([]rune(s))
+
+	codeUnits := make([]interface{}, 0, len(units))
+	for _, unit := range units {
+		codeUnits = append(codeUnits, int64(unit))
+	}
+
+	return rt.New(rt.Get(Uint16ArrayConstructor), rt.NewArray(codeUnits...))
+}
+
+// truncateString returns the given string value truncated to at most
+// the given number of UTF-16 code units, without converting it to a Go
+// string beforehand.
+func truncateString(rt *goja.Runtime, v goja.Value, length int) goja.Value {
+	s := v.ToString()
+
+	asObject := s.ToObject(rt)
+	if asObject.Get("length").ToInteger() <= int64(length) {
+		return s
+	}
+
+	substring, ok := goja.AssertFunction(asObject.Get("substring"))
+	if !ok {
+		return s
+	}
+
+	truncated, err := substring(s, rt.ToValue(0), rt.ToValue(length))
+	if err != nil {
+		return s
 	}
 
+	return truncated
+}
+
+// stringEdges returns the first and last UTF-16 code units of the given
+// string value, or zeros if it is empty, without converting it to a Go string.
+func stringEdges(rt *goja.Runtime, v goja.Value) (first, last uint16) {
 	asObject := v.ToObject(rt)
 
-	var ab goja.ArrayBuffer
-	var ok bool
+	length := asObject.Get("length").ToInteger()
+	if length == 0 {
+		return 0, 0
+	}
+
+	charCodeAt, ok := goja.AssertFunction(asObject.Get("charCodeAt"))
+	if !ok {
+		return 0, 0
+	}
+
+	if code, err := charCodeAt(v, rt.ToValue(0)); err == nil {
+		first = uint16(code.ToInteger())
+	}
+
+	if code, err := charCodeAt(v, rt.ToValue(length-1)); err == nil {
+		last = uint16(code.ToInteger())
+	}
+
+	return first, last
+}
+
+// exportLabel returns the encoding label the given value holds, either as a
+// string, or as the name or label property of an encoding descriptor object,
+// such as { name: "utf-8" }. Any other value is coerced to a string.
+func exportLabel(rt *goja.Runtime, v goja.Value) (string, error) {
+	if common.IsNullish(v) {
+		return "", nil
+	}
+
+	if obj, ok := v.(*goja.Object); ok {
+		for _, key := range []string{"name", "label"} {
+			if property := obj.Get(key); !common.IsNullish(property) {
+				return property.String(), nil
+			}
+		}
+	}
+
+	var label string
+	if err := rt.ExportTo(v, &label); err != nil {
+		return "", err
+	}
+
+	return label, nil
+}
+
+// jsReader adapts a JS object holding a read(size) method, returning an
+// ArrayBuffer, TypedArray or DataView of at most size bytes, or null or
+// undefined once exhausted, to the io.Reader interface.
+type jsReader struct {
+	rt   *goja.Runtime
+	this goja.Value
+	read goja.Callable
+
+	// pending holds the bytes the last read returned
+	// which did not fit in the caller's buffer.
+	pending []byte
+}
+
+// newJSReader returns a jsReader reading from the given value,
+// which must hold a read method.
+func newJSReader(rt *goja.Runtime, v goja.Value) (*jsReader, error) {
+	if common.IsNullish(v) {
+		return nil, NewError(TypeError, "reader is null or undefined")
+	}
+
+	read, ok := goja.AssertFunction(v.ToObject(rt).Get("read"))
+	if !ok {
+		return nil, NewError(TypeError, "reader must have a read method")
+	}
 
-	if IsTypedArray(rt, v) {
-		ab, ok = asObject.Get("buffer").Export().(goja.ArrayBuffer)
-		if !ok {
-			return nil, errors.New("TypedArray.buffer is not an ArrayBuffer")
+	return &jsReader{rt: rt, this: v, read: read}, nil
+}
+
+// Read implements the io.Reader interface.
+func (r *jsReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		v, err := r.read(r.this, r.rt.ToValue(len(p)))
+		if err != nil {
+			return 0, err
 		}
-	} else {
-		ab, ok = asObject.Export().(goja.ArrayBuffer)
-		if !ok {
-			return nil, errors.New("data is neither an ArrayBuffer, nor a TypedArray nor DataView")
+
+		if common.IsNullish(v) {
+			return 0, io.EOF
 		}
+
+		data, err := exportArrayBuffer(r.rt, v)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(data) == 0 {
+			return 0, io.EOF
+		}
+
+		r.pending = data
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
+
+// then resolves the given value as Promise.resolve does, and registers the
+// given callbacks to be called once the resulting promise settles.
+func then(rt *goja.Runtime, v goja.Value, onFulfilled, onRejected func(goja.FunctionCall) goja.Value) error {
+	promiseConstructor := rt.Get("Promise").ToObject(rt)
+
+	resolve, ok := goja.AssertFunction(promiseConstructor.Get("resolve"))
+	if !ok {
+		return NewError(TypeError, "Promise.resolve is not a function")
+	}
+
+	promise, err := resolve(promiseConstructor, v)
+	if err != nil {
+		return err
 	}
 
-	return ab.Bytes(), nil
+	promiseThen, ok := goja.AssertFunction(promise.ToObject(rt).Get("then"))
+	if !ok {
+		return NewError(TypeError, "promise.then is not a function")
+	}
+
+	_, err = promiseThen(promise, rt.ToValue(onFulfilled), rt.ToValue(onRejected))
+
+	return err
+}
+
+// isDetached returns true if the given TypedArray or DataView
+// views an ArrayBuffer which has been detached.
+func isDetached(view *goja.Object) bool {
+	ab, ok := view.Get("buffer").Export().(goja.ArrayBuffer)
+
+	return ok && ab.Detached()
 }
 
 // IsInstanceOf returns true if the given value is an instance of the given constructor
@@ -0,0 +1,20 @@
+package encoding
+
+import "github.com/rivo/uniseg"
+
+// GraphemeCount returns the number of extended grapheme clusters, that is
+// user-perceived characters, the given text is made of.
+//
+// Clusters are delimited as per the rules of [Unicode Standard Annex #29],
+// so that a base character followed by combining marks, an emoji ZWJ sequence,
+// an emoji followed by a skin tone modifier, a flag made of a pair of regional
+// indicators, a Hangul syllable made of conjoining jamo, or a CRLF line ending,
+// each count as a single character.
+//
+// The segmentation, along with the Unicode character properties it relies on,
+// is delegated to the uniseg package.
+//
+// [Unicode Standard Annex #29]: https://www.unicode.org/reports/tr29/#Grapheme_Cluster_Boundary_Rules
+func GraphemeCount(text string) int {
+	return uniseg.GraphemeClusterCount(text)
+}
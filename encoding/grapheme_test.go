@@ -0,0 +1,119 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphemeCount(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		text string
+		want int
+	}{
+		{
+			name: "empty text",
+			text: "",
+			want: 0,
+		},
+		{
+			name: "ascii",
+			text: "abc",
+			want: 3,
+		},
+		{
+			name: "base and combining mark",
+			text: "e\u0301",
+			want: 1,
+		},
+		{
+			name: "base and several combining marks",
+			text: "a\u0323\u0302b",
+			want: 2,
+		},
+		{
+			name: "family emoji zwj sequence",
+			text: "\U0001F468\u200D\U0001F469\u200D\U0001F467",
+			want: 1,
+		},
+		{
+			name: "emoji zwj sequence holding a variation selector",
+			text: "\u2764\uFE0F\u200D\U0001F525",
+			want: 1,
+		},
+		{
+			name: "zwj not preceded by an emoji",
+			text: "a\u200D\U0001F468",
+			want: 2,
+		},
+		{
+			name: "emoji and skin tone modifier",
+			text: "\U0001F44D\U0001F3FD",
+			want: 1,
+		},
+		{
+			name: "flags",
+			text: "\U0001F1EB\U0001F1F7\U0001F1E9\U0001F1EA\U0001F1EF",
+			want: 3,
+		},
+		{
+			name: "conjoining hangul jamo",
+			text: "\u1100\u1161\u11A8\uAC00\u11A8",
+			want: 2,
+		},
+		{
+			name: "crlf",
+			text: "a\r\nb\n\r",
+			want: 5,
+		},
+		{
+			name: "spacing mark",
+			text: "\u0915\u093F",
+			want: 1,
+		},
+		{
+			name: "combining mark following a control character",
+			text: "\n\u0301",
+			want: 2,
+		},
+		{
+			name: "prepended character",
+			text: "\u0600\u0661a",
+			want: 2,
+		},
+		{
+			name: "zwj joining arrows which are not pictographic",
+			text: "\u2B30\u200D\u2B30",
+			want: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, GraphemeCount(tc.text))
+		})
+	}
+}
+
+func TestGraphemeCountJS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const family = new TextDecoder().decode(new Uint8Array([
+			0xf0, 0x9f, 0x91, 0xa8, 0xe2, 0x80, 0x8d, 0xf0, 0x9f, 0x91, 0xa9, 0xe2, 0x80, 0x8d, 0xf0, 0x9f, 0x91, 0xa7,
+		]));
+		assert_equals(family.length, 8, "family emoji length in code units");
+		assert_equals(graphemeCount(family), 1, "family emoji grapheme count");
+		assert_equals(graphemeCount("cafe\u0301"), 4, "combining mark grapheme count");
+	`)
+	assert.NoError(t, err)
+}
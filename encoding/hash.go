@@ -0,0 +1,59 @@
+package encoding
+
+import (
+	"crypto/md5"  //nolint:gosec
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+)
+
+// HashAlgorithm is a type alias for the name of a hash algorithm, named
+// after the ones of the k6/crypto module.
+type HashAlgorithm = string
+
+const (
+	// HashMD5 is the MD5 hash algorithm, as defined in RFC 1321.
+	HashMD5 HashAlgorithm = "md5"
+
+	// HashSHA1 is the SHA-1 hash algorithm, as defined in RFC 3174.
+	HashSHA1 HashAlgorithm = "sha1"
+
+	// HashSHA256 is the SHA-256 hash algorithm, as defined in FIPS 180-4.
+	HashSHA256 HashAlgorithm = "sha256"
+
+	// HashSHA384 is the SHA-384 hash algorithm, as defined in FIPS 180-4.
+	HashSHA384 HashAlgorithm = "sha384"
+
+	// HashSHA512 is the SHA-512 hash algorithm, as defined in FIPS 180-4.
+	HashSHA512 HashAlgorithm = "sha512"
+
+	// HashSHA512_224 is the SHA-512/224 hash algorithm, as defined in FIPS 180-4.
+	HashSHA512_224 HashAlgorithm = "sha512_224"
+
+	// HashSHA512_256 is the SHA-512/256 hash algorithm, as defined in FIPS 180-4.
+	HashSHA512_256 HashAlgorithm = "sha512_256"
+)
+
+// NewHash returns a new hash computing the digest of the given algorithm.
+func NewHash(algorithm HashAlgorithm) (hash.Hash, error) {
+	switch algorithm {
+	case HashMD5:
+		return md5.New(), nil //nolint:gosec
+	case HashSHA1:
+		return sha1.New(), nil //nolint:gosec
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashSHA384:
+		return sha512.New384(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	case HashSHA512_224:
+		return sha512.New512_224(), nil
+	case HashSHA512_256:
+		return sha512.New512_256(), nil
+	default:
+		return nil, NewError(TypeError, fmt.Sprintf("unsupported hash algorithm: %s", algorithm))
+	}
+}
@@ -0,0 +1,255 @@
+package encoding
+
+import (
+	"bytes"
+	"strings"
+)
+
+// htmlPrescanSize is the number of leading bytes of an HTML document
+// prescanned for a character encoding declaration.
+const htmlPrescanSize = 1024
+
+// DecodeHTML decodes the given HTML document, using the encoding it
+// declares, the way browsers determine it when no transport layer
+// information is available.
+//
+// A byte order mark always wins. Otherwise, the first 1024 bytes of the
+// document are prescanned for a `<meta charset>` or `<meta http-equiv>`
+// declaration. Documents declaring neither are decoded using the default
+// encoding, windows-1252 unless specified otherwise through the options.
+func DecodeHTML(data []byte, options decodeHTMLOptions) (string, error) {
+	label, _ := sniffBOM(data)
+	if label == "" {
+		label = prescanHTML(data)
+	}
+
+	if label == "" {
+		label = options.DefaultEncoding
+	}
+
+	if label == "" {
+		label = Windows1252EncodingFormat
+	}
+
+	td, err := NewTextDecoder(nil, label, textDecoderOptions{Fatal: options.Fatal})
+	if err != nil {
+		return "", err
+	}
+
+	return td.Decode(data, decodeOptions{})
+}
+
+type decodeHTMLOptions struct {
+	// Fatal holds a boolean value indicating if decoding
+	// invalid data must throw a `TypeError`.
+	Fatal bool `js:"fatal"`
+
+	// DefaultEncoding holds the label of the encoding used to decode
+	// documents that do not declare their encoding. Defaults to windows-1252.
+	DefaultEncoding string `js:"defaultEncoding"`
+}
+
+// prescanHTML returns the canonical name of the encoding declared by the first
+// `<meta>` element of the given HTML document holding a supported one, or an
+// empty string if there is none.
+//
+// It implements a simplified version of the [prescan algorithm]: only the first
+// 1024 bytes are considered, comments are skipped, and other markup is ignored.
+//
+// [prescan algorithm]: https://html.spec.whatwg.org/multipage/parsing.html#prescan-a-byte-stream-to-determine-its-encoding
+func prescanHTML(data []byte) EncodingName {
+	if len(data) > htmlPrescanSize {
+		data = data[:htmlPrescanSize]
+	}
+
+	for i := 0; i < len(data); i++ {
+		switch {
+		case bytes.HasPrefix(data[i:], []byte("<!--")):
+			end := bytes.Index(data[i+2:], []byte("-->"))
+			if end < 0 {
+				return ""
+			}
+
+			i += 2 + end + 2
+		case i+len("<meta") < len(data) &&
+			bytes.EqualFold(data[i:i+len("<meta")], []byte("<meta")) &&
+			isHTMLSpaceOrSlash(data[i+len("<meta")]):
+			name, next := metaCharset(data, i+len("<meta"))
+			if name != "" {
+				return name
+			}
+
+			i = next
+		}
+	}
+
+	return ""
+}
+
+// metaCharset parses the attributes of the `<meta>` element starting at the
+// given position of data, and returns the canonical name of the encoding it
+// declares, if supported, along with the position its attributes end at.
+func metaCharset(data []byte, i int) (EncodingName, int) {
+	var (
+		seen       = make(map[string]bool)
+		gotPragma  bool
+		label      string
+		needPragma bool
+	)
+
+	for {
+		name, value, next, ok := nextHTMLAttribute(data, i)
+		if !ok {
+			i = next
+			break
+		}
+
+		i = next
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case "http-equiv":
+			gotPragma = value == "content-type"
+		case "content":
+			if label == "" {
+				label = charsetFromContent(value)
+				needPragma = true
+			}
+		case "charset":
+			if label == "" {
+				label = value
+				needPragma = false
+			}
+		}
+	}
+
+	if label == "" || (needPragma && !gotPragma) {
+		return "", i
+	}
+
+	entry, ok := lookupEncoding(label)
+	if !ok {
+		return "", i
+	}
+
+	// As per the specification, documents declaring utf-16
+	// are decoded as utf-8, as the declaration itself could
+	// not have been read otherwise.
+	switch entry.name {
+	case UTF16LEEncodingFormat, UTF16BEEncodingFormat:
+		return UTF8EncodingFormat, i
+	case XUserDefinedEncodingFormat:
+		return Windows1252EncodingFormat, i
+	default:
+		return entry.name, i
+	}
+}
+
+// nextHTMLAttribute parses the attribute starting at the given position of data,
+// and returns its lowercased name and value, along with the position following it.
+//
+// If the element's attributes end at the given position, ok is false.
+func nextHTMLAttribute(data []byte, i int) (name, value string, next int, ok bool) {
+	for i < len(data) && isHTMLSpaceOrSlash(data[i]) {
+		i++
+	}
+
+	if i >= len(data) || data[i] == '>' {
+		return "", "", i, false
+	}
+
+	start := i
+	for i < len(data) && data[i] != '=' && data[i] != '>' && !isHTMLSpaceOrSlash(data[i]) {
+		i++
+	}
+	name = strings.ToLower(string(data[start:i]))
+
+	for i < len(data) && isHTMLSpace(data[i]) {
+		i++
+	}
+
+	if i >= len(data) || data[i] != '=' {
+		return name, "", i, true
+	}
+	i++
+
+	for i < len(data) && isHTMLSpace(data[i]) {
+		i++
+	}
+
+	if i < len(data) && (data[i] == '"' || data[i] == '\'') {
+		quote := data[i]
+		i++
+
+		start = i
+		for i < len(data) && data[i] != quote {
+			i++
+		}
+		value = strings.ToLower(string(data[start:i]))
+
+		if i < len(data) {
+			i++
+		}
+
+		return name, value, i, true
+	}
+
+	start = i
+	for i < len(data) && data[i] != '>' && !isHTMLSpace(data[i]) {
+		i++
+	}
+
+	return name, strings.ToLower(string(data[start:i])), i, true
+}
+
+// charsetFromContent extracts the encoding label from the value of
+// the content attribute of a `<meta http-equiv>` element, such as
+// "text/html; charset=utf-8", as per the [specification].
+//
+// [specification]: https://html.spec.whatwg.org/multipage/urls-and-fetching.html#algorithm-for-extracting-a-character-encoding-from-a-meta-element
+func charsetFromContent(content string) string {
+	for {
+		i := strings.Index(content, "charset")
+		if i < 0 {
+			return ""
+		}
+
+		content = strings.TrimLeft(content[i+len("charset"):], "\t\n\f\r ")
+		if strings.HasPrefix(content, "=") {
+			break
+		}
+	}
+
+	content = strings.TrimLeft(content[1:], "\t\n\f\r ")
+	if content == "" {
+		return ""
+	}
+
+	if quote := content[0]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(content[1:], quote)
+		if end < 0 {
+			return ""
+		}
+
+		return content[1 : end+1]
+	}
+
+	if end := strings.IndexAny(content, "\t\n\f\r ;"); end >= 0 {
+		return content[:end]
+	}
+
+	return content
+}
+
+// isHTMLSpace returns true if the given byte is an ASCII whitespace, as defined by HTML.
+func isHTMLSpace(c byte) bool {
+	return c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+// isHTMLSpaceOrSlash returns true if the given byte is an ASCII whitespace or a slash.
+func isHTMLSpaceOrSlash(c byte) bool {
+	return isHTMLSpace(c) || c == '/'
+}
@@ -0,0 +1,116 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeHTML(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		options decodeHTMLOptions
+		want    string
+	}{
+		{
+			name: "utf-8 byte order mark",
+			data: []byte("\xef\xbb\xbf<meta charset=windows-1252>caf\xc3\xa9"),
+			want: "<meta charset=windows-1252>café",
+		},
+		{
+			name: "utf-16le byte order mark",
+			data: []byte{0xFF, 0xFE, 0x61, 0x00, 0xE9, 0x00},
+			want: "aé",
+		},
+		{
+			name: "meta charset",
+			data: []byte(`<html><head><META Charset="UTF-8"></head>caf` + "\xc3\xa9"),
+			want: `<html><head><META Charset="UTF-8"></head>caf` + "é",
+		},
+		{
+			name: "meta http-equiv",
+			data: []byte(`<meta http-equiv="Content-Type" content="text/html; charset=windows-1250">` + "\x8a"),
+			want: `<meta http-equiv="Content-Type" content="text/html; charset=windows-1250">` + "Š",
+		},
+		{
+			name: "meta content without http-equiv",
+			data: []byte(`<meta content="text/html; charset=utf-8">caf` + "\xc3\xa9"),
+			want: `<meta content="text/html; charset=utf-8">caf` + "Ã©",
+		},
+		{
+			name: "meta content preceding a charset attribute",
+			data: []byte(`<meta content="text/html; charset=utf-8" charset="windows-1252" http-equiv="content-type">caf` + "\xc3\xa9"),
+			want: `<meta content="text/html; charset=utf-8" charset="windows-1252" http-equiv="content-type">caf` + "\u00e9",
+		},
+		{
+			name: "meta charset in a comment",
+			data: []byte(`<!-- <meta charset="utf-8"> -->caf` + "\xe9"),
+			want: `<!-- <meta charset="utf-8"> -->caf` + "é",
+		},
+		{
+			name: "meta charset declaring utf-16",
+			data: []byte(`<meta charset="utf-16">caf` + "\xc3\xa9"),
+			want: `<meta charset="utf-16">caf` + "é",
+		},
+		{
+			name: "unsupported meta charset followed by a supported one",
+			data: []byte(`<meta charset="not-an-encoding"><meta charset="utf-8">` + "\xc3\xa9"),
+			want: `<meta charset="not-an-encoding"><meta charset="utf-8">` + "é",
+		},
+		{
+			name: "no declaration",
+			data: []byte("<p>caf\xe9</p>"),
+			want: "<p>café</p>",
+		},
+		{
+			name:    "no declaration with a default encoding",
+			data:    []byte("<p>caf\xc3\xa9</p>"),
+			options: decodeHTMLOptions{DefaultEncoding: UTF8EncodingFormat},
+			want:    "<p>café</p>",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoded, err := DecodeHTML(tc.data, tc.options)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+		})
+	}
+}
+
+func TestPrescanHTMLOnlyConsidersLeadingBytes(t *testing.T) {
+	t.Parallel()
+
+	padding := make([]byte, htmlPrescanSize)
+	for i := range padding {
+		padding[i] = ' '
+	}
+
+	assert.Equal(t, "", prescanHTML(append(padding, []byte(`<meta charset="utf-8">`)...)))
+}
+
+func TestDecodeHTMLJS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const html = new Uint8Array([
+			0x3c, 0x6d, 0x65, 0x74, 0x61, 0x20, 0x63, 0x68, 0x61, 0x72, 0x73, 0x65, 0x74, 0x3d,
+			0x75, 0x74, 0x66, 0x2d, 0x38, 0x3e, 0xc3, 0xa9,
+		]);
+		assert_equals(decodeHTML(html), "<meta charset=utf-8>é");
+		assert_equals(decodeHTML(new Uint8Array([0xe9]).buffer), "é");
+		assert_equals(decodeHTML(new Uint8Array([0xc3, 0xa9]), { defaultEncoding: "utf-8" }), "é");
+	`)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,142 @@
+package encoding
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// DecodeJSON decodes the given data using the encoding the given label
+// resolves to, parses the decoded text as JSON, and returns the parsed value
+// as the JS value JSON.parse would return.
+//
+// The text is parsed in Go, sparing the creation of the JS string holding
+// it. As the decoder strips the byte order mark the data starts with, unless
+// told to ignore it, JSON payloads starting with one are parsed as browsers
+// do, rather than rejected as JSON would have it.
+//
+// Unlike with the JSON.parse of browsers, escaped lone surrogates, such as
+// "\ud800", are parsed to the replacement character U+FFFD, as the Go strings
+// the JS strings are built from cannot hold them. This matches the JSON.parse
+// of the goja runtime, which parses the text in Go as well.
+func DecodeJSON(rt *goja.Runtime, data []byte, label string, options textDecoderOptions) (goja.Value, error) {
+	td, err := NewTextDecoder(rt, label, options)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := td.Decode(data, decodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(decoded))
+	dec.UseNumber()
+
+	value, err := parseJSONValue(rt, dec)
+	if err != nil {
+		return nil, NewError(SyntaxError, "unable to parse JSON; reason: "+err.Error())
+	}
+
+	// The text must hold a single JSON value
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, NewError(SyntaxError, "unable to parse JSON; reason: unexpected data following the JSON value")
+	}
+
+	return value, nil
+}
+
+// parseJSONValue parses the next JSON value the given decoder reads into a JS
+// value, building objects with their properties in the order they are read,
+// as JSON.parse does.
+func parseJSONValue(rt *goja.Runtime, dec *json.Decoder) (goja.Value, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			return parseJSONObject(rt, dec)
+		case '[':
+			return parseJSONArray(rt, dec)
+		default:
+			return nil, fmt.Errorf("unexpected delimiter: %s", t)
+		}
+	case json.Number:
+		// The number's syntax was checked already, only numbers out of the
+		// float64 range fail to parse, to an infinity, as with JSON.parse.
+		f, _ := strconv.ParseFloat(t.String(), 64)
+
+		return rt.ToValue(f), nil
+	case nil:
+		return goja.Null(), nil
+	default:
+		return rt.ToValue(t), nil
+	}
+}
+
+// parseJSONObject parses the members of the JSON object whose opening
+// delimiter the given decoder just read, into a JS object.
+func parseJSONObject(rt *goja.Runtime, dec *json.Decoder) (goja.Value, error) {
+	obj := rt.NewObject()
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object key: %v", token)
+		}
+
+		value, err := parseJSONValue(rt, dec)
+		if err != nil {
+			return nil, err
+		}
+
+		// Members are defined rather than set, so that a "__proto__"
+		// key defines an own property, as it does with JSON.parse.
+		if err := obj.DefineDataProperty(key, value, goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_TRUE); err != nil {
+			return nil, err
+		}
+	}
+
+	// Consume the closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// parseJSONArray parses the elements of the JSON array whose opening
+// delimiter the given decoder just read, into a JS array.
+func parseJSONArray(rt *goja.Runtime, dec *json.Decoder) (goja.Value, error) {
+	var values []interface{}
+
+	for dec.More() {
+		value, err := parseJSONValue(rt, dec)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	// Consume the closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return rt.NewArray(values...), nil
+}
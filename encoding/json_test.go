@@ -0,0 +1,138 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		data      []byte
+		label     string
+		options   textDecoderOptions
+		want      string
+		wantError ErrorName
+	}{
+		{
+			name:  "utf-8 object",
+			data:  []byte("{\"b\": [1, 2.5, true, null], \"a\": \"caf\u00E9\"}"),
+			label: "utf-8",
+			want:  "{\"b\":[1,2.5,true,null],\"a\":\"caf\u00E9\"}",
+		},
+		{
+			name:  "utf-8 with a byte order mark",
+			data:  append([]byte{0xEF, 0xBB, 0xBF}, `[1]`...),
+			label: "utf-8",
+			want:  `[1]`,
+		},
+		{
+			name:  "utf-16le with a byte order mark",
+			data:  []byte{0xFF, 0xFE, '{', 0x00, '"', 0x00, 'k', 0x00, '"', 0x00, ':', 0x00, '"', 0x00, 0xE9, 0x00, '"', 0x00, '}', 0x00},
+			label: "utf-16le",
+			want:  "{\"k\":\"\u00E9\"}",
+		},
+		{
+			name:  "scalar",
+			data:  []byte(` "text" `),
+			label: "utf-8",
+			want:  `"text"`,
+		},
+		{
+			name:  "escaped surrogate pair",
+			data:  []byte(`["\ud83d\ude00"]`),
+			label: "utf-8",
+			want:  "[\"\U0001F600\"]",
+		},
+		{
+			name:  "escaped lone surrogate substituted",
+			data:  []byte(`["a\ud800b"]`),
+			label: "utf-8",
+			want:  "[\"a\uFFFDb\"]",
+		},
+		{
+			name:      "byte order mark kept with ignoreBOM",
+			data:      append([]byte{0xEF, 0xBB, 0xBF}, `[1]`...),
+			label:     "utf-8",
+			options:   textDecoderOptions{IgnoreBOM: true},
+			wantError: SyntaxError,
+		},
+		{
+			name:      "invalid JSON",
+			data:      []byte(`{"a": }`),
+			label:     "utf-8",
+			wantError: SyntaxError,
+		},
+		{
+			name:      "trailing data",
+			data:      []byte(`{} {}`),
+			label:     "utf-8",
+			wantError: SyntaxError,
+		},
+		{
+			name:      "unsupported label",
+			data:      []byte(`{}`),
+			label:     "bogus-label",
+			wantError: RangeError,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := goja.New()
+
+			value, err := DecodeJSON(rt, tc.data, tc.label, tc.options)
+			if tc.wantError != "" {
+				assert.ErrorContains(t, err, tc.wantError)
+				return
+			}
+
+			require.NoError(t, err)
+
+			stringify, ok := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("stringify"))
+			require.True(t, ok)
+
+			got, err := stringify(goja.Undefined(), value)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got.String())
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const payload = decodeJSON(new TextEncoder().encode('{"b": [1, {"c": null}], "a": 1e400}'), "utf-8");
+			assert_true(Array.isArray(payload.b), "arrays should be JS arrays");
+			assert_equals(payload.b[1].c, null, "nested value");
+			assert_equals(payload.a, Infinity, "numbers out of range should be infinite");
+			assert_equals(Object.keys(payload).join(), "b,a", "keys should keep their order");
+
+			const utf16 = new Uint8Array([0xff, 0xfe, 0x5b, 0x00, 0x31, 0x00, 0x5d, 0x00]);
+			assert_equals(decodeJSON(utf16, "utf-16le")[0], 1, "utf-16le payload");
+
+			const loneSurrogate = '["\\ud800"]';
+			assert_equals(decodeJSON(new TextEncoder().encode(loneSurrogate), "utf-8")[0], JSON.parse(loneSurrogate)[0], "lone surrogates should parse as with JSON.parse");
+
+			let error;
+			try {
+				decodeJSON(new TextEncoder().encode("{"), "utf-8");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof SyntaxError, "invalid JSON should throw a SyntaxError");
+		`)
+		assert.NoError(t, err)
+	})
+}
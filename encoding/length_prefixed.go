@@ -0,0 +1,105 @@
+package encoding
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// DecodeLengthPrefixed reads the length prefix the given data starts with,
+// and decodes the number of bytes it holds, following it, using the encoding
+// the text label resolves to.
+//
+// It returns the decoded text, along with the number of bytes consumed, the
+// prefix included, so that the caller can carry on parsing the data from
+// there. The length prefix counts bytes, not characters nor code units.
+func DecodeLengthPrefixed(data []byte, options decodeLengthPrefixedOptions) (string, int, error) {
+	var order binary.ByteOrder
+	switch options.PrefixEndian {
+	case "", PrefixLittleEndian:
+		order = binary.LittleEndian
+	case PrefixBigEndian:
+		order = binary.BigEndian
+	default:
+		return "", 0, NewError(TypeError, fmt.Sprintf("unsupported prefix endianness: %s", options.PrefixEndian))
+	}
+
+	prefixBytes := options.PrefixBytes
+	if prefixBytes == 0 {
+		prefixBytes = 2
+	}
+
+	if len(data) < prefixBytes {
+		return "", 0, NewError(RangeError, fmt.Sprintf(
+			"unable to read the length prefix; reason: %d bytes are available, out of %d", len(data), prefixBytes,
+		))
+	}
+
+	var length uint64
+	switch prefixBytes {
+	case 1:
+		length = uint64(data[0])
+	case 2:
+		length = uint64(order.Uint16(data))
+	case 4:
+		length = uint64(order.Uint32(data))
+	default:
+		return "", 0, NewError(RangeError, fmt.Sprintf("unsupported prefix size: %d bytes", prefixBytes))
+	}
+
+	if length > uint64(len(data)-prefixBytes) {
+		return "", 0, NewError(RangeError, fmt.Sprintf(
+			"unable to decode the length prefixed text; reason: %d bytes are announced, %d are available",
+			length, len(data)-prefixBytes,
+		))
+	}
+
+	label := options.TextLabel
+	if label == "" {
+		label = UTF16LEEncodingFormat
+	}
+
+	td, err := NewTextDecoder(nil, label, textDecoderOptions{})
+	if err != nil {
+		return "", 0, err
+	}
+
+	end := prefixBytes + int(length)
+
+	decoded, err := td.Decode(data[prefixBytes:end], decodeOptions{})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return decoded, end, nil
+}
+
+type decodeLengthPrefixedOptions struct {
+	// PrefixBytes holds the size of the length prefix, in bytes,
+	// either 1, 2 or 4.
+	//
+	// It defaults to 2.
+	PrefixBytes int `js:"prefixBytes"`
+
+	// PrefixEndian holds the byte order of the length prefix,
+	// either "le" or "be".
+	//
+	// It defaults to "le".
+	PrefixEndian PrefixEndianness `js:"prefixEndian"`
+
+	// TextLabel holds the label of the encoding
+	// the text following the prefix is decoded with.
+	//
+	// It defaults to "utf-16le".
+	TextLabel string `js:"textLabel"`
+}
+
+// PrefixEndianness is a type alias for the byte order of a length prefix.
+type PrefixEndianness = string
+
+const (
+	// PrefixLittleEndian reads length prefixes in little-endian order.
+	PrefixLittleEndian PrefixEndianness = "le"
+
+	// PrefixBigEndian reads length prefixes in big-endian order.
+	PrefixBigEndian PrefixEndianness = "be"
+)
@@ -0,0 +1,136 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeLengthPrefixed(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		options      decodeLengthPrefixedOptions
+		want         string
+		wantConsumed int
+	}{
+		{
+			name:         "2-byte little-endian prefix",
+			data:         []byte{0x04, 0x00, 0x61, 0x00, 0x34, 0x6C, 0xFF},
+			options:      decodeLengthPrefixedOptions{PrefixBytes: 2, PrefixEndian: PrefixLittleEndian, TextLabel: "utf-16le"},
+			want:         "a\u6C34",
+			wantConsumed: 6,
+		},
+		{
+			name:         "4-byte big-endian prefix",
+			data:         []byte{0x00, 0x00, 0x00, 0x06, 0x61, 0x00, 0x3D, 0xD8, 0x00, 0xDE, 0x62, 0x00},
+			options:      decodeLengthPrefixedOptions{PrefixBytes: 4, PrefixEndian: PrefixBigEndian, TextLabel: "utf-16le"},
+			want:         "a\U0001F600",
+			wantConsumed: 10,
+		},
+		{
+			name:         "1-byte prefix",
+			data:         []byte{0x02, 0x00, 0x61, 0x00},
+			options:      decodeLengthPrefixedOptions{PrefixBytes: 1, TextLabel: "utf-16be"},
+			want:         "a",
+			wantConsumed: 3,
+		},
+		{
+			name:         "defaults",
+			data:         []byte{0x02, 0x00, 0x61, 0x00},
+			want:         "a",
+			wantConsumed: 4,
+		},
+		{
+			name:         "empty text",
+			data:         []byte{0x00, 0x00, 0x61},
+			want:         "",
+			wantConsumed: 2,
+		},
+		{
+			name:         "utf-8 text",
+			data:         []byte{0x00, 0x03, 0xE6, 0xB0, 0xB4},
+			options:      decodeLengthPrefixedOptions{PrefixEndian: PrefixBigEndian, TextLabel: "utf-8"},
+			want:         "\u6C34",
+			wantConsumed: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoded, consumed, err := DecodeLengthPrefixed(tc.data, tc.options)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+			assert.Equal(t, tc.wantConsumed, consumed)
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			name    string
+			data    []byte
+			options decodeLengthPrefixedOptions
+			want    ErrorName
+		}{
+			{name: "truncated prefix", data: []byte{0x00, 0x00, 0x00}, options: decodeLengthPrefixedOptions{PrefixBytes: 4}, want: RangeError},
+			{name: "truncated text", data: []byte{0x04, 0x00, 0x61, 0x00}, want: RangeError},
+			{name: "unsupported prefix size", data: []byte{0x00, 0x00, 0x00}, options: decodeLengthPrefixedOptions{PrefixBytes: 3}, want: RangeError},
+			{name: "unsupported endianness", data: []byte{0x00, 0x00}, options: decodeLengthPrefixedOptions{PrefixEndian: "middle"}, want: TypeError},
+			{name: "unsupported label", data: []byte{0x00, 0x00}, options: decodeLengthPrefixedOptions{TextLabel: "bogus-label"}, want: RangeError},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				_, _, err := DecodeLengthPrefixed(tc.data, tc.options)
+
+				var encodingErr *Error
+				require.ErrorAs(t, err, &encodingErr)
+				assert.Equal(t, tc.want, encodingErr.Name)
+			})
+		}
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			// Two records, each a 2-byte little-endian prefix followed by UTF-16LE text
+			const data = new Uint8Array([0x02, 0x00, 0x61, 0x00, 0x04, 0x00, 0x62, 0x00, 0x34, 0x6c]);
+
+			const first = decodeLengthPrefixed(data, { prefixBytes: 2, prefixEndian: "le", textLabel: "utf-16le" });
+			assert_equals(first.value, "a");
+			assert_equals(first.bytesConsumed, 4);
+
+			const second = decodeLengthPrefixed(data.subarray(first.bytesConsumed));
+			assert_equals(second.value, "b\u6c34", "the defaults should read a 2-byte little-endian prefix followed by utf-16le");
+			assert_equals(second.bytesConsumed, 6);
+
+			const bigEndian = new Uint8Array([0x00, 0x00, 0x00, 0x02, 0x63, 0x00]);
+			assert_equals(decodeLengthPrefixed(bigEndian, { prefixBytes: 4, prefixEndian: "be" }).value, "c");
+
+			let error;
+			try {
+				decodeLengthPrefixed(new Uint8Array([0x08, 0x00, 0x61, 0x00]));
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof RangeError, "a length exceeding the data should throw a RangeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,49 @@
+package encoding
+
+import "strings"
+
+// DecodeLines decodes the given data using the encoding the given label
+// resolves to, and returns the decoded text split into lines.
+//
+// Lines are split on line feed characters, once the data is decoded, so that
+// bytes of multi-byte sequences are never mistaken for line feeds. As with
+// JavaScript's `String.prototype.split`, text ending with a line feed yields
+// a trailing empty line.
+func DecodeLines(data []byte, label string, options decodeLinesOptions) ([]string, error) {
+	td, err := NewTextDecoder(nil, label, textDecoderOptions{
+		Fatal:     options.Fatal,
+		IgnoreBOM: options.IgnoreBOM,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := td.Decode(data, decodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(decoded, "\n")
+	if options.TrimCR {
+		for i, line := range lines {
+			lines[i] = strings.TrimSuffix(line, "\r")
+		}
+	}
+
+	return lines, nil
+}
+
+type decodeLinesOptions struct {
+	// Fatal holds a boolean value indicating if decoding
+	// invalid data must throw a `TypeError`.
+	Fatal bool `js:"fatal"`
+
+	// IgnoreBOM holds a boolean value indicating
+	// whether the byte order mark is ignored.
+	IgnoreBOM bool `js:"ignoreBOM"`
+
+	// TrimCR holds a boolean value indicating whether
+	// the carriage return character ending a line, as in
+	// CRLF line endings, is trimmed from it.
+	TrimCR bool `js:"trimCR"`
+}
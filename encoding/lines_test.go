@@ -0,0 +1,91 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeLines(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		label   string
+		options decodeLinesOptions
+		want    []string
+	}{
+		{
+			name:  "utf-8 with LF line endings",
+			data:  []byte("caf\xc3\xa9\nna\xc3\xafve\n"),
+			label: UTF8EncodingFormat,
+			want:  []string{"café", "naïve", ""},
+		},
+		{
+			name:  "utf-8 with CRLF line endings",
+			data:  []byte("caf\xc3\xa9\r\nna\xc3\xafve"),
+			label: UTF8EncodingFormat,
+			want:  []string{"café\r", "naïve"},
+		},
+		{
+			name:    "utf-8 with CRLF line endings trimmed",
+			data:    []byte("caf\xc3\xa9\r\nna\xc3\xafve"),
+			label:   UTF8EncodingFormat,
+			options: decodeLinesOptions{TrimCR: true},
+			want:    []string{"café", "naïve"},
+		},
+		{
+			name:  "windows-1252 with LF line endings",
+			data:  []byte("caf\xe9\nna\xefve"),
+			label: Windows1252EncodingFormat,
+			want:  []string{"café", "naïve"},
+		},
+		{
+			name:    "windows-1252 with CRLF line endings trimmed",
+			data:    []byte("caf\xe9\r\nna\xefve\r\n"),
+			label:   Windows1252EncodingFormat,
+			options: decodeLinesOptions{TrimCR: true},
+			want:    []string{"café", "naïve", ""},
+		},
+		{
+			name:  "utf-16le with a code unit holding a LF byte",
+			data:  []byte{0x0A, 0x01, 0x0A, 0x00, 0x61, 0x00},
+			label: UTF16LEEncodingFormat,
+			want:  []string{"\u010A", "a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			lines, err := DecodeLines(tc.data, tc.label, tc.options)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, lines)
+		})
+	}
+}
+
+func TestDecodeLinesJS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const lines = decodeLines(new Uint8Array([0x61, 0x0d, 0x0a, 0xe9]), "windows-1252", { trimCR: true });
+		assert_true(Array.isArray(lines), "decodeLines should return an array");
+		assert_equals(lines.length, 2, "lines count");
+		assert_equals(lines[0], "a", "first line");
+		assert_equals(lines[1], "\u00e9", "second line");
+
+		const defaults = decodeLines(new Uint8Array([0x61, 0x0d, 0x0a, 0x62]));
+		assert_equals(defaults.length, 2, "options should be optional");
+		assert_equals(defaults[0], "a\r", "carriage returns should be kept by default");
+		assert_equals(defaults[1], "b");
+	`)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,174 @@
+package encoding
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+// encodingMetrics holds the custom metrics the module emits, once enabled,
+// on behalf of the TextDecoder and TextEncoder objects of a VU.
+type encodingMetrics struct {
+	vu modules.VU
+
+	// enabled indicates whether the metrics are emitted, as
+	// set by the module options, once optionsRead is true.
+	enabled     bool
+	optionsRead bool
+
+	// BytesDecoded counts the bytes decoded by TextDecoder objects.
+	BytesDecoded *metrics.Metric
+
+	// DecodeErrors counts the malformed sequences TextDecoder objects
+	// substituted with replacement characters, and the decode calls
+	// which threw.
+	DecodeErrors *metrics.Metric
+
+	// BytesEncoded counts the bytes encoded by TextEncoder objects.
+	BytesEncoded *metrics.Metric
+}
+
+// moduleOptions holds the options of the module, which scripts set under
+// the "encoding" key of the ext options:
+//
+//	export const options = { ext: { encoding: { metrics: true } } };
+type moduleOptions struct {
+	// Metrics holds whether the custom metrics are emitted.
+	//
+	// It defaults to false.
+	Metrics bool `json:"metrics"`
+}
+
+// moduleOptionsKey holds the key of the ext options holding the module options.
+const moduleOptionsKey = "encoding"
+
+const (
+	// BytesDecodedMetricName is the name of the metric counting the bytes decoded.
+	BytesDecodedMetricName = "encoding_bytes_decoded"
+
+	// DecodeErrorsMetricName is the name of the metric counting the decode errors.
+	DecodeErrorsMetricName = "encoding_decode_errors"
+
+	// BytesEncodedMetricName is the name of the metric counting the bytes encoded.
+	BytesEncodedMetricName = "encoding_bytes_encoded"
+)
+
+// registerMetrics registers the module's custom metrics in the given registry,
+// and returns them, ready to be emitted on behalf of the given VU.
+//
+// Whether they are emitted is only decided when the first sample is pushed,
+// the module options being unknown in the init context.
+func registerMetrics(vu modules.VU, registry *metrics.Registry) (*encodingMetrics, error) {
+	m := &encodingMetrics{vu: vu}
+
+	var err error
+	if m.BytesDecoded, err = registry.NewMetric(BytesDecodedMetricName, metrics.Counter, metrics.Data); err != nil {
+		return nil, err
+	}
+
+	if m.DecodeErrors, err = registry.NewMetric(DecodeErrorsMetricName, metrics.Counter); err != nil {
+		return nil, err
+	}
+
+	if m.BytesEncoded, err = registry.NewMetric(BytesEncodedMetricName, metrics.Counter, metrics.Data); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// decoded records a decode call, which decoded the given number of
+// bytes, substituting the given number of malformed sequences.
+func (m *encodingMetrics) decoded(bytes, substitutions int) {
+	if m == nil {
+		return
+	}
+
+	m.push(m.BytesDecoded, float64(bytes))
+	if substitutions > 0 {
+		m.push(m.DecodeErrors, float64(substitutions))
+	}
+}
+
+// decodeFailed records a decode call which threw.
+func (m *encodingMetrics) decodeFailed() {
+	if m == nil {
+		return
+	}
+
+	m.push(m.DecodeErrors, 1)
+}
+
+// encoded records an encode call, which encoded the given number of bytes.
+func (m *encodingMetrics) encoded(bytes int) {
+	if m == nil {
+		return
+	}
+
+	m.push(m.BytesEncoded, float64(bytes))
+}
+
+// push emits a sample of the given metric, holding the given value, tagged
+// with the current tags of the VU.
+//
+// Nothing is emitted unless the module options enable the metrics, nor in
+// the init context, where no samples can be emitted.
+func (m *encodingMetrics) push(metric *metrics.Metric, value float64) {
+	state := m.vu.State()
+	if state == nil {
+		return
+	}
+
+	if !m.optionsRead {
+		options, err := parseModuleOptions(state.Options)
+		if err != nil {
+			throw(m.vu.Runtime(), err)
+		}
+
+		m.enabled = options.Metrics
+		m.optionsRead = true
+	}
+
+	if !m.enabled {
+		return
+	}
+
+	ctm := state.Tags.GetCurrentValues()
+	metrics.PushIfNotDone(m.vu.Context(), state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: metric,
+			Tags:   ctm.Tags,
+		},
+		Time:     time.Now(),
+		Metadata: ctm.Metadata,
+		Value:    value,
+	})
+}
+
+// parseModuleOptions returns the module options the given script options
+// hold, under the "encoding" key of their ext options, if any.
+func parseModuleOptions(options lib.Options) (moduleOptions, error) {
+	var parsed moduleOptions
+
+	raw, ok := options.External[moduleOptionsKey]
+	if !ok {
+		return parsed, nil
+	}
+
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return parsed, NewError(TypeError, "invalid ext."+moduleOptionsKey+" options; reason: "+err.Error())
+	}
+
+	return parsed, nil
+}
+
+// base64DecodedLen returns the number of bytes the given base64 text, of any
+// of the supported variants, decodes to.
+func base64DecodedLen(encoded string) int {
+	return base64.RawStdEncoding.DecodedLen(len(strings.TrimRight(encoded, "=")))
+}
@@ -0,0 +1,127 @@
+package encoding
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+)
+
+func TestMetricsJS(t *testing.T) {
+	t.Parallel()
+
+	t.Run("enabled metrics are emitted on decode and encode", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		ts.state.Options.External = map[string]json.RawMessage{"encoding": json.RawMessage(`{"metrics": true}`)}
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder();
+			decoder.decode(new Uint8Array([0x68, 0x69, 0xff]));
+
+			const fatalDecoder = new TextDecoder("utf-16le", { fatal: true });
+			let error;
+			try {
+				fatalDecoder.decode(new Uint8Array([0x68]));
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "a truncated code unit should throw a TypeError");
+
+			const encoder = new TextEncoder();
+			encoder.encode("hé");
+			encoder.encodeInto("hi", new Uint8Array(8));
+		`)
+		require.NoError(t, err)
+
+		got := sumSamples(ts.samples)
+		assert.Equal(t, 3.0, got[BytesDecodedMetricName])
+		assert.Equal(t, 2.0, got[DecodeErrorsMetricName])
+		assert.Equal(t, 5.0, got[BytesEncodedMetricName])
+	})
+
+	t.Run("chunked decodes count their substitutions", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		ts.state.Options.External = map[string]json.RawMessage{"encoding": json.RawMessage(`{"metrics": true}`)}
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder();
+			decoder.decodeAll([new Uint8Array([0x68, 0xff]), new Uint8Array([0xfe])]);
+			decoder.decodeWithCallback(new Uint8Array([0x68, 0x69, 0xff]), () => {}, { chunkSize: 1 });
+
+			let error;
+			try {
+				decoder.decodeWithCallback(new Uint8Array([0x68]), () => { throw new Error("stop"); });
+			} catch (e) {
+				error = e;
+			}
+			assert_equals(error.message, "stop", "errors thrown by the callback should propagate");
+		`)
+		require.NoError(t, err)
+
+		got := sumSamples(ts.samples)
+		assert.Equal(t, 6.0, got[BytesDecodedMetricName])
+		assert.Equal(t, 3.0, got[DecodeErrorsMetricName], "callback errors should not count as decode errors")
+	})
+
+	t.Run("metrics are not emitted unless enabled", func(t *testing.T) {
+		t.Parallel()
+
+		for _, external := range []map[string]json.RawMessage{
+			nil,
+			{"encoding": json.RawMessage(`{}`)},
+			{"encoding": json.RawMessage(`{"metrics": false}`)},
+		} {
+			ts := newTestSetup(t)
+			ts.state.Options.External = external
+
+			_, err := ts.rt.RunString(`
+				new TextDecoder().decode(new Uint8Array([0x68, 0x69]));
+				new TextEncoder().encode("hi");
+			`)
+			require.NoError(t, err)
+
+			assert.Empty(t, sumSamples(ts.samples))
+		}
+	})
+
+	t.Run("invalid module options throw", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		ts.state.Options.External = map[string]json.RawMessage{"encoding": json.RawMessage(`{"metrics": "yes"}`)}
+
+		_, err := ts.rt.RunString(`
+			let error;
+			try {
+				new TextEncoder().encode("hi");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "invalid module options should throw a TypeError");
+		`)
+		require.NoError(t, err)
+	})
+}
+
+// sumSamples drains the given samples channel, and returns the
+// sum of the values of the samples emitted, by metric name.
+func sumSamples(samples chan metrics.SampleContainer) map[string]float64 {
+	sums := make(map[string]float64)
+
+	for {
+		select {
+		case container := <-samples:
+			for _, sample := range container.GetSamples() {
+				sums[sample.Metric.Name] += sample.Value
+			}
+		default:
+			return sums
+		}
+	}
+}
@@ -3,7 +3,12 @@
 package encoding
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
 
 	"github.com/dop251/goja"
 	"go.k6.io/k6/js/common"
@@ -19,6 +24,14 @@ type (
 	ModuleInstance struct {
 		vu modules.VU
 
+		// metrics holds the custom metrics emitted on behalf of the
+		// TextDecoder and TextEncoder objects of the VU, if any.
+		metrics *encodingMetrics
+
+		// pooled holds the TextDecoder instances backing the objects
+		// getDecoder returned, until they are released.
+		pooled map[*goja.Object]*TextDecoder
+
 		*TextDecoder
 		*TextEncoder
 	}
@@ -40,51 +53,686 @@ func New() *RootModule {
 func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	vu.Runtime().SetFieldNameMapper(goja.TagFieldNameMapper("js", true))
 
-	return &ModuleInstance{
+	mi := &ModuleInstance{
 		vu:          vu,
 		TextDecoder: &TextDecoder{},
 		TextEncoder: &TextEncoder{},
 	}
+
+	// Metrics can only be registered when a registry is available.
+	//
+	// They are registered regardless of the metrics module option, which is not
+	// known yet when the module is imported, while k6 requires the metrics
+	// thresholds refer to be registered by the end of the init context.
+	// Registering emits nothing though, outputs and the end-of-test summary
+	// only seeing the metrics samples are pushed for, or thresholds are set on.
+	if initEnv := vu.InitEnv(); initEnv != nil && initEnv.TestPreInitState != nil && initEnv.Registry != nil {
+		m, err := registerMetrics(vu, initEnv.Registry)
+		if err != nil {
+			throw(vu.Runtime(), err)
+		}
+
+		mi.metrics = m
+	}
+
+	return mi
 }
 
 // Exports implements the modules.Instance interface and returns
 // the exports of the JS module.
 func (mi *ModuleInstance) Exports() modules.Exports {
 	return modules.Exports{Named: map[string]interface{}{
-		"TextDecoder": mi.NewTextDecoder,
-		"TextEncoder": mi.NewTextEncoder,
+		"TextDecoder":       mi.NewTextDecoder,
+		"TextEncoder":       mi.NewTextEncoder,
+		"TextEncoderStream": mi.NewTextEncoderStream,
+		"byteLength":        mi.ByteLength,
+		"canonicalName":     mi.CanonicalName,
+		"compareBytes":      mi.CompareBytes,
+		"concatDecode":      mi.ConcatDecode,
+		"decodeAsync":       mi.DecodeAsync,
+		"decodeHTML":        mi.DecodeHTML,
+		"decodeJSON":        mi.DecodeJSON,
+		"decodeLines":       mi.DecodeLines,
+		"decodeOrFallback":  mi.DecodeOrFallback,
+		"decodeReader":      mi.DecodeReader,
+		"getDecoder":        mi.GetDecoder,
+		"graphemeCount":     mi.GraphemeCount,
+		"isValidUTF8":       mi.IsValidUTF8,
+		"labelsFor":         mi.LabelsFor,
+		"newDecoder":        mi.NewDecoder,
+		"newEncoder":        mi.NewEncoder,
+		"peekEncoding":      mi.PeekEncoding,
+		"releaseDecoder":    mi.ReleaseDecoder,
+		"selfTest":          mi.SelfTest,
+		"splitEncode":       mi.SplitEncode,
+		"tryDecode":         mi.TryDecode,
+
+		"decodeLengthPrefixed":       mi.DecodeLengthPrefixed,
+		"registerSingleByteEncoding": mi.RegisterSingleByteEncoding,
 	}}
 }
 
 // NewTextDecoder is the JS constructor for the TextDecoder object.
 func (mi *ModuleInstance) NewTextDecoder(call goja.ConstructorCall) *goja.Object {
+	return mi.newTextDecoder(call.Argument(0), call.Argument(1))
+}
+
+// NewDecoder is the JS factory function returning a TextDecoder object,
+// callable without the new keyword.
+func (mi *ModuleInstance) NewDecoder(label goja.Value, options goja.Value) *goja.Object {
+	return mi.newTextDecoder(label, options)
+}
+
+// newTextDecoder returns a TextDecoder object for the given label and options
+// arguments, throwing should any of them be invalid.
+func (mi *ModuleInstance) newTextDecoder(labelArg goja.Value, optionsArg goja.Value) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	label, options := parseTextDecoderArgs(rt, labelArg, optionsArg)
+
+	td, err := NewTextDecoder(rt, label, options)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return newTextDecoderObject(rt, td, mi.metrics)
+}
+
+// GetDecoder is the JS function returning a TextDecoder object for the given
+// label and options, backed by a decoder recycled from the pool shared by all
+// VUs, if any is available.
+//
+// The object is meant to be handed back with releaseDecoder once done with.
+func (mi *ModuleInstance) GetDecoder(labelArg goja.Value, optionsArg goja.Value) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	label, options := parseTextDecoderArgs(rt, labelArg, optionsArg)
+
+	td, err := GetDecoder(rt, label, options)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	obj := newTextDecoderObject(rt, td, mi.metrics)
+
+	if mi.pooled == nil {
+		mi.pooled = make(map[*goja.Object]*TextDecoder)
+	}
+	mi.pooled[obj] = td
+
+	return obj
+}
+
+// ReleaseDecoder is the JS function handing a TextDecoder object returned by
+// getDecoder back, so that its decoder is recycled. The decoding methods of
+// the object throw once released.
+func (mi *ModuleInstance) ReleaseDecoder(decoder goja.Value) {
+	rt := mi.vu.Runtime()
+
+	var obj *goja.Object
+	if !common.IsNullish(decoder) {
+		obj = decoder.ToObject(rt)
+	}
+
+	td, ok := mi.pooled[obj]
+	if !ok {
+		throw(rt, NewError(TypeError, "unable to release decoder; reason: it was not returned by getDecoder, or was already released"))
+	}
+
+	delete(mi.pooled, obj)
+	ReleaseDecoder(td)
+}
+
+// parseTextDecoderArgs returns the label and options the given TextDecoder
+// constructor arguments hold, throwing should any of them be invalid.
+func parseTextDecoderArgs(rt *goja.Runtime, labelArg goja.Value, optionsArg goja.Value) (string, textDecoderOptions) {
+	// Parse the label parameter
+	label, err := exportLabel(rt, labelArg)
+	if err != nil {
+		throw(rt, NewError(RangeError, "unable to extract label from the first argument; reason: "+err.Error()))
+	}
+
+	// Parse the options parameter
+	options, err := parseTextDecoderOptions(rt, optionsArg)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return label, options
+}
+
+// NewTextEncoder is the JS constructor for the TextEncoder object.
+func (mi *ModuleInstance) NewTextEncoder(call goja.ConstructorCall) *goja.Object {
+	return mi.newTextEncoder(call.Argument(0), call.Argument(1))
+}
+
+// NewEncoder is the JS factory function returning a TextEncoder object,
+// callable without the new keyword.
+func (mi *ModuleInstance) NewEncoder(label goja.Value, options goja.Value) *goja.Object {
+	return mi.newTextEncoder(label, options)
+}
+
+// newTextEncoder returns a TextEncoder object for the given label and options
+// arguments, throwing should any of them be invalid.
+func (mi *ModuleInstance) newTextEncoder(labelArg goja.Value, optionsArg goja.Value) *goja.Object {
 	rt := mi.vu.Runtime()
 
 	// Parse the label parameter
 	var label string
-	err := rt.ExportTo(call.Argument(0), &label)
+	if !common.IsNullish(labelArg) {
+		if err := rt.ExportTo(labelArg, &label); err != nil {
+			throw(rt, NewError(RangeError, "unable to extract label from the first argument; reason: "+err.Error()))
+		}
+	}
+
+	// Parse the options parameter
+	var options textEncoderOptions
+	if !common.IsNullish(optionsArg) {
+		if err := rt.ExportTo(optionsArg, &options); err != nil {
+			throw(rt, err)
+		}
+	}
+
+	te, err := NewTextEncoder(label, options)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return newTextEncoderObject(rt, te, mi.metrics)
+}
+
+// NewTextEncoderStream is the JS constructor for the TextEncoderStream object.
+func (mi *ModuleInstance) NewTextEncoderStream(_ goja.ConstructorCall) *goja.Object {
+	return newTextEncoderStreamObject(mi.vu.Runtime(), NewTextEncoderStream())
+}
+
+// ConcatDecode is the JS function decoding each of the given ArrayBuffer,
+// TypedArray or DataView independently, with the encoding the given label
+// resolves to, and returning the concatenation of the decoded text.
+func (mi *ModuleInstance) ConcatDecode(buffers goja.Value, label string, options goja.Value) string {
+	rt := mi.vu.Runtime()
+
+	data, err := exportArrayBuffers(rt, buffers)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	var opts concatDecodeOptions
+	if !common.IsNullish(options) {
+		if err := rt.ExportTo(options, &opts); err != nil {
+			throw(rt, err)
+		}
+	}
+
+	decoded, err := ConcatDecode(data, label, opts)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return decoded
+}
+
+// DecodeAsync is the JS function awaiting the given promise, or value, of an
+// ArrayBuffer, TypedArray or DataView, and decoding the bytes it resolves to
+// with the encoding the given label resolves to.
+//
+// It returns a promise of the decoded text, rejected with the reason the
+// given promise is rejected with, or with the error decoding failed with.
+func (mi *ModuleInstance) DecodeAsync(source goja.Value, label string, options goja.Value) *goja.Promise {
+	rt := mi.vu.Runtime()
+	promise, resolve, reject := rt.NewPromise()
+
+	opts, err := parseTextDecoderOptions(rt, options)
+	if err != nil {
+		reject(jsError(rt, err))
+		return promise
+	}
+
+	td, err := NewTextDecoder(rt, label, opts)
+	if err != nil {
+		reject(jsError(rt, err))
+		return promise
+	}
+
+	onFulfilled := func(call goja.FunctionCall) goja.Value {
+		data, err := exportArrayBuffer(rt, call.Argument(0))
+		if err != nil {
+			reject(jsError(rt, err))
+			return goja.Undefined()
+		}
+
+		decoded, err := td.Decode(data, decodeOptions{})
+		if err != nil {
+			reject(jsError(rt, err))
+			return goja.Undefined()
+		}
+
+		resolve(decoded)
+		return goja.Undefined()
+	}
+
+	onRejected := func(call goja.FunctionCall) goja.Value {
+		reject(call.Argument(0))
+		return goja.Undefined()
+	}
+
+	// Resolving the source through Promise.resolve awaits promises and
+	// thenables alike, and takes plain values as they are.
+	if err := then(rt, source, onFulfilled, onRejected); err != nil {
+		reject(jsError(rt, err))
+	}
+
+	return promise
+}
+
+// DecodeJSON is the JS function decoding the given ArrayBuffer, TypedArray or
+// DataView with the encoding the given label resolves to, and returning the
+// value the decoded text parses to as JSON.
+func (mi *ModuleInstance) DecodeJSON(source goja.Value, label string, options goja.Value) goja.Value {
+	rt := mi.vu.Runtime()
+
+	data, err := exportArrayBuffer(rt, source)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	opts, err := parseTextDecoderOptions(rt, options)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	value, err := DecodeJSON(rt, data, label, opts)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return value
+}
+
+// DecodeLengthPrefixed is the JS function reading the length prefix the given
+// ArrayBuffer, TypedArray or DataView starts with, and decoding the bytes it
+// announces as text.
+//
+// It returns an object holding the decoded text, as value, and the number of
+// bytes read, the prefix included, as bytesConsumed.
+func (mi *ModuleInstance) DecodeLengthPrefixed(source goja.Value, options goja.Value) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	data, err := exportArrayBuffer(rt, source)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	var opts decodeLengthPrefixedOptions
+	if !common.IsNullish(options) {
+		if err := rt.ExportTo(options, &opts); err != nil {
+			throw(rt, err)
+		}
+	}
+
+	decoded, consumed, err := DecodeLengthPrefixed(data, opts)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	result := rt.NewObject()
+	if err := result.Set("value", decoded); err != nil {
+		throw(rt, err)
+	}
+	if err := result.Set("bytesConsumed", consumed); err != nil {
+		throw(rt, err)
+	}
+
+	return result
+}
+
+// DecodeReader is the JS function decoding the bytes read from the given
+// reader with the encoding the given label resolves to.
+//
+// The reader is either an object holding a read(size) method, returning
+// an ArrayBuffer, TypedArray or DataView, or null or undefined once
+// exhausted, or an ArrayBuffer, TypedArray or DataView itself.
+func (mi *ModuleInstance) DecodeReader(source goja.Value, label string, options goja.Value) string {
+	rt := mi.vu.Runtime()
+
+	var r io.Reader
+	if !common.IsNullish(source) && (IsTypedArray(rt, source) || IsInstanceOf(rt, source, ArrayBufferConstructor, DataViewConstructor)) {
+		data, err := exportArrayBuffer(rt, source)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		r = bytes.NewReader(data)
+	} else {
+		jsr, err := newJSReader(rt, source)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		r = jsr
+	}
+
+	var opts decodeReaderOptions
+	if !common.IsNullish(options) {
+		if err := rt.ExportTo(options, &opts); err != nil {
+			throw(rt, err)
+		}
+	}
+
+	decoded, err := DecodeReader(r, label, opts)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return decoded
+}
+
+// DecodeLines is the JS function decoding the given ArrayBuffer, TypedArray
+// or DataView with the encoding the given label resolves to, and returning
+// the decoded text split into lines.
+func (mi *ModuleInstance) DecodeLines(source goja.Value, label string, options goja.Value) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	data, err := exportArrayBuffer(rt, source)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	var opts decodeLinesOptions
+	if !common.IsNullish(options) {
+		if err := rt.ExportTo(options, &opts); err != nil {
+			throw(rt, err)
+		}
+	}
+
+	lines, err := DecodeLines(data, label, opts)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	values := make([]interface{}, 0, len(lines))
+	for _, line := range lines {
+		values = append(values, line)
+	}
+
+	return rt.NewArray(values...)
+}
+
+// DecodeHTML is the JS function decoding the given ArrayBuffer, TypedArray
+// or DataView holding an HTML document, with the encoding it declares.
+func (mi *ModuleInstance) DecodeHTML(source goja.Value, options goja.Value) string {
+	rt := mi.vu.Runtime()
+
+	data, err := exportArrayBuffer(rt, source)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	var opts decodeHTMLOptions
+	if !common.IsNullish(options) {
+		if err := rt.ExportTo(options, &opts); err != nil {
+			throw(rt, err)
+		}
+	}
+
+	decoded, err := DecodeHTML(data, opts)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return decoded
+}
+
+// ByteLength is the JS function returning the number of bytes the given text
+// encodes to, using the encoding the given label resolves to, without
+// producing them.
+func (mi *ModuleInstance) ByteLength(text goja.Value, label string) int {
+	rt := mi.vu.Runtime()
+
+	if common.IsNullish(text) {
+		throw(rt, NewError(TypeError, "text is null or undefined"))
+	}
+
+	length, err := ByteLength(text.String(), label)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return length
+}
+
+// SplitEncode is the JS function encoding the given text with the encoding
+// the given label resolves to, and returning the encoded bytes split into an
+// array of Uint8Array chunks of at most maxBytes bytes, each ending on a
+// character boundary.
+func (mi *ModuleInstance) SplitEncode(text goja.Value, maxBytes int, label string) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	if common.IsNullish(text) {
+		throw(rt, NewError(TypeError, "text is null or undefined"))
+	}
+
+	chunks, err := SplitEncode(text.String(), maxBytes, label)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	values := make([]interface{}, 0, len(chunks))
+	for _, chunk := range chunks {
+		u, err := rt.New(rt.Get("Uint8Array"), rt.ToValue(rt.NewArrayBuffer(chunk)))
+		if err != nil {
+			throw(rt, err)
+		}
+
+		values = append(values, u)
+	}
+
+	return rt.NewArray(values...)
+}
+
+// SelfTest is the JS function round-tripping a sample text through the
+// encoding the given label resolves to.
+//
+// It returns an object holding the name of the encoding, the sample, whether
+// its characters all survived the round-trip, and the code point indices
+// of those which did not.
+func (mi *ModuleInstance) SelfTest(label string) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	report, err := SelfTest(label)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	positions := make([]interface{}, 0, len(report.LossyPositions))
+	for _, position := range report.LossyPositions {
+		positions = append(positions, position)
+	}
+
+	result := rt.NewObject()
+	if err := result.Set("encoding", report.Encoding); err != nil {
+		throw(rt, err)
+	}
+	if err := result.Set("sample", SelfTestSample); err != nil {
+		throw(rt, err)
+	}
+	if err := result.Set("lossless", report.Lossless); err != nil {
+		throw(rt, err)
+	}
+	if err := result.Set("lossyPositions", rt.NewArray(positions...)); err != nil {
+		throw(rt, err)
+	}
+
+	return result
+}
+
+// CompareBytes is the JS function returning the offset of the first byte
+// the given ArrayBuffers, TypedArrays or DataViews differ at, or -1 if
+// they are equal.
+func (mi *ModuleInstance) CompareBytes(a, b goja.Value) int {
+	rt := mi.vu.Runtime()
+
+	left, err := exportArrayBuffer(rt, a)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	right, err := exportArrayBuffer(rt, b)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return CompareBytes(left, right)
+}
+
+// CanonicalName is the JS function returning the canonical
+// name of the encoding the given label resolves to.
+func (mi *ModuleInstance) CanonicalName(label string) string {
+	rt := mi.vu.Runtime()
+
+	name, err := CanonicalName(label)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return name
+}
+
+// LabelsFor is the JS function returning all the labels resolving
+// to the same encoding as the given label.
+func (mi *ModuleInstance) LabelsFor(label string) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	labels, err := LabelsFor(label)
 	if err != nil {
-		common.Throw(rt, NewError(RangeError, "unable to extract label from the first argument; reason: "+err.Error()))
+		throw(rt, err)
+	}
+
+	values := make([]interface{}, 0, len(labels))
+	for _, l := range labels {
+		values = append(values, l)
+	}
+
+	return rt.NewArray(values...)
+}
+
+// PeekEncoding is the JS function returning a best guess of the
+// encoding of the given ArrayBuffer, TypedArray or DataView.
+func (mi *ModuleInstance) PeekEncoding(source goja.Value) string {
+	rt := mi.vu.Runtime()
+
+	data, err := exportArrayBuffer(rt, source)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return PeekEncoding(data)
+}
+
+// DecodeOrFallback is the JS function decoding the given ArrayBuffer, TypedArray
+// or DataView with the encoding the primary label resolves to, or the one the
+// fallback label resolves to, should the data not be valid in the former.
+//
+// It returns an object holding the decoded text, and the name of the
+// encoding used to decode it.
+func (mi *ModuleInstance) DecodeOrFallback(source goja.Value, primaryLabel, fallbackLabel string) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	data, err := exportArrayBuffer(rt, source)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	decoded, name, err := DecodeOrFallback(data, primaryLabel, fallbackLabel)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	result := rt.NewObject()
+	if err := result.Set("value", decoded); err != nil {
+		throw(rt, err)
+	}
+	if err := result.Set("encoding", name); err != nil {
+		throw(rt, err)
+	}
+
+	return result
+}
+
+// GraphemeCount is the JS function returning the number of extended
+// grapheme clusters, that is user-perceived characters, of the given text.
+func (mi *ModuleInstance) GraphemeCount(text string) int {
+	return GraphemeCount(text)
+}
+
+// IsValidUTF8 is the JS function returning whether the given ArrayBuffer,
+// TypedArray or DataView holds valid UTF-8 only.
+func (mi *ModuleInstance) IsValidUTF8(source goja.Value) bool {
+	rt := mi.vu.Runtime()
+
+	data, err := exportArrayBuffer(rt, source)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	return IsValidUTF8(data)
+}
+
+// TryDecode is the JS function decoding the given ArrayBuffer, TypedArray or
+// DataView with the encoding the given label resolves to, and returning an
+// object describing the outcome, rather than throwing on malformed data.
+func (mi *ModuleInstance) TryDecode(source goja.Value, label string, options goja.Value) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	data, err := exportArrayBuffer(rt, source)
+	if err != nil {
+		throw(rt, err)
+	}
+
+	var opts tryDecodeOptions
+	if !common.IsNullish(options) {
+		if err := rt.ExportTo(options, &opts); err != nil {
+			throw(rt, err)
+		}
 	}
 
-	// Parse the options parameter
-	var options textDecoderOptions
-	err = rt.ExportTo(call.Argument(1), &options)
+	decoded, malformed, err := TryDecode(data, label, opts)
 	if err != nil {
-		common.Throw(rt, err)
+		throw(rt, err)
 	}
 
-	td, err := NewTextDecoder(rt, label, options)
-	if err != nil {
-		common.Throw(rt, err)
+	malformedValue := goja.Null()
+	if malformed != nil {
+		malformedValue = rt.ToValue(malformed)
+	}
+
+	result := rt.NewObject()
+	if err := result.Set("ok", malformed == nil); err != nil {
+		throw(rt, err)
+	}
+	if err := result.Set("value", decoded); err != nil {
+		throw(rt, err)
+	}
+	if err := result.Set("error", malformedValue); err != nil {
+		throw(rt, err)
 	}
 
-	return newTextDecoderObject(rt, td)
+	return result
 }
 
-// NewTextEncoder is the JS constructor for the TextEncoder object.
-func (mi *ModuleInstance) NewTextEncoder(_ goja.ConstructorCall) *goja.Object {
-	return newTextEncoderObject(mi.vu.Runtime(), NewTextEncoder())
+// RegisterSingleByteEncoding is the JS function registering a single-byte
+// encoding under the given name, from an array of 256 code points.
+func (mi *ModuleInstance) RegisterSingleByteEncoding(name string, table goja.Value) {
+	rt := mi.vu.Runtime()
+
+	var runes []rune
+	if err := rt.ExportTo(table, &runes); err != nil {
+		throw(rt, NewError(TypeError, "unable to extract table from the second argument; reason: "+err.Error()))
+	}
+
+	if err := RegisterSingleByteEncoding(name, runes); err != nil {
+		throw(rt, err)
+	}
 }
 
 // newTextDecoderObject converts the given TextDecoder instance into a JS object.
@@ -94,35 +742,218 @@ func (mi *ModuleInstance) NewTextEncoder(_ goja.ConstructorCall) *goja.Object {
 //
 // In the event setting the properties on the object where to fail, the function
 // will throw a JS exception.
-func newTextDecoderObject(rt *goja.Runtime, td *TextDecoder) *goja.Object {
+func newTextDecoderObject(rt *goja.Runtime, td *TextDecoder, m *encodingMetrics) *goja.Object {
 	obj := rt.NewObject()
 
 	// Wrap the Go TextDecoder.Decode method in a JS function
-	decodeMethod := func(buffer goja.Value, options decodeOptions) string {
-		data, err := exportArrayBuffer(rt, buffer)
+	decodeMethod := func(buffer goja.Value, opts goja.Value) goja.Value {
+		options, err := parseDecodeOptions(rt, opts, td.DefaultStream)
 		if err != nil {
-			common.Throw(rt, err)
+			throw(rt, err)
+		}
+
+		switch options.Output {
+		case "", DecodeOutputString, DecodeOutputCodePoints, DecodeOutputCodeUnits:
+		default:
+			throw(rt, NewError(TypeError, fmt.Sprintf("unsupported output: %s", options.Output)))
+		}
+
+		switch options.ControlBytes {
+		case "", ControlBytesKeep, ControlBytesStrip, ControlBytesReplace:
+		default:
+			throw(rt, NewError(TypeError, fmt.Sprintf("unsupported control bytes policy: %s", options.ControlBytes)))
+		}
+
+		switch options.CaseFold {
+		case "", CaseFoldNone, CaseFoldLower, CaseFoldUpper, CaseFoldASCIILower, CaseFoldASCIIUpper:
+		default:
+			throw(rt, NewError(TypeError, fmt.Sprintf("unsupported case fold: %s", options.CaseFold)))
 		}
 
-		decoded, err := td.Decode(data, options)
+		decoded, consumed, err := td.DecodeValueConsumed(buffer, options)
 		if err != nil {
-			common.Throw(rt, err)
+			m.decodeFailed()
+			throw(rt, err)
 		}
 
-		return decoded
+		m.decoded(consumed, td.Substitutions())
+
+		var remainder []byte
+		if options.ReturnRemainder {
+			remainder = td.TakeRemainder()
+		}
+
+		value := rt.ToValue(decoded)
+		switch options.Output {
+		case DecodeOutputCodePoints:
+			value = newCodePointsArray(rt, decoded)
+		case DecodeOutputCodeUnits:
+			codeUnits, err := newCodeUnitsArray(rt, decoded)
+			if err != nil {
+				throw(rt, err)
+			}
+
+			value = codeUnits
+		}
+
+		if !options.WithConsumed && !options.ReportBOM && !options.ReportErrors && !options.ReturnRemainder && !options.FlagErrors {
+			return value
+		}
+
+		result := rt.NewObject()
+		if err := result.Set("value", value); err != nil {
+			throw(rt, err)
+		}
+
+		if options.WithConsumed {
+			if err := result.Set("consumed", consumed); err != nil {
+				throw(rt, err)
+			}
+		}
+
+		if options.ReportBOM {
+			bom := goja.Null()
+			if name := td.StrippedBOM(); name != "" {
+				bom = rt.ToValue(name)
+			}
+
+			if err := result.Set("bom", bom); err != nil {
+				throw(rt, err)
+			}
+		}
+
+		if options.ReportErrors {
+			regions := td.MalformedRegions()
+			values := make([]interface{}, 0, len(regions))
+			for _, region := range regions {
+				values = append(values, region)
+			}
+
+			if err := result.Set("errors", rt.NewArray(values...)); err != nil {
+				throw(rt, err)
+			}
+		}
+
+		if options.FlagErrors {
+			if err := result.Set("hadErrors", td.Substitutions() > 0); err != nil {
+				throw(rt, err)
+			}
+		}
+
+		if options.ReturnRemainder {
+			u, err := rt.New(rt.Get("Uint8Array"), rt.ToValue(rt.NewArrayBuffer(remainder)))
+			if err != nil {
+				throw(rt, err)
+			}
+
+			if err := result.Set("remainder", u); err != nil {
+				throw(rt, err)
+			}
+		}
+
+		return result
 	}
 
 	// Set the decode method to the wrapper function we just created
 	if err := setReadOnlyPropertyOf(obj, "decode", rt.ToValue(decodeMethod)); err != nil {
-		common.Throw(
+		throw(
 			rt,
 			errors.New("unable to define decode read-only property on TextDecoder object; reason: "+err.Error()),
 		)
 	}
 
+	// Wrap the Go TextDecoder.DecodeAll method in a JS function
+	decodeAllMethod := func(chunks goja.Value) string {
+		// Extract all the chunks beforehand, so that an invalid
+		// one leaves the decoder's state untouched.
+		data, err := exportArrayBuffers(rt, chunks)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		decoded, err := td.DecodeAll(data)
+		if err != nil {
+			m.decodeFailed()
+			throw(rt, err)
+		}
+
+		var size int
+		for _, chunk := range data {
+			size += len(chunk)
+		}
+		m.decoded(size, td.Substitutions())
+
+		return decoded
+	}
+
+	// Set the decodeAll method to the wrapper function we just created
+	if err := setReadOnlyPropertyOf(obj, "decodeAll", rt.ToValue(decodeAllMethod)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define decodeAll read-only property on TextDecoder object; reason: "+err.Error()),
+		)
+	}
+
+	// Wrap the Go TextDecoder.DecodeWithCallback method in a JS function
+	decodeWithCallbackMethod := func(buffer goja.Value, callback goja.Value, opts goja.Value) {
+		fn, ok := goja.AssertFunction(callback)
+		if !ok {
+			throw(rt, NewError(TypeError, "callback must be a function"))
+		}
+
+		data, err := exportArrayBuffer(rt, buffer)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		var options decodeWithCallbackOptions
+		if !common.IsNullish(opts) {
+			if err := rt.ExportTo(opts, &options); err != nil {
+				throw(rt, err)
+			}
+		}
+
+		// Errors thrown by the callback are not decode errors
+		var callbackErr error
+		err = td.DecodeWithCallback(data, options.ChunkSize, func(piece string) error {
+			_, callbackErr = fn(goja.Undefined(), rt.ToValue(piece))
+			return callbackErr
+		})
+		if err != nil {
+			if callbackErr == nil {
+				m.decodeFailed()
+			}
+
+			throw(rt, err)
+		}
+
+		m.decoded(len(data), td.Substitutions())
+	}
+
+	// Set the decodeWithCallback method to the wrapper function we just created
+	if err := setReadOnlyPropertyOf(obj, "decodeWithCallback", rt.ToValue(decodeWithCallbackMethod)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define decodeWithCallback read-only property on TextDecoder object; reason: "+err.Error()),
+		)
+	}
+
+	// Wrap the Go TextDecoder.Clone method in a JS function
+	cloneMethod := func() *goja.Object {
+		return newTextDecoderObject(rt, td.Clone(), m)
+	}
+
+	// Set the clone method to the wrapper function we just created
+	if err := setReadOnlyPropertyOf(obj, "clone", rt.ToValue(cloneMethod)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define clone read-only property on TextDecoder object; reason: "+err.Error()),
+		)
+	}
+
 	// Set the encoding property
 	if err := setReadOnlyPropertyOf(obj, "encoding", rt.ToValue(td.Encoding)); err != nil {
-		common.Throw(
+		throw(
 			rt,
 			errors.New("unable to define encoding read-only property on TextDecoder object; reason: "+err.Error()),
 		)
@@ -130,7 +961,7 @@ func newTextDecoderObject(rt *goja.Runtime, td *TextDecoder) *goja.Object {
 
 	// Set the fatal property
 	if err := setReadOnlyPropertyOf(obj, "fatal", rt.ToValue(td.Fatal)); err != nil {
-		common.Throw(
+		throw(
 			rt,
 			errors.New("unable to define fatal read-only property on TextDecoder object; reason: "+err.Error()),
 		)
@@ -138,49 +969,434 @@ func newTextDecoderObject(rt *goja.Runtime, td *TextDecoder) *goja.Object {
 
 	// Set the ignoreBOM property
 	if err := setReadOnlyPropertyOf(obj, "ignoreBOM", rt.ToValue(td.IgnoreBOM)); err != nil {
-		common.Throw(
+		throw(
 			rt,
 			errors.New("unable to define ignoreBOM read-only property on TextDecoder object; reason: "+err.Error()),
 		)
 	}
 
+	// Set the pending property, reflecting the decoder's current state
+	if err := setReadOnlyAccessorPropertyOf(obj, "pending", rt.ToValue(td.Pending)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define pending read-only property on TextDecoder object; reason: "+err.Error()),
+		)
+	}
+
+	// Set the decodeErrors property, reflecting the substitutions made by the last decode call
+	if err := setReadOnlyAccessorPropertyOf(obj, "decodeErrors", rt.ToValue(td.Substitutions)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define decodeErrors read-only property on TextDecoder object; reason: "+err.Error()),
+		)
+	}
+
 	return obj
 }
 
-func newTextEncoderObject(rt *goja.Runtime, te *TextEncoder) *goja.Object {
+// parseTextDecoderOptions returns the TextDecoder options the given value holds.
+//
+// Besides the canonical options object, a bare boolean is accepted, as a
+// shorthand for the fatal option.
+func parseTextDecoderOptions(rt *goja.Runtime, v goja.Value) (textDecoderOptions, error) {
+	var options textDecoderOptions
+	if common.IsNullish(v) {
+		return options, nil
+	}
+
+	if fatal, ok := v.Export().(bool); ok {
+		options.Fatal = fatal
+		return options, nil
+	}
+
+	if err := rt.ExportTo(v, &options); err != nil {
+		return options, err
+	}
+
+	// The resetOnError option is held negated, so that it defaults to true
+	if resetOnError := v.ToObject(rt).Get("resetOnError"); resetOnError != nil && !goja.IsUndefined(resetOnError) {
+		options.NoResetOnError = !resetOnError.ToBoolean()
+	}
+
+	return options, nil
+}
+
+// parseDecodeOptions returns the decode options the given value holds.
+//
+// Besides the canonical options object, a bare boolean is accepted, for
+// compatibility with code passing the stream option positionally. Should
+// the stream option be omitted, it defaults to the given value.
+func parseDecodeOptions(rt *goja.Runtime, v goja.Value, defaultStream bool) (decodeOptions, error) {
+	options := decodeOptions{Stream: defaultStream}
+	if common.IsNullish(v) {
+		return options, nil
+	}
+
+	if stream, ok := v.Export().(bool); ok {
+		options.Stream = stream
+		return options, nil
+	}
+
+	if err := rt.ExportTo(v, &options); err != nil {
+		return options, NewError(TypeError, "unable to parse the decode options; reason: "+err.Error())
+	}
+
+	// Exporting leaves the fields of omitted options untouched, the stream
+	// option is thus only reset should it be given as undefined. Otherwise,
+	// it is coerced as JS coerces values to booleans, explicitly, rather
+	// than left to the export rules, as WebIDL would have it.
+	if stream := v.ToObject(rt).Get("stream"); stream != nil {
+		if goja.IsUndefined(stream) {
+			options.Stream = defaultStream
+		} else {
+			options.Stream = stream.ToBoolean()
+		}
+	}
+
+	return options, nil
+}
+
+func newTextEncoderObject(rt *goja.Runtime, te *TextEncoder, m *encodingMetrics) *goja.Object {
 	obj := rt.NewObject()
 
+	// In strict mode, rather than being coerced to a string, as per the
+	// specification, inputs which are not strings throw, and so do
+	// strings holding lone surrogates.
+	checkStrictInput := func(s goja.Value) {
+		if !te.Strict {
+			return
+		}
+
+		if !isString(s) {
+			throw(rt, NewError(TypeError, "unable to encode text; reason: input is not a string"))
+		}
+
+		if hasLoneSurrogates(rt, s) {
+			throw(rt, NewError(TypeError, "unable to encode text; reason: input holds lone surrogates"))
+		}
+	}
+
 	// Wrap the Go TextEncoder.Encode method in a JS function
 	encodeMethod := func(s goja.Value) *goja.Object {
+		checkStrictInput(s)
+
 		buffer, err := te.Encode(s.String())
 		if err != nil {
-			common.Throw(rt, err)
+			throw(rt, err)
 		}
 
+		m.encoded(len(buffer))
+
 		// Create a new Uint8Array from the buffer
 		u, err := rt.New(rt.Get("Uint8Array"), rt.ToValue(rt.NewArrayBuffer(buffer)))
 		if err != nil {
-			common.Throw(rt, err)
+			throw(rt, err)
+		}
+
+		return u
+	}
+
+	// Wrap the Go TextEncoder.EncodeAll method in a JS function, taking
+	// an iterable of strings, and returning the concatenation of their
+	// encoded bytes as a single Uint8Array.
+	encodeAllMethod := func(v goja.Value) *goja.Object {
+		if common.IsNullish(v) {
+			throw(rt, NewError(TypeError, "texts must be an iterable of strings"))
+		}
+
+		var values []goja.Value
+		if err := rt.ExportTo(v, &values); err != nil {
+			throw(rt, NewError(TypeError, "texts must be an iterable of strings; reason: "+err.Error()))
+		}
+
+		chunks := make([]string, len(values))
+		edges := make([][2]uint16, len(values))
+		for i, value := range values {
+			if te.Strict && !isString(value) {
+				throw(rt, NewError(TypeError, "unable to encode text; reason: input is not a string"))
+			}
+
+			// Primitives other than strings convert to themselves,
+			// hence going through a Go string for them.
+			values[i] = value.ToString()
+			if !isString(values[i]) {
+				values[i] = rt.ToValue(values[i].String())
+			}
+
+			chunks[i] = values[i].String()
+			edges[i][0], edges[i][1] = stringEdges(rt, values[i])
+		}
+
+		if te.Strict && hasLoneSurrogatesAcross(rt, values, edges) {
+			throw(rt, NewError(TypeError, "unable to encode text; reason: input holds lone surrogates"))
+		}
+
+		buffer, err := te.EncodeAll(chunks, edges)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		m.encoded(len(buffer))
+
+		u, err := rt.New(rt.Get("Uint8Array"), rt.ToValue(rt.NewArrayBuffer(buffer)))
+		if err != nil {
+			throw(rt, err)
+		}
+
+		return u
+	}
+
+	// Wrap the Go TextEncoder.EncodeToBase64 method in a JS function
+	encodeToBase64Method := func(s goja.Value, variant string) string {
+		checkStrictInput(s)
+
+		encoded, err := te.EncodeToBase64(s.String(), variant)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		m.encoded(base64DecodedLen(encoded))
+
+		return encoded
+	}
+
+	// Wrap the Go TextEncoder.EncodeToHash method in a JS function, returning
+	// the digest as hexadecimal by default, or as base64, or as an ArrayBuffer,
+	// should "base64" or "binary" be given, as the k6/crypto module does.
+	encodeToHashMethod := func(s goja.Value, algorithm string, outputEncoding goja.Value) goja.Value {
+		checkStrictInput(s)
+
+		h, err := NewHash(algorithm)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		written, err := te.EncodeToHash(s.String(), h)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		m.encoded(written)
+
+		digest := h.Sum(nil)
+
+		output := "hex"
+		if !common.IsNullish(outputEncoding) {
+			output = outputEncoding.String()
+		}
+
+		switch output {
+		case "hex":
+			return rt.ToValue(hex.EncodeToString(digest))
+		case "base64":
+			return rt.ToValue(base64.StdEncoding.EncodeToString(digest))
+		case "binary":
+			return rt.ToValue(rt.NewArrayBuffer(digest))
+		default:
+			throw(rt, NewError(TypeError, fmt.Sprintf("unsupported digest output encoding: %s", output)))
+			return nil
+		}
+	}
+
+	// Wrap the Go TextEncoder.EncodeShared method in a JS function, returning
+	// views of a single ArrayBuffer, sharing its memory with the encoder's
+	// shared buffer, for as long as the latter does not need to grow.
+	var (
+		shared     goja.ArrayBuffer
+		sharedSize = -1
+	)
+	encodeSharedMethod := func(s goja.Value) *goja.Object {
+		checkStrictInput(s)
+
+		buffer, err := te.EncodeShared(s.String())
+		if err != nil {
+			throw(rt, err)
+		}
+
+		m.encoded(len(buffer))
+
+		// The shared buffer is reallocated whenever it grows
+		if sharedSize != cap(buffer) {
+			shared = rt.NewArrayBuffer(buffer[:cap(buffer)])
+			sharedSize = cap(buffer)
+		}
+
+		u, err := rt.New(rt.Get("Uint8Array"), rt.ToValue(shared), rt.ToValue(0), rt.ToValue(len(buffer)))
+		if err != nil {
+			throw(rt, err)
 		}
 
 		return u
 	}
 
+	// Wrap the Go TextEncoder.EncodeInto method in a JS function
+	encodeIntoMethod := func(s goja.Value, destination goja.Value) *goja.Object {
+		if !IsInstanceOf(rt, destination, Uint8ArrayConstructor) {
+			throw(rt, NewError(TypeError, "destination is not a Uint8Array"))
+		}
+
+		if isDetached(destination.ToObject(rt)) {
+			throw(rt, NewError(TypeError, "cannot encode into detached ArrayBuffer"))
+		}
+
+		checkStrictInput(s)
+
+		buffer, err := exportArrayBuffer(rt, destination)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		// As every character is encoded as at least one byte, at most as many
+		// code units as the destination holds bytes can be read. Truncating the
+		// source accordingly beforehand bounds the work to the destination size.
+		//
+		// Note that one extra code unit is kept so that a surrogate pair is never
+		// split at the truncation point in a way that would alter the characters
+		// that could be written.
+		s = truncateString(rt, s, len(buffer)+1)
+
+		read, written, err := te.EncodeInto(s.String(), buffer)
+		if err != nil {
+			throw(rt, err)
+		}
+
+		m.encoded(written)
+
+		result := rt.NewObject()
+		if err := result.Set("read", read); err != nil {
+			throw(rt, err)
+		}
+		if err := result.Set("written", written); err != nil {
+			throw(rt, err)
+		}
+
+		return result
+	}
+
 	// Set the encode property by wrapping the Go function in a JS function
 	if err := setReadOnlyPropertyOf(obj, "encode", rt.ToValue(encodeMethod)); err != nil {
-		common.Throw(
+		throw(
 			rt,
 			errors.New("unable to define encode read-only method on TextEncoder object; reason: "+err.Error()),
 		)
 	}
 
+	// Set the encodeAll property by wrapping the Go function in a JS function
+	if err := setReadOnlyPropertyOf(obj, "encodeAll", rt.ToValue(encodeAllMethod)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define encodeAll read-only method on TextEncoder object; reason: "+err.Error()),
+		)
+	}
+
+	// Set the encodeToBase64 property by wrapping the Go function in a JS function
+	if err := setReadOnlyPropertyOf(obj, "encodeToBase64", rt.ToValue(encodeToBase64Method)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define encodeToBase64 read-only method on TextEncoder object; reason: "+err.Error()),
+		)
+	}
+
+	// Set the encodeToHash property by wrapping the Go function in a JS function
+	if err := setReadOnlyPropertyOf(obj, "encodeToHash", rt.ToValue(encodeToHashMethod)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define encodeToHash read-only method on TextEncoder object; reason: "+err.Error()),
+		)
+	}
+
+	// Set the encodeShared property by wrapping the Go function in a JS function
+	if err := setReadOnlyPropertyOf(obj, "encodeShared", rt.ToValue(encodeSharedMethod)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define encodeShared read-only method on TextEncoder object; reason: "+err.Error()),
+		)
+	}
+
+	// Set the encodeInto property by wrapping the Go function in a JS function
+	if err := setReadOnlyPropertyOf(obj, "encodeInto", rt.ToValue(encodeIntoMethod)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define encodeInto read-only method on TextEncoder object; reason: "+err.Error()),
+		)
+	}
+
 	// Set the encoding property
 	if err := setReadOnlyPropertyOf(obj, "encoding", rt.ToValue(te.Encoding)); err != nil {
-		common.Throw(
+		throw(
 			rt,
 			errors.New("unable to define encoding read-only property on TextEncoder object; reason: "+err.Error()),
 		)
 	}
 
+	// Set the strict property
+	if err := setReadOnlyPropertyOf(obj, "strict", rt.ToValue(te.Strict)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define strict read-only property on TextEncoder object; reason: "+err.Error()),
+		)
+	}
+
+	// Set the escapeInvalid property
+	if err := setReadOnlyPropertyOf(obj, "escapeInvalid", rt.ToValue(te.EscapeInvalid)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define escapeInvalid read-only property on TextEncoder object; reason: "+err.Error()),
+		)
+	}
+
+	return obj
+}
+
+func newTextEncoderStreamObject(rt *goja.Runtime, tes *TextEncoderStream) *goja.Object {
+	obj := rt.NewObject()
+
+	// newUint8Array wraps the given bytes in a new Uint8Array
+	newUint8Array := func(buffer []byte) *goja.Object {
+		u, err := rt.New(rt.Get("Uint8Array"), rt.ToValue(rt.NewArrayBuffer(buffer)))
+		if err != nil {
+			throw(rt, err)
+		}
+
+		return u
+	}
+
+	// Wrap the Go TextEncoderStream.Write method in a JS function
+	writeMethod := func(chunk goja.Value) *goja.Object {
+		s := chunk.ToString()
+		first, last := stringEdges(rt, s)
+
+		return newUint8Array(tes.Write(s.String(), first, last))
+	}
+
+	// Set the write method to the wrapper function we just created
+	if err := setReadOnlyPropertyOf(obj, "write", rt.ToValue(writeMethod)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define write read-only property on TextEncoderStream object; reason: "+err.Error()),
+		)
+	}
+
+	// Wrap the Go TextEncoderStream.Flush method in a JS function
+	flushMethod := func() *goja.Object {
+		return newUint8Array(tes.Flush())
+	}
+
+	// Set the flush method to the wrapper function we just created
+	if err := setReadOnlyPropertyOf(obj, "flush", rt.ToValue(flushMethod)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define flush read-only property on TextEncoderStream object; reason: "+err.Error()),
+		)
+	}
+
+	// Set the encoding property
+	if err := setReadOnlyPropertyOf(obj, "encoding", rt.ToValue(tes.Encoding)); err != nil {
+		throw(
+			rt,
+			errors.New("unable to define encoding read-only property on TextEncoderStream object; reason: "+err.Error()),
+		)
+	}
+
 	return obj
 }
@@ -0,0 +1,106 @@
+package encoding
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// decoders is the pool of TextDecoder instances shared by all VUs.
+//
+//nolint:gochecknoglobals
+var decoders = &decoderPool{pools: make(map[decoderPoolKey]*sync.Pool)}
+
+// decoderPool recycles the TextDecoder instances released by their users,
+// so that they can be reused, rather than constructed anew, by others.
+//
+// Decoders are pooled by encoding and options, so that a decoder taken
+// from the pool only differs from a new one by its grown scratch buffer.
+type decoderPool struct {
+	mu    sync.Mutex
+	pools map[decoderPoolKey]*sync.Pool
+}
+
+// decoderPoolKey identifies the decoders which are interchangeable.
+type decoderPoolKey struct {
+	encoding EncodingName
+	options  textDecoderOptions
+}
+
+// GetDecoder returns a TextDecoder for the given label and options, taken
+// from the pool of decoders released by ReleaseDecoder, if any is available,
+// or constructed as NewTextDecoder does otherwise.
+func GetDecoder(rt *goja.Runtime, label string, options textDecoderOptions) (*TextDecoder, error) {
+	// An empty label defaults to the utf-8 encoding
+	if strings.TrimSpace(label) == "" {
+		label = UTF8EncodingFormat
+	}
+
+	entry, ok := lookupEncoding(label)
+	if !ok {
+		return nil, NewError(RangeError, fmt.Sprintf("unsupported encoding: %s", label))
+	}
+
+	if td, ok := decoders.pool(decoderPoolKey{encoding: entry.name, options: options}).Get().(*TextDecoder); ok {
+		td.rt = rt
+		return td, nil
+	}
+
+	return NewTextDecoder(rt, entry.name, options)
+}
+
+// ReleaseDecoder resets the given TextDecoder, discarding the state of its
+// current stream, if any, and puts it back in the pool GetDecoder takes
+// decoders from.
+//
+// The pool takes a copy of the decoder over, along with its scratch buffer,
+// and the given decoder is invalidated, so that its decode calls fail from
+// then on, rather than interfere with the user the copy is handed out to.
+// Releasing a decoder which was released already does nothing.
+func ReleaseDecoder(td *TextDecoder) {
+	td.Reset()
+
+	td.mu.Lock()
+	if td.released {
+		td.mu.Unlock()
+		return
+	}
+	td.released = true
+	td.mu.Unlock()
+
+	recycled := td.Clone()
+	recycled.rt = nil
+	recycled.scratch, td.scratch = td.scratch, nil
+
+	key := decoderPoolKey{
+		encoding: td.Encoding,
+		options: textDecoderOptions{
+			Fatal:          td.Fatal,
+			IgnoreBOM:      td.IgnoreBOM,
+			AutoReBOM:      td.AutoReBOM,
+			DefaultStream:  td.DefaultStream,
+			NoResetOnError: !td.ResetOnError,
+			Newline:        td.Newline,
+			LoneSurrogates: td.LoneSurrogates,
+		},
+	}
+
+	decoders.pool(key).Put(recycled)
+}
+
+// pool returns the pool of the decoders identified by the given key,
+// creating it if needed.
+func (p *decoderPool) pool(key decoderPoolKey) *sync.Pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pool, ok := p.pools[key]
+	if !ok {
+		pool = &sync.Pool{}
+		p.pools[key] = pool
+	}
+
+	return pool
+}
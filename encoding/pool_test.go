@@ -0,0 +1,165 @@
+package encoding
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoderPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("released decoders are reset", func(t *testing.T) {
+		t.Parallel()
+
+		options := textDecoderOptions{IgnoreBOM: true}
+
+		td, err := GetDecoder(nil, "utf-8", options)
+		require.NoError(t, err)
+
+		// Leave the first bytes of a sequence buffered
+		_, err = td.Decode([]byte{0x61, 0xE6, 0xB0}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		require.True(t, td.Pending())
+
+		ReleaseDecoder(td)
+
+		assert.False(t, td.Pending(), "released decoder should hold no buffered bytes")
+
+		recycled, err := GetDecoder(nil, "utf-8", options)
+		require.NoError(t, err)
+
+		decoded, err := recycled.Decode([]byte{0xB4, 0x62}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "\uFFFDb", decoded, "recycled decoder should start a new stream")
+	})
+
+	t.Run("released decoders are invalidated", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := GetDecoder(nil, "utf-8", textDecoderOptions{})
+		require.NoError(t, err)
+
+		ReleaseDecoder(td)
+		ReleaseDecoder(td)
+
+		_, err = td.Decode([]byte("a"), decodeOptions{})
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+
+		// The decoder handed out by the pool is not the released one
+		recycled, err := GetDecoder(nil, "utf-8", textDecoderOptions{})
+		require.NoError(t, err)
+		assert.NotSame(t, td, recycled)
+
+		decoded, err := recycled.Decode([]byte("a"), decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "a", decoded)
+	})
+
+	t.Run("unsupported label", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := GetDecoder(nil, "bogus-label", textDecoderOptions{})
+		assert.ErrorContains(t, err, RangeError)
+	})
+
+	t.Run("decoders recycled across goroutines leak no state", func(t *testing.T) {
+		t.Parallel()
+
+		const (
+			workers    = 8
+			iterations = 200
+		)
+
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < iterations; j++ {
+					td, err := GetDecoder(nil, "utf-8", textDecoderOptions{})
+					if !assert.NoError(t, err) {
+						return
+					}
+
+					// A decoder recycled with its state would prepend
+					// the bytes buffered by its previous user.
+					if !assert.False(t, td.Pending(), "recycled decoder should hold no buffered bytes") {
+						return
+					}
+
+					first, err := td.Decode([]byte{0xEF, 0xBB, 0xBF, 0x61, 0xE6, 0xB0}, decodeOptions{Stream: true})
+					if !assert.NoError(t, err) {
+						return
+					}
+
+					assert.Equal(t, "a", first)
+
+					// Release half of the decoders in the middle of their stream
+					if j%2 == 0 {
+						ReleaseDecoder(td)
+						continue
+					}
+
+					rest, err := td.Decode([]byte{0xB4}, decodeOptions{})
+					if !assert.NoError(t, err) {
+						return
+					}
+
+					assert.Equal(t, "\u6C34", rest)
+					ReleaseDecoder(td)
+				}
+			}()
+		}
+
+		wg.Wait()
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = getDecoder("utf-8", { fatal: false });
+			assert_equals(decoder.encoding, "utf-8", "encoding property");
+			assert_equals(decoder.decode(new Uint8Array([0x61, 0xe6, 0xb0]), { stream: true }), "a");
+			releaseDecoder(decoder);
+
+			let error;
+			try {
+				decoder.decode(new Uint8Array([0x61]));
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "using a released decoder should throw a TypeError");
+
+			const recycled = getDecoder("utf8");
+			assert_equals(recycled.decode(new Uint8Array([0xb4, 0x62])), "\uFFFDb", "no bytes should leak from a previous use");
+			releaseDecoder(recycled);
+
+			error = undefined;
+			try {
+				releaseDecoder(recycled);
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "releasing a decoder twice should throw a TypeError");
+
+			error = undefined;
+			try {
+				releaseDecoder(new TextDecoder());
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "releasing a decoder getDecoder did not return should throw a TypeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
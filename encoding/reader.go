@@ -0,0 +1,93 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecodeReader decodes the bytes read from the given reader using the
+// encoding the given label resolves to, and returns the decoded text.
+//
+// The reader is read from in chunks of at most options.ChunkSize bytes,
+// which are decoded in streaming mode as they are read, so that sequences
+// spanning two chunks decode as a whole. Should options.OnProgress be set,
+// it is called after each chunk with the number of bytes read so far.
+func DecodeReader(r io.Reader, label string, options decodeReaderOptions) (string, error) {
+	chunkSize := options.ChunkSize
+	switch {
+	case chunkSize == 0:
+		chunkSize = defaultDecodeChunkSize
+	case chunkSize < 0:
+		return "", NewError(RangeError, fmt.Sprintf("unable to decode reader; reason: chunkSize must be positive, got %d", chunkSize))
+	}
+
+	td, err := NewTextDecoder(nil, label, textDecoderOptions{
+		Fatal:     options.Fatal,
+		IgnoreBOM: options.IgnoreBOM,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		decoded   strings.Builder
+		bytesRead int
+		chunk     = make([]byte, chunkSize)
+	)
+	for {
+		n, readErr := r.Read(chunk)
+		if n > 0 {
+			text, err := td.Decode(chunk[:n], decodeOptions{Stream: true})
+			if err != nil {
+				return "", err
+			}
+
+			decoded.WriteString(text)
+			bytesRead += n
+
+			if options.OnProgress != nil {
+				options.OnProgress(bytesRead)
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+
+		if readErr != nil {
+			return "", readErr
+		}
+	}
+
+	// Flush the sequence the last chunk might have left incomplete
+	text, err := td.Decode(nil, decodeOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	decoded.WriteString(text)
+
+	return decoded.String(), nil
+}
+
+type decodeReaderOptions struct {
+	// ChunkSize holds the maximum number of bytes
+	// read from the reader at once.
+	//
+	// It defaults to 64 KiB.
+	ChunkSize int `js:"chunkSize"`
+
+	// OnProgress holds a function called after each chunk
+	// is decoded, with the number of bytes read so far.
+	OnProgress func(bytesRead int) `js:"onProgress"`
+
+	// Fatal holds a boolean value indicating if decoding
+	// invalid data must throw a `TypeError`.
+	Fatal bool `js:"fatal"`
+
+	// IgnoreBOM holds a boolean value indicating
+	// whether the byte order mark is ignored.
+	IgnoreBOM bool `js:"ignoreBOM"`
+}
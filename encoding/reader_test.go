@@ -0,0 +1,148 @@
+package encoding
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeReader(t *testing.T) {
+	t.Parallel()
+
+	t.Run("chunk sizes", func(t *testing.T) {
+		t.Parallel()
+
+		text := strings.Repeat("a\u00E9\u6C34\U0001F600", 100)
+
+		for chunkSize := 1; chunkSize <= 8; chunkSize++ {
+			var progress []int
+
+			decoded, err := DecodeReader(strings.NewReader(text), "utf-8", decodeReaderOptions{
+				ChunkSize:  chunkSize,
+				OnProgress: func(bytesRead int) { progress = append(progress, bytesRead) },
+			})
+			require.NoError(t, err)
+			assert.Equal(t, text, decoded, "sequences spanning chunks should be decoded whole")
+
+			require.Len(t, progress, (len(text)+chunkSize-1)/chunkSize)
+			assert.Equal(t, chunkSize, progress[0])
+			assert.Equal(t, len(text), progress[len(progress)-1])
+		}
+	})
+
+	t.Run("utf-16le", func(t *testing.T) {
+		t.Parallel()
+
+		data := []byte{0x61, 0x00, 0x3D, 0xD8, 0x00, 0xDE}
+
+		decoded, err := DecodeReader(bytes.NewReader(data), "utf-16le", decodeReaderOptions{ChunkSize: 3})
+		require.NoError(t, err)
+		assert.Equal(t, "a\U0001F600", decoded)
+	})
+
+	t.Run("truncated sequence at the end", func(t *testing.T) {
+		t.Parallel()
+
+		decoded, err := DecodeReader(bytes.NewReader([]byte{0x61, 0xE6, 0xB0}), "utf-8", decodeReaderOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "a\uFFFD", decoded)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			name    string
+			data    []byte
+			label   string
+			options decodeReaderOptions
+			want    ErrorName
+		}{
+			{name: "negative chunk size", data: []byte{0x61}, label: "utf-8", options: decodeReaderOptions{ChunkSize: -1}, want: RangeError},
+			{name: "unsupported label", data: []byte{0x61}, label: "bogus-label", want: RangeError},
+			{name: "fatal", data: []byte{0x61, 0xFF}, label: "utf-8", options: decodeReaderOptions{Fatal: true}, want: TypeError},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				_, err := DecodeReader(bytes.NewReader(tc.data), tc.label, tc.options)
+
+				var encodingErr *Error
+				require.ErrorAs(t, err, &encodingErr)
+				assert.Equal(t, tc.want, encodingErr.Name)
+			})
+		}
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const text = "a\u00e9\u6c34\u{1f600}".repeat(100000);
+			const data = new TextEncoder().encode(text);
+
+			// A reader over the data, returning views of at most size bytes
+			function newReader(data) {
+				let offset = 0;
+				return {
+					read(size) {
+						if (offset >= data.length) {
+							return null;
+						}
+
+						const chunk = data.subarray(offset, offset + size);
+						offset += chunk.length;
+						return chunk;
+					},
+				};
+			}
+
+			const progress = [];
+			const decoded = decodeReader(newReader(data), "utf-8", {
+				chunkSize: 4096,
+				onProgress: (bytesRead) => progress.push(bytesRead),
+			});
+			assert_equals(decoded, new TextDecoder().decode(data), "decodeReader should match a one-shot decode");
+			assert_equals(progress.length, Math.ceil(data.length / 4096), "onProgress should be called for each chunk");
+			assert_equals(progress[0], 4096);
+			assert_equals(progress[progress.length - 1], data.length, "onProgress should report all the bytes read");
+
+			assert_equals(decodeReader(data, "utf-8", { chunkSize: 7 }), decoded, "buffer sources should be read in chunks too");
+			assert_equals(decodeReader(newReader(new Uint8Array([])), "utf-8"), "", "an empty reader should decode to an empty string");
+
+			let error;
+			try {
+				decodeReader({ read() { throw new Error("boom"); } }, "utf-8");
+			} catch (e) {
+				error = e;
+			}
+			assert_equals(error.message, "boom", "errors thrown by the reader should propagate");
+
+			error = undefined;
+			try {
+				decodeReader(newReader(data), "utf-8", { onProgress() { throw new Error("stop"); } });
+			} catch (e) {
+				error = e;
+			}
+			assert_equals(error.message, "stop", "errors thrown by onProgress should propagate");
+
+			error = undefined;
+			try {
+				decodeReader({}, "utf-8");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "a reader without a read method should throw a TypeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,65 @@
+package encoding
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// replacementEncoding implements the replacement encoding, which the
+// specification maps to labels of encodings known to be a security
+// risk, in order to prevent them from being decoded.
+//
+// Decoding a non-empty stream with it yields a single replacement
+// character, while decoding an empty one yields nothing.
+//
+// Unlike [encoding.Replacement], whose decoder emits a replacement character
+// for each non-empty input it transforms, the decoder only emits one per stream,
+// regardless of how many calls the stream is transformed in.
+type replacementEncoding struct{}
+
+// Ensure the interfaces are implemented correctly
+var _ encoding.Encoding = replacementEncoding{}
+
+// NewDecoder implements the encoding.Encoding interface.
+func (replacementEncoding) NewDecoder() *encoding.Decoder {
+	return &encoding.Decoder{Transformer: &replacementDecoder{}}
+}
+
+// NewEncoder implements the encoding.Encoding interface.
+func (replacementEncoding) NewEncoder() *encoding.Encoder {
+	return encoding.Replacement.NewEncoder()
+}
+
+// replacementDecoder transforms any non-empty stream into a single replacement character.
+type replacementDecoder struct {
+	// emitted indicates whether the replacement character
+	// has already been emitted for the current stream.
+	emitted bool
+}
+
+// Transform implements the transform.Transformer interface.
+func (d *replacementDecoder) Transform(dst, src []byte, _ bool) (nDst, nSrc int, err error) {
+	if len(src) == 0 || d.emitted {
+		return 0, len(src), nil
+	}
+
+	const replacementCharacter = "\uFFFD"
+	if len(dst) < len(replacementCharacter) {
+		return 0, 0, transform.ErrShortDst
+	}
+
+	d.emitted = true
+
+	return copy(dst, replacementCharacter), len(src), nil
+}
+
+// Reset implements the transform.Transformer interface.
+func (d *replacementDecoder) Reset() {
+	d.emitted = false
+}
+
+// clone implements the transformerCloner interface.
+func (d *replacementDecoder) clone() transform.Transformer {
+	c := *d
+	return &c
+}
@@ -0,0 +1,72 @@
+package encoding
+
+// SelfTestSample holds the text SelfTest round-trips through an encoding,
+// covering ASCII, Latin-1, BMP and astral characters.
+const SelfTestSample = "Hello, world! caf\u00E9 \u00A3\u20AC \u6C34\u3042 \U0001F600"
+
+// SelfTestResult holds the outcome of round-tripping SelfTestSample
+// through an encoding.
+type SelfTestResult struct {
+	// Encoding holds the name of the encoding the label resolved to.
+	Encoding string
+
+	// Lossless holds whether each character of the sample
+	// decodes back to itself once encoded.
+	Lossless bool
+
+	// LossyPositions holds the indices, in code points, of the characters
+	// of the sample which could not be encoded, or did not decode back
+	// to themselves.
+	LossyPositions []int
+}
+
+// SelfTest encodes and decodes SelfTestSample, one character at a time,
+// with the encoding the given label resolves to, and reports the characters
+// which do not survive the round-trip.
+//
+// It is meant to check that an encoding is wired correctly: a lossless
+// encoding, such as utf-8, is expected to report no loss at all, and a
+// single-byte one to report exactly the characters it cannot represent.
+func SelfTest(label string) (SelfTestResult, error) {
+	te, err := NewTextEncoder(label, textEncoderOptions{})
+	if err != nil {
+		return SelfTestResult{}, err
+	}
+
+	td, err := NewTextDecoder(nil, label, textDecoderOptions{})
+	if err != nil {
+		return SelfTestResult{}, err
+	}
+
+	result := SelfTestResult{Encoding: te.Encoding, LossyPositions: []int{}}
+
+	position := 0
+	for _, r := range SelfTestSample {
+		if !roundTrips(te, td, string(r)) {
+			result.LossyPositions = append(result.LossyPositions, position)
+		}
+
+		position++
+	}
+
+	result.Lossless = len(result.LossyPositions) == 0
+
+	return result, nil
+}
+
+// roundTrips returns true if the given text decodes back
+// to itself once encoded with the given encoder.
+func roundTrips(te *TextEncoder, td *TextDecoder, text string) bool {
+	// Characters the encoding cannot represent fail to encode
+	encoded, err := te.Encode(text)
+	if err != nil {
+		return false
+	}
+
+	decoded, err := td.Decode(encoded, decodeOptions{})
+	if err != nil {
+		return false
+	}
+
+	return decoded == text
+}
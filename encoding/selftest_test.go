@@ -0,0 +1,76 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		label    string
+		encoding string
+		lossy    []int
+	}{
+		{label: "utf-8", encoding: UTF8EncodingFormat, lossy: []int{}},
+		{label: "utf-16le", encoding: UTF16LEEncodingFormat, lossy: []int{}},
+		// The CJK characters and the emoji
+		{label: "windows-1252", encoding: "windows-1252", lossy: []int{22, 23, 25}},
+		// The pound and euro signs too
+		{label: "latin2", encoding: "iso-8859-2", lossy: []int{19, 20, 22, 23, 25}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.label, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := SelfTest(tc.label)
+			require.NoError(t, err)
+			assert.Equal(t, tc.encoding, result.Encoding)
+			assert.Equal(t, tc.lossy, result.LossyPositions)
+			assert.Equal(t, len(tc.lossy) == 0, result.Lossless)
+		})
+	}
+
+	t.Run("unsupported label", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := SelfTest("bogus-label")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const utf8 = selfTest("utf-8");
+			assert_equals(utf8.encoding, "utf-8");
+			assert_true(utf8.lossless, "utf-8 should be lossless");
+			assert_equals(utf8.lossyPositions.length, 0);
+
+			const windows1252 = selfTest("windows-1252");
+			assert_false(windows1252.lossless, "windows-1252 should be lossy");
+			assert_equals(windows1252.lossyPositions.join(), "22,23,25");
+			assert_equals(windows1252.lossyPositions.map((i) => [...windows1252.sample][i]).join(""), "\u6c34\u3042\u{1f600}");
+
+			let error;
+			try {
+				selfTest("bogus-label");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof RangeError, "an unsupported label should throw a RangeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
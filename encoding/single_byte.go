@@ -0,0 +1,166 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// singleByteTableSize is the number of entries of a single-byte encoding table.
+const singleByteTableSize = 256
+
+// singleByteEncoding is a single-byte encoding defined by a
+// table mapping each byte to the rune at its index.
+//
+// It allows decoding text encoded with code pages not
+// provided by the golang.org/x/text/encoding packages.
+type singleByteEncoding struct {
+	table   [singleByteTableSize]rune
+	reverse map[rune]byte
+}
+
+// Ensure the interfaces are implemented correctly
+var _ encoding.Encoding = &singleByteEncoding{}
+
+// newSingleByteEncoding returns a new single-byte encoding using the given table.
+//
+// The table must hold exactly 256 valid runes.
+func newSingleByteEncoding(table []rune) (*singleByteEncoding, error) {
+	if len(table) != singleByteTableSize {
+		return nil, NewError(
+			RangeError,
+			fmt.Sprintf("single-byte encoding table must hold %d entries, got %d", singleByteTableSize, len(table)),
+		)
+	}
+
+	e := &singleByteEncoding{reverse: make(map[rune]byte, singleByteTableSize)}
+	for i, r := range table {
+		if !utf8.ValidRune(r) {
+			return nil, NewError(RangeError, fmt.Sprintf("single-byte encoding table entry %d is not a valid code point", i))
+		}
+
+		e.table[i] = r
+
+		// Favor the lowest byte when several map to the same rune
+		if _, ok := e.reverse[r]; !ok {
+			e.reverse[r] = byte(i)
+		}
+	}
+
+	return e, nil
+}
+
+// xUserDefined implements the x-user-defined encoding, which maps ASCII
+// bytes to themselves, and bytes 0x80 to 0xFF to the private use code
+// points U+F780 to U+F7FF.
+//
+//nolint:gochecknoglobals
+var xUserDefined = func() *singleByteEncoding {
+	table := make([]rune, singleByteTableSize)
+	for i := range table {
+		table[i] = rune(i)
+		if i >= utf8.RuneSelf {
+			table[i] = 0xF780 + rune(i-utf8.RuneSelf)
+		}
+	}
+
+	e, err := newSingleByteEncoding(table)
+	if err != nil {
+		panic(err)
+	}
+
+	return e
+}()
+
+// NewDecoder implements the encoding.Encoding interface.
+func (e *singleByteEncoding) NewDecoder() *encoding.Decoder {
+	return &encoding.Decoder{Transformer: singleByteDecoder{encoding: e}}
+}
+
+// NewEncoder implements the encoding.Encoding interface.
+func (e *singleByteEncoding) NewEncoder() *encoding.Encoder {
+	return &encoding.Encoder{Transformer: singleByteEncoder{encoding: e}}
+}
+
+// singleByteDecoder transforms single-byte encoded text into UTF-8.
+type singleByteDecoder struct {
+	transform.NopResetter
+
+	encoding *singleByteEncoding
+}
+
+// Transform implements the transform.Transformer interface.
+func (d singleByteDecoder) Transform(dst, src []byte, _ bool) (nDst, nSrc int, err error) {
+	for _, b := range src {
+		r := d.encoding.table[b]
+		if nDst+utf8.RuneLen(r) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+
+		nDst += utf8.EncodeRune(dst[nDst:], r)
+		nSrc++
+	}
+
+	return nDst, nSrc, nil
+}
+
+// errUnmappableRune is returned when encoding a rune the single-byte encoding has no byte for.
+var errUnmappableRune = errors.New("rune not representable in the single-byte encoding")
+
+// singleByteEncoder transforms UTF-8 text into single-byte encoded text.
+type singleByteEncoder struct {
+	transform.NopResetter
+
+	encoding *singleByteEncoding
+}
+
+// Transform implements the transform.Transformer interface.
+func (e singleByteEncoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		if !atEOF && !utf8.FullRune(src[nSrc:]) {
+			return nDst, nSrc, transform.ErrShortSrc
+		}
+
+		r, size := utf8.DecodeRune(src[nSrc:])
+		b, ok := e.encoding.reverse[r]
+		if !ok {
+			return nDst, nSrc, errUnmappableRune
+		}
+
+		if nDst >= len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+
+		dst[nDst] = b
+		nDst++
+		nSrc += size
+	}
+
+	return nDst, nSrc, nil
+}
+
+// RegisterSingleByteEncoding registers a single-byte encoding, mapping each byte
+// to the rune at its index in the given table, under the given name.
+//
+// Once registered, the encoding can be used by TextDecoder instances constructed
+// with its name as label.
+func RegisterSingleByteEncoding(name string, table []rune) error {
+	name = normalizeLabel(name)
+	if name == "" {
+		return NewError(RangeError, "unable to register encoding; reason: name is empty")
+	}
+
+	e, err := newSingleByteEncoding(table)
+	if err != nil {
+		return err
+	}
+
+	return registerEncoding(&encodingEntry{
+		name:        name,
+		labels:      []string{name},
+		newEncoding: func() encoding.Encoding { return e },
+	})
+}
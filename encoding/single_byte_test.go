@@ -0,0 +1,101 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterSingleByteEncoding(t *testing.T) {
+	t.Parallel()
+
+	t.Run("decoding with a registered encoding", func(t *testing.T) {
+		t.Parallel()
+
+		table := make([]rune, 256)
+		for i := range table {
+			table[i] = rune(i)
+		}
+		table[0x80] = 0x20AC
+
+		require.NoError(t, RegisterSingleByteEncoding("X-Toy-Decode", table))
+
+		td, err := NewTextDecoder(goja.New(), " x-toy-decode ", textDecoderOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "x-toy-decode", td.Encoding)
+
+		decoded, err := td.Decode([]byte{0x61, 0x80, 0x62}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "a\u20ACb", decoded)
+	})
+
+	t.Run("table with an invalid length", func(t *testing.T) {
+		t.Parallel()
+
+		err := RegisterSingleByteEncoding("x-toy-short", make([]rune, 255))
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+	})
+
+	t.Run("table with an invalid rune", func(t *testing.T) {
+		t.Parallel()
+
+		table := make([]rune, 256)
+		table[0x80] = 0xD800
+
+		err := RegisterSingleByteEncoding("x-toy-invalid", table)
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+	})
+
+	t.Run("built-in encoding name", func(t *testing.T) {
+		t.Parallel()
+
+		err := RegisterSingleByteEncoding("utf-8", make([]rune, 256))
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+	})
+
+	t.Run("code page identifier", func(t *testing.T) {
+		t.Parallel()
+
+		err := RegisterSingleByteEncoding("1252", make([]rune, 256))
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+
+		name, err := CanonicalName("1252")
+		require.NoError(t, err)
+		assert.Equal(t, Windows1252EncodingFormat, name)
+	})
+
+	t.Run("from JS", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const table = [];
+			for (let i = 0; i < 256; i++) {
+				table.push(i);
+			}
+			table[0x80] = 0x263A;
+
+			registerSingleByteEncoding("x-toy-js", table);
+
+			const decoder = new TextDecoder("x-toy-js");
+			assert_equals(decoder.encoding, "x-toy-js", "encoding should be the registered name");
+			assert_equals(decoder.decode(new Uint8Array([0x61, 0x80])), "a\u263A", "0x80 should decode to the registered rune");
+		`)
+		assert.NoError(t, err)
+	})
+}
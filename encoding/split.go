@@ -0,0 +1,125 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/transform"
+)
+
+// SplitEncode encodes the given text using the encoding the given label
+// resolves to, and splits the encoded bytes into chunks of at most maxBytes
+// bytes, for transmission over size-limited frames.
+//
+// Chunks only ever end on a character boundary, so that their concatenation
+// is the encoded text, and, for stateless encodings, each of them decodes on
+// its own. The text is encoded one character at a time, with a single encoder,
+// so that stateful encodings, such as iso-2022-jp, switch their state as
+// encoding the whole text at once would. As a result, their chunks do not
+// repeat the escape sequence of the state they start in, and only decode
+// along with the chunks preceding them, as a stream.
+//
+// It fails with a RangeError should maxBytes be lower than one, or should a
+// single character encode to more than maxBytes bytes.
+func SplitEncode(text string, maxBytes int, label string) ([][]byte, error) {
+	if maxBytes < 1 {
+		return nil, NewError(RangeError, fmt.Sprintf("unable to split encoded text; reason: maxBytes must be positive, got %d", maxBytes))
+	}
+
+	te, err := NewTextEncoder(label, textEncoderOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		chunks  [][]byte
+		current []byte
+		enc     = te.newEncoder()
+	)
+
+	// appendPiece appends the given encoded piece to the current
+	// chunk, or to a new one should the current chunk be full.
+	appendPiece := func(piece []byte, description string) error {
+		if len(piece) > maxBytes {
+			return NewError(RangeError, fmt.Sprintf(
+				"unable to split encoded text; reason: %s encodes to %d bytes, more than maxBytes (%d)",
+				description, len(piece), maxBytes,
+			))
+		}
+
+		if len(current)+len(piece) > maxBytes {
+			chunks = append(chunks, current)
+			current = nil
+		}
+
+		current = append(current, piece...)
+
+		return nil
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		// Invalid bytes are encoded as the replacement character the
+		// encoder substitutes for them, which, unlike a lone byte, is
+		// never held back as the start of an incomplete sequence.
+		src := []byte(text[i : i+size])
+		if r == utf8.RuneError {
+			src = []byte(string(utf8.RuneError))
+		}
+
+		piece, err := transformPiece(enc, src, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := appendPiece(piece, fmt.Sprintf("character U+%04X", r)); err != nil {
+			return nil, err
+		}
+
+		i += size
+	}
+
+	// Flush the encoder, which might end the output with the
+	// bytes returning stateful encodings to their initial state.
+	tail, err := transformPiece(enc, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tail) > 0 {
+		if err := appendPiece(tail, "the end of the text"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks, nil
+}
+
+// transformPiece returns the bytes the given encoder transforms the given
+// piece of text to, which is expected to be consumed whole.
+func transformPiece(enc transform.Transformer, piece []byte, atEOF bool) ([]byte, error) {
+	var (
+		dst [32]byte
+		out []byte
+	)
+	for {
+		nDst, nSrc, err := enc.Transform(dst[:], piece, atEOF)
+		out = append(out, dst[:nDst]...)
+		piece = piece[nSrc:]
+
+		switch {
+		case errors.Is(err, transform.ErrShortDst):
+			continue
+		case err != nil:
+			return nil, NewError(TypeError, "unable to encode text; reason: "+err.Error())
+		default:
+			return out, nil
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package encoding
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitEncode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		text     string
+		maxBytes int
+		label    string
+		want     [][]byte
+	}{
+		{
+			name:     "empty text",
+			text:     "",
+			maxBytes: 4,
+			label:    "utf-8",
+			want:     nil,
+		},
+		{
+			name:     "ascii",
+			text:     "abcde",
+			maxBytes: 2,
+			label:    "utf-8",
+			want:     [][]byte{[]byte("ab"), []byte("cd"), []byte("e")},
+		},
+		{
+			name:     "multi-byte character straddling the boundary",
+			text:     "ab\u6C34",
+			maxBytes: 4,
+			label:    "utf-8",
+			want:     [][]byte{[]byte("ab"), {0xE6, 0xB0, 0xB4}},
+		},
+		{
+			name:     "multi-byte character ending on the boundary",
+			text:     "a\u6C34b",
+			maxBytes: 4,
+			label:    "utf-8",
+			want:     [][]byte{{0x61, 0xE6, 0xB0, 0xB4}, []byte("b")},
+		},
+		{
+			name:     "four-byte characters",
+			text:     "\U0001F600\U0001F600",
+			maxBytes: 7,
+			label:    "utf-8",
+			want:     [][]byte{{0xF0, 0x9F, 0x98, 0x80}, {0xF0, 0x9F, 0x98, 0x80}},
+		},
+		{
+			name:     "utf-16le surrogate pair",
+			text:     "a\U0001D11E",
+			maxBytes: 5,
+			label:    "utf-16le",
+			want:     [][]byte{{0x61, 0x00}, {0x34, 0xD8, 0x1E, 0xDD}},
+		},
+		{
+			name:     "windows-1252",
+			text:     "caf\u00E9",
+			maxBytes: 3,
+			label:    "windows-1252",
+			want:     [][]byte{[]byte("caf"), {0xE9}},
+		},
+		{
+			name:     "invalid utf-8 substituted",
+			text:     "a\xFFb",
+			maxBytes: 3,
+			label:    "utf-8",
+			want:     [][]byte{{0x61}, {0xEF, 0xBF, 0xBD}, {0x62}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			chunks, err := SplitEncode(tc.text, tc.maxBytes, tc.label)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, chunks)
+		})
+	}
+
+	t.Run("chunks decode on their own", func(t *testing.T) {
+		t.Parallel()
+
+		text := strings.Repeat("a\u00E9\u6C34\U0001F600", 50)
+
+		for maxBytes := 4; maxBytes <= 16; maxBytes++ {
+			chunks, err := SplitEncode(text, maxBytes, "utf-8")
+			require.NoError(t, err)
+
+			var decoded strings.Builder
+			for _, chunk := range chunks {
+				assert.LessOrEqual(t, len(chunk), maxBytes)
+
+				td, err := NewTextDecoder(nil, "utf-8", textDecoderOptions{Fatal: true})
+				require.NoError(t, err)
+
+				part, err := td.Decode(chunk, decodeOptions{})
+				require.NoError(t, err, "each chunk should be valid on its own")
+
+				decoded.WriteString(part)
+			}
+
+			assert.Equal(t, text, decoded.String())
+		}
+	})
+
+	t.Run("stateful encoding", func(t *testing.T) {
+		t.Parallel()
+
+		text := "a\u3042\u3044b"
+
+		te, err := NewTextEncoder("iso-2022-jp", textEncoderOptions{})
+		require.NoError(t, err)
+
+		want, err := te.Encode(text)
+		require.NoError(t, err)
+
+		chunks, err := SplitEncode(text, 5, "iso-2022-jp")
+		require.NoError(t, err)
+
+		for _, chunk := range chunks {
+			assert.LessOrEqual(t, len(chunk), 5)
+		}
+
+		assert.Equal(t, want, bytes.Join(chunks, nil), "the chunks should join to the encoded text")
+
+		td, err := NewTextDecoder(nil, "iso-2022-jp", textDecoderOptions{})
+		require.NoError(t, err)
+
+		var decoded strings.Builder
+		for _, chunk := range chunks {
+			part, err := td.Decode(chunk, decodeOptions{Stream: true})
+			require.NoError(t, err)
+
+			decoded.WriteString(part)
+		}
+		assert.Equal(t, text, decoded.String(), "the chunks should decode as a stream")
+
+		// The third chunk continues the state the second one switched to,
+		// without repeating its escape sequence, and is thus decoded as
+		// ASCII bytes on its own.
+		require.Len(t, chunks, 4)
+
+		td, err = NewTextDecoder(nil, "iso-2022-jp", textDecoderOptions{})
+		require.NoError(t, err)
+
+		alone, err := td.Decode(chunks[2], decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "$$", alone)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			name     string
+			text     string
+			maxBytes int
+			label    string
+			want     ErrorName
+		}{
+			{name: "character larger than maxBytes", text: "ab\u6C34", maxBytes: 2, label: "utf-8", want: RangeError},
+			{name: "zero maxBytes", text: "a", maxBytes: 0, label: "utf-8", want: RangeError},
+			{name: "negative maxBytes", text: "a", maxBytes: -1, label: "utf-8", want: RangeError},
+			{name: "unsupported label", text: "a", maxBytes: 4, label: "bogus-label", want: RangeError},
+			{name: "unmappable character", text: "\u6C34", maxBytes: 4, label: "windows-1252", want: TypeError},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				_, err := SplitEncode(tc.text, tc.maxBytes, tc.label)
+
+				var encodingErr *Error
+				require.ErrorAs(t, err, &encodingErr)
+				assert.Equal(t, tc.want, encodingErr.Name)
+			})
+		}
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const chunks = splitEncode("ab\u6c34\u{1f600}", 4, "utf-8");
+			assert_equals(chunks.length, 3, "number of chunks");
+			assert_true(chunks[0] instanceof Uint8Array, "chunks should be Uint8Arrays");
+			assert_equals(chunks.map((chunk) => chunk.length).join(), "2,3,4", "chunk lengths");
+			assert_equals(chunks.map((chunk) => new TextDecoder().decode(chunk)).join(""), "ab\u6c34\u{1f600}");
+
+			assert_equals(splitEncode("", 4, "utf-8").length, 0, "empty text");
+
+			let error;
+			try {
+				splitEncode("\u6c34", 2, "utf-8");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof RangeError, "a character larger than maxBytes should throw a RangeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
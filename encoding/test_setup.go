@@ -62,6 +62,7 @@ func newTestSetup(t testing.TB) testSetup {
 	require.NoError(t, err)
 
 	samples := make(chan metrics.SampleContainer, 1000)
+	registry := metrics.NewRegistry()
 
 	state := &lib.State{
 		Group:  root,
@@ -77,19 +78,28 @@ func newTestSetup(t testing.TB) testSetup {
 		},
 		Samples:        samples,
 		TLSConfig:      tb.TLSClientConfig,
-		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
-		Tags:           lib.NewVUStateTags(metrics.NewRegistry().RootTagSet()),
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(registry),
+		Tags:           lib.NewVUStateTags(registry.RootTagSet()),
 	}
 
+	// The module instance is created in the init context, where the
+	// metrics registry is available, and used in the VU context, once
+	// the VU state is set.
 	vu := &modulestest.VU{
-		CtxField:     tb.Context,
-		InitEnvField: &common.InitEnvironment{},
+		CtxField: tb.Context,
+		InitEnvField: &common.InitEnvironment{
+			TestPreInitState: &lib.TestPreInitState{Registry: registry},
+		},
 		RuntimeField: rt,
-		StateField:   state,
 	}
 
 	m := new(RootModule).NewModuleInstance(vu)
-	require.NoError(t, rt.Set("TextDecoder", m.Exports().Named["TextDecoder"]))
+	for name, export := range m.Exports().Named {
+		require.NoError(t, rt.Set(name, export))
+	}
+
+	vu.InitEnvField = nil
+	vu.StateField = state
 
 	ev := eventloop.New(vu)
 	vu.RegisterCallbackField = ev.RegisterCallback
@@ -297,32 +297,32 @@ const encodings_table = [
   //   ],
   //   heading: "Legacy multi-byte Chinese (traditional) encodings",
   // },
-  // {
-  //   encodings: [
-  //     {
-  //       labels: ["cseucpkdfmtjapanese", "euc-jp", "x-euc-jp"],
-  //       name: "EUC-JP",
-  //     },
-  //     {
-  //       labels: ["csiso2022jp", "iso-2022-jp"],
-  //       name: "ISO-2022-JP",
-  //     },
-  //     {
-  //       labels: [
-  //         "csshiftjis",
-  //         "ms932",
-  //         "ms_kanji",
-  //         "shift-jis",
-  //         "shift_jis",
-  //         "sjis",
-  //         "windows-31j",
-  //         "x-sjis",
-  //       ],
-  //       name: "Shift_JIS",
-  //     },
-  //   ],
-  //   heading: "Legacy multi-byte Japanese encodings",
-  // },
+  {
+    encodings: [
+      {
+        labels: ["cseucpkdfmtjapanese", "euc-jp", "x-euc-jp"],
+        name: "EUC-JP",
+      },
+      {
+        labels: ["csiso2022jp", "iso-2022-jp"],
+        name: "ISO-2022-JP",
+      },
+      {
+        labels: [
+          "csshiftjis",
+          "ms932",
+          "ms_kanji",
+          "shift-jis",
+          "shift_jis",
+          "sjis",
+          "windows-31j",
+          "x-sjis",
+        ],
+        name: "Shift_JIS",
+      },
+    ],
+    heading: "Legacy multi-byte Japanese encodings",
+  },
   // {
   //   encodings: [
   //     {
@@ -1,16 +1,28 @@
 package encoding
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
 	"golang.org/x/text/encoding"
-	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/transform"
 )
 
+// maxSingleByteExpansion is the maximum number of bytes the UTF-8 encoding
+// of a character of the single-byte encodings of the golang.org/x/text
+// packages takes, as all of them belong to the Basic Multilingual Plane.
+const maxSingleByteExpansion = 3
+
 // TextDecoder represents a decoder for a specific text encoding, such
 // as UTF-8, UTF-16, ISO-8859-2, etc.
 //
@@ -26,48 +38,1137 @@ type TextDecoder struct {
 	// IgnoreBOM holds a boolean indicating whether the byte order mark is ignored.
 	IgnoreBOM bool
 
+	// AutoReBOM holds a boolean indicating whether a UTF-8 decoder switches
+	// to UTF-16 for the streams starting with a UTF-16 byte order mark.
+	AutoReBOM bool
+
+	// DefaultStream holds a boolean indicating whether decode calls
+	// omitting the stream option decode in streaming mode.
+	DefaultStream bool
+
+	// ResetOnError holds a boolean indicating whether a failed decode
+	// call resets the decoder, discarding the state of the current stream.
+	ResetOnError bool
+
+	// Newline holds the policy applied to the line endings of decoded text.
+	Newline NewlinePolicy
+
+	// LoneSurrogates holds the policy applied to the unpaired
+	// surrogate code units of UTF-16 input.
+	LoneSurrogates LoneSurrogatesPolicy
+
+	// mu guards the state of the decoder, so that it can be shared by
+	// goroutines, their calls being handled one at a time.
+	mu sync.Mutex
+
 	decoder   encoding.Encoding
 	transform transform.Transformer
 
+	// buffer holds the trailing bytes of an incomplete sequence
+	// left over by the previous streaming decode call.
+	buffer []byte
+
+	// bomSeen indicates whether the start of the current stream, where
+	// a byte order mark would be found, has already been processed.
+	bomSeen bool
+
+	// skipLF indicates whether the previous streaming decode call ended
+	// with a carriage return, normalized already, so that a line feed
+	// the current call starts with belongs to the same line ending.
+	skipLF bool
+
+	// reBOMFrom holds the name of the encoding the decoder switched
+	// from for the current stream, as its byte order mark told it was
+	// UTF-16, if it did.
+	reBOMFrom EncodingName
+
+	// scratch holds the grow-only destination buffer decode calls
+	// write the decoded bytes to, before copying them out.
+	scratch []byte
+
+	// released indicates whether the decoder was handed back to the
+	// pool, which makes its decode calls fail.
+	released bool
+
+	// substitutions holds the number of replacement characters
+	// the last decode call substituted for invalid input.
+	substitutions int
+
+	// loneSurrogates holds the number of unpaired surrogate
+	// code units of the UTF-16 input of the last decode call.
+	loneSurrogates int
+
+	// strippedBOM holds the name of the encoding whose byte order
+	// mark the last decode call stripped, if any.
+	strippedBOM EncodingName
+
+	// malformedRegions holds the regions of the input the last decode
+	// call substituted replacement characters for, when requested.
+	malformedRegions []MalformedRegion
+
+	// asciiCompatible indicates whether the encoding decodes
+	// bytes below 0x80 to the ASCII characters of the same value.
+	asciiCompatible bool
+
+	// singleByte indicates whether the encoding decodes
+	// each byte to a single character.
+	singleByte bool
+
 	rt *goja.Runtime
 }
 
 // Decode takes a byte stream as input and returns a string.
+//
+// When decoding in streaming mode, the trailing bytes of an incomplete
+// sequence are buffered, and prepended to the input of the next call.
+//
+// A call made without streaming mode is always terminal: it flushes the
+// stream, and resets the decoder state, regardless of whether it was made
+// in the middle of a streaming session. Any bytes buffered beforehand are
+// decoded along with its input, and any state carried by the decoder, such
+// as the current shift state of stateful encodings, is discarded. The next
+// call thus starts a new stream.
 func (td *TextDecoder) Decode(buffer []byte, options decodeOptions) (string, error) {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	return td.decode(buffer, options)
+}
+
+// decode implements Decode, the caller holding the decoder's lock.
+//
+// In fatal mode, input holding malformed sequences is an error. Should a call
+// fail, the decoder is reset, unless told otherwise, so that it starts a new
+// stream with the next call, rather than carry on with what the failed call
+// left of the current one.
+func (td *TextDecoder) decode(buffer []byte, options decodeOptions) (string, error) {
+	// Non-streaming calls reset the decoder before returning, the
+	// line ending state of the current stream is thus read upfront.
+	skipLF := td.skipLF
+
+	decoded, err := td.decodeChunk(buffer, options)
+	switch {
+	case err != nil:
+	case td.loneSurrogates > 0 && (options.fatal(td.Fatal) || td.LoneSurrogates == LoneSurrogatesError):
+		err = NewError(TypeError, "unable to decode text; reason: input holds unpaired surrogates")
+	case options.fatal(td.Fatal) && td.substitutions > 0:
+		err = NewError(TypeError, "unable to decode text; reason: input holds malformed sequences")
+	}
+
+	if err != nil {
+		if td.ResetOnError {
+			td.reset()
+		}
+
+		return "", err
+	}
+
+	decoded, td.skipLF = normalizeNewlines(decoded, td.Newline, skipLF)
+	if !options.Stream && !options.ReturnRemainder {
+		td.skipLF = false
+	}
+
+	return decoded, nil
+}
+
+// decodeChunk decodes the given chunk of the current stream, substituting
+// replacement characters for the malformed sequences it holds.
+func (td *TextDecoder) decodeChunk(buffer []byte, options decodeOptions) (string, error) {
 	if td.decoder == nil {
 		return "", errors.New("encoding not set")
 	}
 
-	// Create a transformer that will remove the BOM if IgnoreBOM is false.
+	if td.released {
+		return "", NewError(TypeError, "unable to decode text; reason: the decoder was released")
+	}
+
+	td.substitutions = 0
+	td.loneSurrogates = 0
+	td.strippedBOM = ""
+	td.malformedRegions = nil
+
+	// Returning the remainder to the caller is a streaming mode
+	if options.ReturnRemainder {
+		options.Stream = true
+	}
+
+	if options.MaxBytes != nil && *options.MaxBytes < 0 {
+		return "", NewError(RangeError, fmt.Sprintf("unable to decode text; reason: negative maxBytes: %d", *options.MaxBytes))
+	}
+	buffer = options.limit(buffer)
+
+	// origin holds the offset, relative to the given buffer, of the data
+	// being decoded, which starts with the bytes buffered by a previous
+	// streaming call, if any, and is stripped of its byte order mark.
+	origin := -len(td.buffer)
+
+	// Prepend the bytes buffered by a previous streaming call, if any.
+	data := buffer
+	if len(td.buffer) > 0 {
+		data = append(td.buffer, buffer...)
+		td.buffer = nil
+	}
+
+	// Reset the decoder state when not streaming
+	if !options.Stream {
+		defer td.reset()
+	}
+
+	// Decode the stream as UTF-16 instead of UTF-8 when it starts with a
+	// UTF-16 byte order mark, as mislabeled data sometimes does.
+	if td.AutoReBOM && !td.bomSeen && td.Encoding == UTF8EncodingFormat {
+		if options.Stream && len(data) < 2 && (bytes.HasPrefix(byteOrderMark(UTF16LEEncodingFormat), data) ||
+			bytes.HasPrefix(byteOrderMark(UTF16BEEncodingFormat), data)) {
+			// Too few bytes were received to tell whether the stream starts
+			// with a UTF-16 byte order mark yet, hold on to them.
+			td.buffer = append([]byte{}, data...)
+			return "", nil
+		}
+
+		td.switchOnUTF16BOM(data)
+	}
+
+	// Strip the byte order mark the stream starts with, unless ignored.
 	//
-	// Note that BOM removal only applies to Unicode.
-	var transformer transform.Transformer
-	if !td.IgnoreBOM {
-		transformer = unicode.BOMOverride(td.decoder.NewDecoder())
+	// Note that only the byte order mark of the decoder's encoding is
+	// stripped, as per the specification, any other being decoded as is.
+	if !td.IgnoreBOM && !td.bomSeen {
+		bom := byteOrderMark(td.Encoding)
+		if options.Stream && len(data) < len(bom) && bytes.HasPrefix(bom, data) {
+			// Too few bytes were received to tell whether the stream starts with
+			// a byte order mark yet, hold on to them until more are received.
+			td.buffer = append([]byte{}, data...)
+			return "", nil
+		}
+
+		td.bomSeen = true
+		if len(bom) > 0 && bytes.HasPrefix(data, bom) {
+			td.strippedBOM = td.Encoding
+			data = data[len(bom):]
+			origin += len(bom)
+		}
+	}
+
+	if !options.Stream && options.ErrorOnTruncated && td.endsWithTruncatedSequence(data) {
+		return "", NewError(TypeError, "unable to decode text; reason: input ends with a truncated sequence")
+	}
+
+	var incomplete []byte
+	switch {
+	case td.singleByte:
+		// Single-byte encodings decode each byte on its own, and thus
+		// never hold back, nor flush, the bytes of an incomplete sequence.
+	case options.Stream && td.Encoding == UTF8EncodingFormat:
+		// When streaming UTF-8, hold back a trailing incomplete sequence,
+		// so that it is decoded once the rest of its bytes are received.
+		data, incomplete = separateIncompleteUTF8Sequences(data)
+	case !options.Stream && (td.Encoding == UTF16LEEncodingFormat || td.Encoding == UTF16BEEncodingFormat):
+		// When flushing UTF-16, only decode the complete code units, the
+		// bytes left, if any, being substituted with a single replacement
+		// character, as per the specification.
+		data, incomplete = separateIncompleteUTF16Sequences(data, td.Encoding == UTF16BEEncodingFormat)
+
+		// Bytes left beyond an odd trailing one start with a high
+		// surrogate, necessarily unpaired as it ends the input.
+		if len(incomplete) >= 2 {
+			td.loneSurrogates = 1
+		}
+
+		// In fatal mode, the bytes left are an error instead.
+		if options.fatal(td.Fatal) && len(incomplete) > 0 {
+			return "", NewError(TypeError, "unable to decode text; reason: input ends with a truncated code unit")
+		}
+	}
+
+	// Short-circuit empty input, sparing the allocation of both the
+	// transformer and the destination buffer.
+	if len(data) == 0 {
+		if options.Stream {
+			td.buffer = append([]byte{}, incomplete...)
+			return "", nil
+		}
+
+		if len(incomplete) > 0 {
+			td.substitutions = 1
+			if options.ReportErrors {
+				td.malformedRegions = []MalformedRegion{{Offset: origin, ByteLength: len(incomplete)}}
+			}
+
+			if options.EscapeInvalid {
+				return string(appendEscapedBytes(nil, incomplete)), nil
+			}
+
+			return string(utf8.RuneError), nil
+		}
+
+		return "", nil
+	}
+
+	// Pure ASCII input decodes to itself in ASCII-compatible encodings,
+	// sparing the transformation and its destination buffer.
+	if td.asciiCompatible && len(incomplete) == 0 && isASCII(data) {
+		return foldCase(mapControlCharacters(string(data), options.ControlBytes), options.CaseFold), nil
+	}
+
+	// Single-byte encodings decode each byte to a character of up to three
+	// bytes in UTF-8, sizing the destination for the worst case spares growing
+	// it, and copying what was decoded so far, in the middle of the transform.
+	if td.singleByte && cap(td.scratch) < maxSingleByteExpansion*len(data) {
+		td.scratch = make([]byte, maxSingleByteExpansion*len(data))
+	}
+
+	var (
+		decoded []byte
+		n       int
+		err     error
+	)
+	if td.Encoding == UTF8EncodingFormat {
+		// The trailing incomplete sequence being held back already when
+		// streaming, what is left decodes as at the end of the input, so
+		// that the malformed sequences it ends with, which the transformer
+		// cannot tell from incomplete ones otherwise, are not held back.
+		decoded, n, err = transformUTF8Runs(td.transform, td.scratch, data, true)
 	} else {
-		transformer = td.decoder.NewDecoder()
+		decoded, n, err = transformBytes(td.transform, td.scratch, data, !options.Stream)
 	}
+	if err != nil {
+		return "", NewError(TypeError, "unable to decode text; reason: "+err.Error())
+	}
+
+	// Replacement characters the input encodes as such are not substitutions
+	td.substitutions = bytes.Count(decoded, []byte(string(utf8.RuneError))) - td.countReplacementCharacters(data[:n])
 
-	var decoded string
-	var err error
+	// UTF-16 decoders substitute replacement characters for unpaired
+	// surrogates only, the truncated code unit a flush might leave
+	// being substituted below.
+	if _, isUTF16 := utf16ByteOrder(td.Encoding); isUTF16 {
+		td.loneSurrogates += td.substitutions
+	}
+
+	// Only look for the malformed regions when there are some
+	if options.ReportErrors && td.substitutions > 0 {
+		td.malformedRegions = td.findMalformedRegions(data[:n], origin)
+	}
+
+	// Decode the data again, escaping the malformed sequences, when there are some
+	if options.EscapeInvalid && td.substitutions > 0 {
+		decoded = td.escapeMalformed(decoded, data[:n])
+	}
+
+	if !options.Stream && len(incomplete) > 0 {
+		if options.EscapeInvalid {
+			decoded = appendEscapedBytes(decoded, incomplete)
+		} else {
+			decoded = utf8.AppendRune(decoded, utf8.RuneError)
+		}
+		td.substitutions++
+
+		if options.ReportErrors {
+			td.malformedRegions = append(td.malformedRegions, MalformedRegion{
+				Offset:     origin + len(data),
+				ByteLength: len(incomplete),
+			})
+		}
+	}
+
+	// Hold on to the destination buffer, so that it is reused by the
+	// next call, the decoded string being a copy of its content.
+	td.scratch = decoded
+
+	// The transformer leaves the trailing bytes it cannot decode yet
+	// unconsumed, such as a Shift_JIS lead byte ending the chunk, while
+	// it decodes single-byte characters right away.
 	if options.Stream {
-		if td.transform == nil {
-			td.transform = transformer
+		td.buffer = append(append([]byte{}, data[n:]...), incomplete...)
+	}
+
+	return foldCase(mapControlCharacters(string(decoded), options.ControlBytes), options.CaseFold), nil
+}
+
+// Substitutions returns the number of replacement characters the last
+// decode call substituted for invalid or truncated input. The chunks of
+// a DecodeAll or DecodeWithCallback call count as a single decode call.
+//
+// Replacement characters the input validly encodes as such do not count.
+func (td *TextDecoder) Substitutions() int {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	return td.substitutions
+}
+
+// StrippedBOM returns the name of the encoding whose byte order mark the
+// last decode call stripped from the start of the stream, or an empty
+// string if it stripped none.
+//
+// Byte order marks are only stripped at the start of a stream, and only
+// when the decoder does not ignore them.
+func (td *TextDecoder) StrippedBOM() EncodingName {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	return td.strippedBOM
+}
+
+// MalformedRegions returns the regions of the input the last decode call
+// substituted replacement characters for, provided it was asked to report
+// them, in the order they appear in the input.
+func (td *TextDecoder) MalformedRegions() []MalformedRegion {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	return td.malformedRegions
+}
+
+// MalformedRegion describes a malformed sequence of decoded data,
+// which was substituted with a replacement character.
+type MalformedRegion struct {
+	// Offset holds the offset, in bytes, of the malformed sequence from the
+	// start of the buffer given to the decode call. It is negative when the
+	// sequence starts in bytes buffered by a previous streaming call.
+	Offset int `js:"offset"`
+
+	// ByteLength holds the length, in bytes, of the malformed sequence.
+	ByteLength int `js:"byteLength"`
+}
+
+// findMalformedRegions returns the regions of the given data, made of complete
+// sequences only, a fresh transformer substitutes replacement characters for,
+// offset by the given origin.
+func (td *TextDecoder) findMalformedRegions(data []byte, origin int) []MalformedRegion {
+	var regions []MalformedRegion
+	td.decodeCharacters(data, func(offset int, _, source []byte, substituted bool) {
+		if substituted {
+			regions = append(regions, MalformedRegion{Offset: origin + offset, ByteLength: len(source)})
 		}
+	})
 
-		decoded, _, err = transform.String(td.transform, string(buffer))
-	} else {
-		decoded, _, err = transform.String(transformer, string(buffer))
+	return regions
+}
+
+// decodeCharacters decodes the given data, made of complete sequences only,
+// with a fresh transformer, and calls fn with each decoded character, along
+// with the offset and bytes of the data it originates from, and whether it is
+// a replacement character substituted for them.
+//
+// The transformer is given room for a single character at a time, so that the
+// bytes it consumes to write each of them are known.
+func (td *TextDecoder) decodeCharacters(data []byte, fn func(offset int, decoded, source []byte, substituted bool)) {
+	t := td.decoder.NewDecoder()
+
+	dest := make([]byte, 2*utf8.UTFMax)
+	for offset := 0; offset < len(data); {
+		var (
+			nDest, nSrc int
+			err         error
+		)
+		for size := 1; size <= len(dest); size++ {
+			nDest, nSrc, err = t.Transform(dest[:size], data[offset:], true)
+			if nDest > 0 || !errors.Is(err, transform.ErrShortDst) {
+				break
+			}
+
+			// Bytes consumed without writing anything, such as the
+			// escape sequences of stateful encodings, are not retried.
+			offset += nSrc
+		}
+
+		if nDest == 0 && nSrc == 0 {
+			break
+		}
+
+		source := data[offset : offset+nSrc]
+		substituted := bytes.Count(dest[:nDest], []byte(string(utf8.RuneError))) > td.countReplacementCharacters(source)
+		fn(offset, dest[:nDest], source, substituted)
+
+		offset += nSrc
+	}
+}
+
+// countReplacementCharacters returns the number of replacement characters
+// the given data, made of complete sequences only, validly encodes.
+//
+// Only the Unicode encodings can encode the replacement character.
+func (td *TextDecoder) countReplacementCharacters(data []byte) int {
+	switch td.Encoding {
+	case UTF8EncodingFormat:
+		// The sequence starts with a lead byte, and is thus never
+		// part of a preceding sequence, be it valid or not.
+		return bytes.Count(data, []byte(string(utf8.RuneError)))
+	case UTF16LEEncodingFormat, UTF16BEEncodingFormat:
+		var count int
+		for i := 0; i+1 < len(data); i += 2 {
+			if (td.Encoding == UTF16LEEncodingFormat && data[i] == 0xFD && data[i+1] == 0xFF) ||
+				(td.Encoding == UTF16BEEncodingFormat && data[i] == 0xFF && data[i+1] == 0xFD) {
+				count++
+			}
+		}
+
+		return count
+	default:
+		return 0
+	}
+}
 
-		// Reset the transformer when not streaming
-		td.transform = nil
+// mapControlCharacters applies the given policy to the C0 and C1 control
+// characters of the given text, as well as to the delete character.
+//
+// The tab, line feed and carriage return characters are left untouched,
+// as they are part of the layout of the text rather than noise.
+func mapControlCharacters(text string, policy ControlBytesPolicy) string {
+	if policy == "" || policy == ControlBytesKeep {
+		return text
 	}
 
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsControl(r) || r == '\t' || r == '\n' || r == '\r' {
+			return r
+		}
+
+		if policy == ControlBytesStrip {
+			return -1
+		}
+
+		return utf8.RuneError
+	}, text)
+}
+
+// foldCase folds the given text to the case the given policy holds.
+//
+// Characters are mapped one at a time, without regard to the language,
+// so that folding the chunks of a stream equals folding the whole text:
+// the Turkish dotted capital I is lowercased to an ASCII i, for instance.
+func foldCase(text string, policy CaseFoldPolicy) string {
+	switch policy {
+	case CaseFoldLower:
+		return strings.ToLower(text)
+	case CaseFoldUpper:
+		return strings.ToUpper(text)
+	case CaseFoldASCIILower, CaseFoldASCIIUpper:
+		return strings.Map(func(r rune) rune {
+			switch {
+			case policy == CaseFoldASCIILower && 'A' <= r && r <= 'Z':
+				return r + 'a' - 'A'
+			case policy == CaseFoldASCIIUpper && 'a' <= r && r <= 'z':
+				return r - ('a' - 'A')
+			default:
+				return r
+			}
+		}, text)
+	default:
+		return text
+	}
+}
+
+// normalizeNewlines applies the given policy to the line endings of the given
+// text, a chunk of the current stream, and returns whether the normalized text
+// ends with a carriage return, whose line feed, if any, starts the next chunk.
+//
+// Should skipLF be true, the previous chunk ended with a carriage return, and
+// the line feed the text starts with, if any, is dropped, so that a CRLF line
+// ending split across chunks is normalized to a single line ending.
+func normalizeNewlines(text string, policy NewlinePolicy, skipLF bool) (string, bool) {
+	if policy == "" || policy == NewlineNone {
+		return text, false
+	}
+
+	if text == "" {
+		return text, skipLF
+	}
+
+	newline := "\n"
+	if policy == NewlineCRLF {
+		newline = "\r\n"
+	}
+
+	if skipLF && text[0] == '\n' {
+		text = text[1:]
+	}
+
+	// Text holding no carriage return has its line endings normalized
+	// to line feeds already, sparing the copy.
+	if policy == NewlineLF && strings.IndexByte(text, '\r') < 0 {
+		return text, false
+	}
+
+	var (
+		b       strings.Builder
+		endsCR  bool
+		pending int
+	)
+	b.Grow(len(text))
+
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\r':
+			b.WriteString(text[pending:i])
+			b.WriteString(newline)
+
+			if i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+
+			endsCR = i == len(text)-1 && text[i] == '\r'
+			pending = i + 1
+		case '\n':
+			b.WriteString(text[pending:i])
+			b.WriteString(newline)
+			pending = i + 1
+		}
+	}
+	b.WriteString(text[pending:])
+
+	return b.String(), endsCR
+}
+
+// DecodeConsumed decodes the given buffer as Decode does, and also returns
+// the number of bytes the decoded text originates from.
+//
+// Bytes buffered by a previous streaming call, and decoded by this one, count
+// as consumed by it, while bytes of the buffer held back awaiting the rest of
+// their sequence do not. Hence, summing the consumed bytes of successive calls
+// yields the offset of the decoded text's end in the source.
+func (td *TextDecoder) DecodeConsumed(buffer []byte, options decodeOptions) (string, int, error) {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	pending := len(td.buffer)
+	buffer = options.limit(buffer)
+
+	decoded, err := td.decode(buffer, options)
 	if err != nil {
-		return "", NewError(TypeError, "unable to decode text; reason: "+err.Error())
+		return "", 0, err
 	}
 
-	return decoded, nil
+	return decoded, pending + len(buffer) - len(td.buffer), nil
+}
+
+// DecodeAll decodes the given chunks as a single stream, and returns the
+// concatenation of the decoded text.
+//
+// Every chunk but the last is decoded as if streamed, and the last one
+// ends the stream, so that the decoder is always flushed, even when no
+// chunks are given.
+func (td *TextDecoder) DecodeAll(chunks [][]byte) (string, error) {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	if len(chunks) == 0 {
+		return td.decode(nil, decodeOptions{})
+	}
+
+	var (
+		text          strings.Builder
+		substitutions int
+	)
+	for i, chunk := range chunks {
+		decoded, err := td.decode(chunk, decodeOptions{Stream: i < len(chunks)-1})
+		if err != nil {
+			td.reset()
+			return "", err
+		}
+
+		text.WriteString(decoded)
+		substitutions += td.substitutions
+	}
+	td.substitutions = substitutions
+
+	return text.String(), nil
+}
+
+// DecodeWithCallback decodes the given data as a single stream, in chunks of
+// at most chunkSize bytes, and calls fn with the text each chunk decodes to,
+// sparing the accumulation of the whole decoded text.
+//
+// Pieces left empty, such as by a chunk ending in the middle of a sequence,
+// are not passed to fn. Should fn return an error, decoding stops, and the
+// decoder is reset. Note that fn must not use the decoder itself, which
+// stays locked until decoding ends.
+func (td *TextDecoder) DecodeWithCallback(data []byte, chunkSize int, fn func(piece string) error) error {
+	switch {
+	case chunkSize == 0:
+		chunkSize = defaultDecodeChunkSize
+	case chunkSize < 0:
+		return NewError(RangeError, fmt.Sprintf("unable to decode text; reason: chunkSize must be positive, got %d", chunkSize))
+	}
+
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	var substitutions int
+	for {
+		chunk := data
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+		data = data[len(chunk):]
+
+		// The last chunk, possibly empty, ends the stream
+		last := len(data) == 0
+
+		piece, err := td.decode(chunk, decodeOptions{Stream: !last})
+		if err != nil {
+			td.reset()
+			return err
+		}
+		substitutions += td.substitutions
+
+		if piece != "" {
+			if err := fn(piece); err != nil {
+				td.reset()
+				return err
+			}
+		}
+
+		if last {
+			td.substitutions = substitutions
+			return nil
+		}
+	}
+}
+
+// endsWithTruncatedSequence returns true if the given data ends with
+// an incomplete sequence of the text decoder's encoding.
+func (td *TextDecoder) endsWithTruncatedSequence(data []byte) bool {
+	if td.Encoding == UTF8EncodingFormat {
+		_, incomplete := separateIncompleteUTF8Sequences(data)
+		return len(incomplete) > 0
+	}
+
+	// Otherwise, let a fresh decoder tell whether it would need
+	// more bytes to consume the data in its entirety.
+	_, n, err := transformBytes(td.decoder.NewDecoder(), nil, data, false)
+
+	return err == nil && n < len(data)
+}
+
+// Clone returns an independent copy of the text decoder, carrying on
+// the current stream from the same state.
+//
+// The bytes buffered by previous streaming calls, and the options, are
+// copied. The state of the decoder's transformer is copied only when it
+// can be: the transformers of stateful encodings provided by the
+// golang.org/x/text packages, such as the shift state of iso-2022-jp, keep
+// it private, and the copy starts from their initial state instead.
+func (td *TextDecoder) Clone() *TextDecoder {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	clone := &TextDecoder{
+		Encoding:       td.Encoding,
+		Fatal:          td.Fatal,
+		IgnoreBOM:      td.IgnoreBOM,
+		AutoReBOM:      td.AutoReBOM,
+		DefaultStream:  td.DefaultStream,
+		ResetOnError:   td.ResetOnError,
+		Newline:        td.Newline,
+		LoneSurrogates: td.LoneSurrogates,
+		decoder:        td.decoder,
+		bomSeen:        td.bomSeen,
+		skipLF:         td.skipLF,
+		reBOMFrom:      td.reBOMFrom,
+
+		asciiCompatible: td.asciiCompatible,
+		singleByte:      td.singleByte,
+		rt:              td.rt,
+	}
+
+	if len(td.buffer) > 0 {
+		clone.buffer = append([]byte{}, td.buffer...)
+	}
+
+	clone.transform = td.decoder.NewDecoder()
+	if d, ok := td.transform.(*encoding.Decoder); ok {
+		if c, ok := d.Transformer.(transformerCloner); ok {
+			clone.transform = &encoding.Decoder{Transformer: c.clone()}
+		}
+	}
+
+	return clone
+}
+
+// transformerCloner is implemented by the transformers
+// whose state can be copied.
+type transformerCloner interface {
+	// clone returns a copy of the transformer, in the same state.
+	clone() transform.Transformer
+}
+
+// Reset discards the state of the current stream, if any, so that the
+// decoder can be reused, the next decode call starting a new stream, as
+// if made on a new decoder.
+//
+// The byte order mark the new stream starts with is thus handled as per
+// the ignoreBOM option, and the reports of the last decode call, such as
+// its number of substitutions, are discarded too.
+func (td *TextDecoder) Reset() {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	td.reset()
+	td.substitutions = 0
+	td.strippedBOM = ""
+	td.malformedRegions = nil
+}
+
+// reset discards the state of the current stream, so
+// that the next decode call starts a new stream.
+//
+// The caller must hold the decoder's lock.
+func (td *TextDecoder) reset() {
+	td.buffer = nil
+	td.bomSeen = false
+	td.skipLF = false
+
+	// Switch back to the encoding the decoder was constructed
+	// with, in case a byte order mark told it otherwise.
+	if td.reBOMFrom != "" {
+		entry, _ := lookupEncoding(td.reBOMFrom)
+		td.Encoding = entry.name
+		td.decoder = entry.newEncoding()
+		td.transform = td.decoder.NewDecoder()
+		td.asciiCompatible = entry.asciiCompatible
+		td.reBOMFrom = ""
+
+		return
+	}
+
+	td.transform.Reset()
+}
+
+// switchOnUTF16BOM switches the decoder to the UTF-16 encoding the byte order
+// mark the given data starts with belongs to, if any, for the current stream.
+func (td *TextDecoder) switchOnUTF16BOM(data []byte) {
+	for _, name := range []EncodingName{UTF16LEEncodingFormat, UTF16BEEncodingFormat} {
+		if !bytes.HasPrefix(data, byteOrderMark(name)) {
+			continue
+		}
+
+		entry, _ := lookupEncoding(name)
+		td.reBOMFrom = td.Encoding
+		td.Encoding = entry.name
+		td.decoder = entry.newEncoding()
+		td.transform = td.decoder.NewDecoder()
+		td.asciiCompatible = entry.asciiCompatible
+
+		return
+	}
+}
+
+// TakeRemainder returns the bytes of an incomplete sequence buffered by the
+// last streaming decode call, if any, and discards them, so that the caller
+// can prepend them to the next chunk instead.
+func (td *TextDecoder) TakeRemainder() []byte {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	remainder := td.buffer
+	td.buffer = nil
+
+	return remainder
+}
+
+// Pending returns true if the bytes of an incomplete sequence, received
+// by a streaming decode call, are buffered awaiting the rest of the sequence.
+func (td *TextDecoder) Pending() bool {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	return len(td.buffer) > 0
+}
+
+// transformBytes runs the given transformer over src, and returns the
+// transformed bytes along with the number of bytes of src consumed.
+//
+// Unlike [transform.Bytes], the transformer is not reset beforehand, which allows
+// it to carry its state over successive calls. When atEOF is false, the trailing
+// bytes the transformer could not process yet are left unconsumed.
+//
+// The transformed bytes are written to dest, which is grown as needed. Passing
+// the returned slice back as dest in subsequent calls allows reusing it.
+func transformBytes(t transform.Transformer, dest, src []byte, atEOF bool) ([]byte, int, error) {
+	// Size the destination after the source, which is exact for ASCII
+	// text, and let it grow for anything expanding when transformed.
+	if cap(dest) < len(src) {
+		dest = make([]byte, len(src))
+	}
+	dest = dest[:cap(dest)]
+
+	var nDest, nSrc int
+	for {
+		n, m, err := t.Transform(dest[nDest:], src[nSrc:], atEOF)
+		nDest += n
+		nSrc += m
+
+		switch {
+		case errors.Is(err, transform.ErrShortDst):
+			// Always leave room for at least one more replacement
+			// character, even when growing an empty destination.
+			grown := make([]byte, 2*len(dest)+utf8.UTFMax)
+			copy(grown, dest[:nDest])
+			dest = grown
+		case errors.Is(err, transform.ErrShortSrc) && !atEOF:
+			return dest[:nDest], nSrc, nil
+		case err != nil:
+			return nil, nSrc, err
+		default:
+			return dest[:nDest], nSrc, nil
+		}
+	}
+}
+
+// transformUTF8Runs decodes the given UTF-8 data as transformBytes does,
+// copying its runs of ASCII bytes as is, and only engaging the transformer
+// for the malformed regions of non-ASCII bytes in between.
+//
+// An ASCII byte is never part of a multi-byte sequence, and ends any
+// incomplete one it follows: each region thus decodes on its own as it
+// does along with the rest of the data, the last one aside, which might
+// hold an incomplete sequence to leave unconsumed when not at EOF.
+func transformUTF8Runs(t transform.Transformer, dest, src []byte, atEOF bool) ([]byte, int, error) {
+	if cap(dest) < len(src) {
+		dest = make([]byte, 0, len(src))
+	}
+	dest = dest[:0]
+
+	for start := 0; start < len(src); {
+		end := start
+		for end < len(src) && src[end] < utf8.RuneSelf {
+			end++
+		}
+
+		dest = append(dest, src[start:end]...)
+		if end == len(src) {
+			return dest, end, nil
+		}
+
+		start = end
+		for end < len(src) && src[end] >= utf8.RuneSelf {
+			end++
+		}
+
+		// Well-formed regions decode to themselves as well, the last
+		// one included, as a complete sequence is never held back.
+		region := src[start:end]
+		if utf8.Valid(region) {
+			dest = append(dest, region...)
+			start = end
+
+			continue
+		}
+
+		regionAtEOF := atEOF || end < len(src)
+
+		for {
+			nDest, nSrc, err := t.Transform(dest[len(dest):cap(dest)], region, regionAtEOF)
+			dest = dest[:len(dest)+nDest]
+			region = region[nSrc:]
+			start += nSrc
+
+			if errors.Is(err, transform.ErrShortDst) {
+				// Always leave room for at least one more replacement
+				// character, even when growing an empty destination.
+				grown := make([]byte, len(dest), 2*cap(dest)+utf8.UTFMax)
+				copy(grown, dest)
+				dest = grown
+
+				continue
+			}
+
+			if errors.Is(err, transform.ErrShortSrc) && !regionAtEOF {
+				return dest, start, nil
+			}
+
+			if err != nil {
+				return nil, start, err
+			}
+
+			break
+		}
+	}
+
+	return dest, len(src), nil
+}
+
+// isASCII returns true if all the bytes of the given data are below 0x80.
+func isASCII(data []byte) bool {
+	for _, c := range data {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DecodeValue takes a BufferSource, that is an ArrayBuffer, a TypedArray
+// or a DataView, as input and returns a string.
+//
+// It is a convenience over Decode, extracting the bytes the given value
+// views before decoding them. With the binaryString option, the value is
+// a binary string instead, and UTF-16 decoders read the code units of
+// 16-bit TypedArrays in their own byte order, rather than the platform's.
+func (td *TextDecoder) DecodeValue(buffer goja.Value, options decodeOptions) (string, error) {
+	decoded, _, err := td.DecodeValueConsumed(buffer, options)
+
+	return decoded, err
+}
+
+// DecodeValueConsumed decodes the given value as DecodeValue does, and also
+// returns the number of bytes the decoded text originates from, as
+// DecodeConsumed does.
+func (td *TextDecoder) DecodeValueConsumed(buffer goja.Value, options decodeOptions) (string, int, error) {
+	data, err := td.exportValue(buffer, options)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return td.DecodeConsumed(data, options)
+}
+
+// exportValue returns the bytes the given value holds, read as the
+// given options and the encoding of the decoder tell to.
+func (td *TextDecoder) exportValue(buffer goja.Value, options decodeOptions) ([]byte, error) {
+	switch order, isUTF16 := utf16ByteOrder(td.Encoding); {
+	case options.BinaryString:
+		return exportBinaryString(buffer)
+	case isUTF16 && !common.IsNullish(buffer) && IsInstanceOf(td.rt, buffer, Int16ArrayConstructor, Uint16ArrayConstructor):
+		// 16-bit TypedArrays hold UTF-16 code units, serialized
+		// in the decoder's byte order rather than the platform's.
+		return exportCodeUnits(td.rt, buffer, order)
+	default:
+		return exportArrayBuffer(td.rt, buffer)
+	}
+}
+
+// exportArrayBuffer interprets the given value as an ArrayBuffer, TypedArray or DataView
+// and returns the underlying bytes it views.
+//
+// The bytes of a DataView are returned in the order its buffer holds them,
+// regardless of the byte order its getters and setters might be called with:
+// the encoding of the decoder they are given to alone governs their meaning.
+//
+// Note that the returned byte slice shares its memory with the given value.
+func exportArrayBuffer(rt *goja.Runtime, v goja.Value) ([]byte, error) {
+	if common.IsNullish(v) {
+		return nil, NewError(TypeError, "data is null or undefined")
+	}
+
+	asObject := v.ToObject(rt)
+
+	if ab, ok := asObject.Export().(goja.ArrayBuffer); ok {
+		if ab.Detached() {
+			return nil, NewError(TypeError, "cannot decode detached ArrayBuffer")
+		}
+
+		return ab.Bytes(), nil
+	}
+
+	if !IsTypedArray(rt, v) && !IsInstanceOf(rt, v, DataViewConstructor) {
+		return nil, NewError(TypeError, "data is neither an ArrayBuffer, nor a TypedArray nor DataView")
+	}
+
+	ab, ok := asObject.Get("buffer").Export().(goja.ArrayBuffer)
+	if !ok {
+		return nil, NewError(TypeError, "data.buffer is not an ArrayBuffer")
+	}
+
+	// The buffer of a view may have been detached since the view was created,
+	// in which case the view's offset and length no longer hold.
+	if ab.Detached() {
+		return nil, NewError(TypeError, "cannot decode detached ArrayBuffer")
+	}
+
+	// TypedArray and DataView objects can view a portion of their buffer only
+	buffer := ab.Bytes()
+	offset := asObject.Get("byteOffset").ToInteger()
+	length := asObject.Get("byteLength").ToInteger()
+	if offset < 0 || length < 0 || offset+length > int64(len(buffer)) {
+		return nil, NewError(TypeError, "data views bytes out of the bounds of its buffer")
+	}
+
+	return buffer[offset : offset+length], nil
+}
+
+// exportArrayBuffers interprets the given value as an iterable of ArrayBuffer,
+// TypedArray or DataView, and returns the underlying bytes each of them views.
+func exportArrayBuffers(rt *goja.Runtime, v goja.Value) ([][]byte, error) {
+	if common.IsNullish(v) {
+		return nil, NewError(TypeError, "data must be an iterable of buffer sources")
+	}
+
+	var values []goja.Value
+	if err := rt.ExportTo(v, &values); err != nil {
+		return nil, NewError(TypeError, "data must be an iterable of buffer sources; reason: "+err.Error())
+	}
+
+	buffers := make([][]byte, 0, len(values))
+	for _, value := range values {
+		buffer, err := exportArrayBuffer(rt, value)
+		if err != nil {
+			return nil, err
+		}
+
+		buffers = append(buffers, buffer)
+	}
+
+	return buffers, nil
+}
+
+// exportBinaryString interprets the given value as a binary string, holding one
+// byte per character, as produced by atob, and returns the bytes it holds.
+//
+// Characters beyond U+00FF cannot stand for a byte, and are thus rejected.
+func exportBinaryString(v goja.Value) ([]byte, error) {
+	s, ok := v.Export().(string)
+	if !ok {
+		return nil, NewError(TypeError, "data is not a string")
+	}
+
+	data := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xFF {
+			return nil, NewError(TypeError, fmt.Sprintf("binary string holds a character out of the byte range: U+%04X", r))
+		}
+
+		data = append(data, byte(r))
+	}
+
+	return data, nil
+}
+
+// exportCodeUnits interprets the given value as an Int16Array or Uint16Array of
+// UTF-16 code units, and returns the bytes they serialize to in the given order.
+//
+// Unlike the bytes a 16-bit TypedArray views, which hold its elements in the
+// platform's native byte order, the returned bytes do not depend on the platform.
+func exportCodeUnits(rt *goja.Runtime, v goja.Value, order binary.ByteOrder) ([]byte, error) {
+	asObject := v.ToObject(rt)
+
+	if isDetached(asObject) {
+		return nil, NewError(TypeError, "cannot decode detached ArrayBuffer")
+	}
+
+	length := asObject.Get("length").ToInteger()
+	data := make([]byte, 2*length)
+	for i := int64(0); i < length; i++ {
+		unit := uint16(asObject.Get(strconv.FormatInt(i, 10)).ToInteger())
+		order.PutUint16(data[2*i:], unit)
+	}
+
+	return data, nil
+}
+
+// defaultDecodeChunkSize holds the number of bytes DecodeWithCallback
+// decodes, and DecodeReader reads, at once by default.
+const defaultDecodeChunkSize = 64 * 1024
+
+type decodeWithCallbackOptions struct {
+	// ChunkSize holds the maximum number of bytes
+	// decoded at once, before calling the callback.
+	//
+	// It defaults to 64 KiB.
+	ChunkSize int `js:"chunkSize"`
 }
 
 type decodeOptions struct {
@@ -76,44 +1177,243 @@ type decodeOptions struct {
 	//
 	// Set to true if processing the data in chunks, and
 	// false for the final chunk or if the data is not chunked.
+	//
+	// Note that a call with stream set to false always ends
+	// the current stream, and resets the decoder's state.
+	//
+	// Non-boolean values are coerced as JS coerces values to
+	// booleans: truthy values, such as 1 or "false", enable
+	// streaming, and falsy ones, such as 0, "" or null, disable it.
 	Stream bool `js:"stream"`
+
+	// A boolean flag indicating whether the final, non-streaming,
+	// call to decode() should throw a `TypeError` when the input
+	// ends with a truncated sequence, even in non-fatal mode.
+	//
+	// It defaults to `false`, which means that the truncated
+	// sequence is substituted with a replacement character.
+	ErrorOnTruncated bool `js:"errorOnTruncated"`
+
+	// Output holds the form decode() returns the decoded text in,
+	// either "string", "codepoints" or "codeunits".
+	//
+	// It defaults to "string".
+	Output DecodeOutput `js:"output"`
+
+	// ControlBytes holds the policy applied to the control characters
+	// of the decoded text, either "keep", "strip" or "replace".
+	//
+	// It defaults to "keep", which leaves them as is.
+	ControlBytes ControlBytesPolicy `js:"controlBytes"`
+
+	// CaseFold holds the case the decoded text is folded to, either
+	// "none", "lower" or "upper", following the Unicode case mappings,
+	// or "ascii-lower" or "ascii-upper", only folding ASCII letters.
+	//
+	// It defaults to "none", which leaves the text as is.
+	CaseFold CaseFoldPolicy `js:"caseFold"`
+
+	// WithConsumed holds a boolean value indicating whether decode()
+	// returns an object holding both the decoded text, as value, and
+	// the number of bytes it originates from, as consumed.
+	WithConsumed bool `js:"withConsumed"`
+
+	// BinaryString holds a boolean value indicating whether decode()
+	// expects a binary string, holding one byte per character as
+	// produced by atob, rather than a buffer source.
+	BinaryString bool `js:"binaryString"`
+
+	// ReportBOM holds a boolean value indicating whether decode() returns
+	// an object holding both the decoded text, as value, and the name of
+	// the encoding whose byte order mark was stripped, as bom, or null if
+	// none was.
+	ReportBOM bool `js:"reportBOM"`
+
+	// MaxBytes holds the number of leading bytes of the buffer decode()
+	// processes, the rest of it being left for a subsequent call.
+	//
+	// In streaming mode, a sequence the limit splits is buffered until the
+	// next call completes it. It defaults to processing the whole buffer.
+	MaxBytes *int `js:"maxBytes"`
+
+	// ReportErrors holds a boolean value indicating whether decode() returns
+	// an object holding both the decoded text, as value, and the regions of
+	// the input substituted with replacement characters, as errors, each
+	// described by its offset and byteLength.
+	ReportErrors bool `js:"reportErrors"`
+
+	// EscapeInvalid holds a boolean value indicating whether decode() escapes
+	// each byte of malformed sequences to the Private Use Area code point
+	// U+F700 plus its value, rather than substituting the sequences with
+	// replacement characters, so that a TextEncoder constructed with the
+	// escapeInvalid option can recover the original bytes.
+	EscapeInvalid bool `js:"escapeInvalid"`
+
+	// ReturnRemainder holds a boolean value indicating whether decode()
+	// decodes in streaming mode, but hands the trailing bytes of an
+	// incomplete sequence back to the caller, as remainder, rather than
+	// buffering them, alongside the decoded text, as value. The caller
+	// is then expected to prepend them to the next chunk.
+	ReturnRemainder bool `js:"returnRemainder"`
+
+	// FlagErrors holds a boolean value indicating whether decode() returns
+	// an object holding both the decoded text, as value, and whether any
+	// malformed sequence was substituted with a replacement character, as
+	// hadErrors. Replacement characters the input encodes as such do not
+	// count as errors.
+	FlagErrors bool `js:"flagErrors"`
+
+	// Fatal holds a boolean value overriding, for the decode() call
+	// only, the error mode the decoder was constructed with, so that
+	// a single decoder can make both strict and lenient passes.
+	//
+	// It defaults to the decoder's fatal property.
+	Fatal *bool `js:"fatal"`
 }
 
+// limit returns the leading part of the given buffer the options allow
+// processing, as set by MaxBytes.
+func (o decodeOptions) limit(buffer []byte) []byte {
+	if o.MaxBytes == nil || *o.MaxBytes < 0 || *o.MaxBytes >= len(buffer) {
+		return buffer
+	}
+
+	return buffer[:*o.MaxBytes]
+}
+
+// fatal returns whether the error mode of the call is fatal, as set by Fatal,
+// or by the given error mode of the decoder, should Fatal be omitted.
+func (o decodeOptions) fatal(decoderFatal bool) bool {
+	if o.Fatal == nil {
+		return decoderFatal
+	}
+
+	return *o.Fatal
+}
+
+// DecodeOutput is a type alias for the form decoded text is returned in.
+type DecodeOutput = string
+
+const (
+	// DecodeOutputString returns the decoded text as a string.
+	DecodeOutputString DecodeOutput = "string"
+
+	// DecodeOutputCodePoints returns the decoded text as an array
+	// of the Unicode scalar values it is made of.
+	DecodeOutputCodePoints DecodeOutput = "codepoints"
+
+	// DecodeOutputCodeUnits returns the decoded text as a Uint16Array
+	// of the UTF-16 code units it is made of.
+	DecodeOutputCodeUnits DecodeOutput = "codeunits"
+)
+
+// ControlBytesPolicy is a type alias for the policy
+// applied to the control characters of decoded text.
+type ControlBytesPolicy = string
+
+const (
+	// ControlBytesKeep leaves control characters as is.
+	ControlBytesKeep ControlBytesPolicy = "keep"
+
+	// ControlBytesStrip removes control characters.
+	ControlBytesStrip ControlBytesPolicy = "strip"
+
+	// ControlBytesReplace substitutes control characters with replacement characters.
+	ControlBytesReplace ControlBytesPolicy = "replace"
+)
+
+// CaseFoldPolicy is a type alias for the case decoded text is folded to.
+type CaseFoldPolicy = string
+
+const (
+	// CaseFoldNone leaves the case of decoded text as is.
+	CaseFoldNone CaseFoldPolicy = "none"
+
+	// CaseFoldLower folds decoded text to lowercase.
+	CaseFoldLower CaseFoldPolicy = "lower"
+
+	// CaseFoldUpper folds decoded text to uppercase.
+	CaseFoldUpper CaseFoldPolicy = "upper"
+
+	// CaseFoldASCIILower folds the ASCII letters of decoded text to lowercase.
+	CaseFoldASCIILower CaseFoldPolicy = "ascii-lower"
+
+	// CaseFoldASCIIUpper folds the ASCII letters of decoded text to uppercase.
+	CaseFoldASCIIUpper CaseFoldPolicy = "ascii-upper"
+)
+
+// NewlinePolicy is a type alias for the policy
+// applied to the line endings of decoded text.
+type NewlinePolicy = string
+
+const (
+	// NewlineNone leaves line endings as is.
+	NewlineNone NewlinePolicy = "none"
+
+	// NewlineLF normalizes line endings to line feeds.
+	NewlineLF NewlinePolicy = "lf"
+
+	// NewlineCRLF normalizes line endings to carriage returns followed by line feeds.
+	NewlineCRLF NewlinePolicy = "crlf"
+)
+
+// LoneSurrogatesPolicy is a type alias for the policy applied
+// to the unpaired surrogate code units of UTF-16 input.
+type LoneSurrogatesPolicy = string
+
+const (
+	// LoneSurrogatesReplace substitutes unpaired surrogates with replacement characters.
+	LoneSurrogatesReplace LoneSurrogatesPolicy = "replace"
+
+	// LoneSurrogatesError makes unpaired surrogates an error.
+	LoneSurrogatesError LoneSurrogatesPolicy = "error"
+)
+
 // NewTextDecoder returns a new TextDecoder object instance that will
 // generate a string from a byte stream with a specific encoding.
 func NewTextDecoder(rt *goja.Runtime, label string, options textDecoderOptions) (*TextDecoder, error) {
-	// Pick the encoding BOM policy accordingly
-	bomPolicy := unicode.IgnoreBOM
-	if !options.IgnoreBOM {
-		bomPolicy = unicode.UseBOM
-	}
-
-	var decoder encoding.Encoding
-	switch strings.TrimSpace(strings.ToLower(label)) {
-	case "",
-		"unicode-1-1-utf-8",
-		"unicode11utf8",
-		"unicode20utf8",
-		"utf-8",
-		"utf8",
-		"x-unicode20utf8":
+	// An empty label defaults to the utf-8 encoding
+	if strings.TrimSpace(label) == "" {
 		label = UTF8EncodingFormat
-		decoder = unicode.UTF8
-	case UTF16LEEncodingFormat:
-		decoder = unicode.UTF16(unicode.LittleEndian, bomPolicy)
-	case UTF16BEEncodingFormat:
-		decoder = unicode.UTF16(unicode.BigEndian, bomPolicy)
-	default:
+	}
+
+	entry, ok := lookupEncoding(label)
+	if !ok {
 		return nil, NewError(RangeError, fmt.Sprintf("unsupported encoding: %s", label))
 	}
 
+	switch options.Newline {
+	case "", NewlineNone, NewlineLF, NewlineCRLF:
+	default:
+		return nil, NewError(TypeError, fmt.Sprintf("unsupported newline policy: %s", options.Newline))
+	}
+
+	switch options.LoneSurrogates {
+	case "", LoneSurrogatesReplace, LoneSurrogatesError:
+	default:
+		return nil, NewError(TypeError, fmt.Sprintf("unsupported lone surrogates policy: %s", options.LoneSurrogates))
+	}
+
 	td := &TextDecoder{
-		Encoding:  label,
-		IgnoreBOM: options.IgnoreBOM,
-		Fatal:     options.Fatal,
+		Encoding:       entry.name,
+		IgnoreBOM:      options.IgnoreBOM,
+		Fatal:          options.Fatal,
+		AutoReBOM:      options.AutoReBOM,
+		DefaultStream:  options.DefaultStream,
+		ResetOnError:   !options.NoResetOnError,
+		Newline:        options.Newline,
+		LoneSurrogates: options.LoneSurrogates,
 
-		decoder: decoder,
-		rt:      rt,
+		decoder:         entry.newEncoding(),
+		asciiCompatible: entry.asciiCompatible,
+		rt:              rt,
+	}
+
+	td.transform = td.decoder.NewDecoder()
+
+	switch td.decoder.(type) {
+	case *charmap.Charmap, *singleByteEncoding:
+		td.singleByte = true
 	}
 
 	return td, nil
@@ -133,6 +1433,69 @@ const (
 
 	// UTF16BEEncodingFormat is the encoding format for utf-16be
 	UTF16BEEncodingFormat = "utf-16be"
+
+	// Windows1252EncodingFormat is the encoding format for windows-1252
+	Windows1252EncodingFormat = "windows-1252"
+
+	// Windows1250EncodingFormat is the encoding format for windows-1250
+	Windows1250EncodingFormat = "windows-1250"
+
+	// Windows1251EncodingFormat is the encoding format for windows-1251
+	Windows1251EncodingFormat = "windows-1251"
+
+	// Windows1257EncodingFormat is the encoding format for windows-1257
+	Windows1257EncodingFormat = "windows-1257"
+
+	// Windows1253EncodingFormat is the encoding format for windows-1253
+	Windows1253EncodingFormat = "windows-1253"
+
+	// Windows1254EncodingFormat is the encoding format for windows-1254
+	Windows1254EncodingFormat = "windows-1254"
+
+	// Windows1255EncodingFormat is the encoding format for windows-1255
+	Windows1255EncodingFormat = "windows-1255"
+
+	// Windows1256EncodingFormat is the encoding format for windows-1256
+	Windows1256EncodingFormat = "windows-1256"
+
+	// Windows1258EncodingFormat is the encoding format for windows-1258
+	Windows1258EncodingFormat = "windows-1258"
+
+	// IBM866EncodingFormat is the encoding format for ibm866
+	IBM866EncodingFormat = "ibm866"
+
+	// ISO88592EncodingFormat is the encoding format for iso-8859-2
+	ISO88592EncodingFormat = "iso-8859-2"
+
+	// ISO885915EncodingFormat is the encoding format for iso-8859-15
+	ISO885915EncodingFormat = "iso-8859-15"
+
+	// KOI8REncodingFormat is the encoding format for koi8-r
+	KOI8REncodingFormat = "koi8-r"
+
+	// KOI8UEncodingFormat is the encoding format for koi8-u
+	KOI8UEncodingFormat = "koi8-u"
+
+	// ReplacementEncodingFormat is the encoding format for replacement
+	ReplacementEncodingFormat = "replacement"
+
+	// Big5EncodingFormat is the encoding format for big5
+	Big5EncodingFormat = "big5"
+
+	// GBKEncodingFormat is the encoding format for gbk
+	GBKEncodingFormat = "gbk"
+
+	// EUCJPEncodingFormat is the encoding format for euc-jp
+	EUCJPEncodingFormat = "euc-jp"
+
+	// ISO2022JPEncodingFormat is the encoding format for iso-2022-jp
+	ISO2022JPEncodingFormat = "iso-2022-jp"
+
+	// ShiftJISEncodingFormat is the encoding format for shift_jis
+	ShiftJISEncodingFormat = "shift_jis"
+
+	// XUserDefinedEncodingFormat is the encoding format for x-user-defined
+	XUserDefinedEncodingFormat = "x-user-defined"
 )
 
 type textDecoderOptions struct {
@@ -148,4 +1511,55 @@ type textDecoderOptions struct {
 	// IgnoreBOM holds a boolean value indicating
 	// whether the byte order mark is ignored.
 	IgnoreBOM bool `js:"ignoreBOM"`
+
+	// AutoReBOM holds a boolean value indicating whether
+	// a UTF-8 decoder decodes the streams starting with a
+	// UTF-16 byte order mark as UTF-16, recovering from
+	// mislabeled data.
+	//
+	// It defaults to `false`, and has no effect on
+	// decoders of any other encoding.
+	AutoReBOM bool `js:"autoReBOM"`
+
+	// DefaultStream holds a boolean value indicating whether
+	// the `TextDecoder.decode()` calls omitting the stream
+	// option decode in streaming mode, as if it was true.
+	//
+	// It defaults to `false`. Passing the stream option
+	// explicitly overrides it, which allows flushing the
+	// decoder with `{ stream: false }`.
+	DefaultStream bool `js:"defaultStream"`
+
+	// NoResetOnError holds a boolean value indicating whether
+	// a `TextDecoder.decode()` call throwing, such as in fatal
+	// mode, leaves the decoder in the state the call left it
+	// in, rather than reset it, discarding the bytes buffered
+	// and the state of the current stream, so that the decoder
+	// can be reused for a new one.
+	//
+	// It holds the negation of the `resetOnError` option, which
+	// defaults to `true`, so that the decoders constructed from
+	// Go with the zero value of the options reset as well.
+	// Non-streaming calls always reset the decoder.
+	NoResetOnError bool `js:"-"`
+
+	// Newline holds the policy applied to the line endings of
+	// the decoded text, either "none", to leave them as is, "lf"
+	// or "crlf", to normalize CRLF, CR and LF line endings to
+	// the given one.
+	//
+	// It defaults to "none". A CRLF line ending split across
+	// streamed chunks is normalized to a single line ending.
+	Newline NewlinePolicy `js:"newline"`
+
+	// LoneSurrogates holds the policy applied to the unpaired
+	// surrogate code units of the input of UTF-16 decoders,
+	// either "replace", to substitute a replacement character
+	// for each of them, as per the specification, or "error",
+	// to make `TextDecoder.decode()` throw a `TypeError`.
+	//
+	// It defaults to "replace", unless in fatal mode, which
+	// always throws, and has no effect on decoders of any other
+	// encoding.
+	LoneSurrogates LoneSurrogatesPolicy `js:"loneSurrogates"`
 }
@@ -1,9 +1,19 @@
 package encoding
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"unicode/utf8"
 
+	"github.com/dop251/goja"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
 )
 
 //
@@ -41,3 +51,4178 @@ func executeTestScripts(ts testSetup, base string, scripts ...string) error {
 
 	return nil
 }
+
+func TestTextDecoderDecodeStreamOrphanContinuationBytes(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		chunks [][]byte
+		want   string
+	}{
+		{
+			name:   "one orphan continuation byte",
+			chunks: [][]byte{{0x61, 0x80}, {0x62}},
+			want:   "a\uFFFDb",
+		},
+		{
+			name:   "two orphan continuation bytes",
+			chunks: [][]byte{{0x61, 0x80, 0x80}, {0x62}},
+			want:   "a\uFFFD\uFFFDb",
+		},
+		{
+			name:   "three orphan continuation bytes",
+			chunks: [][]byte{{0x61, 0x80, 0x80, 0x80}, {0x62}},
+			want:   "a\uFFFD\uFFFD\uFFFDb",
+		},
+		{
+			name:   "incomplete sequence completed by the next chunk",
+			chunks: [][]byte{{0x61, 0xE6, 0xB0}, {0xB4, 0x62}},
+			want:   "a\u6C34b",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(goja.New(), UTF8EncodingFormat, textDecoderOptions{})
+			require.NoError(t, err)
+
+			var got string
+			for i, chunk := range tc.chunks {
+				decoded, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+
+				got += decoded
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestTextDecoderDecodeStreamMalformedTail(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		chunk       []byte
+		want        string
+		wantPending bool
+	}{
+		{
+			name:  "lead byte followed by an invalid byte",
+			chunk: []byte{0x61, 0xE6, 0xFF},
+			want:  "a\uFFFD\uFFFD",
+		},
+		{
+			name:  "lead byte followed by an out of range continuation byte",
+			chunk: []byte{0x61, 0xE0, 0x80},
+			want:  "a\uFFFD\uFFFD",
+		},
+		{
+			name:        "incomplete sequence",
+			chunk:       []byte{0x61, 0xE6, 0xB0},
+			want:        "a",
+			wantPending: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+			require.NoError(t, err)
+
+			// Malformed sequences ending the chunk are substituted right
+			// away, as no upcoming byte could make them well-formed.
+			decoded, err := td.Decode(tc.chunk, decodeOptions{Stream: true})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+			assert.Equal(t, tc.wantPending, td.Pending())
+		})
+	}
+}
+
+func TestTextDecoderDecodeErrorOnTruncated(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		label     string
+		chunks    [][]byte
+		options   decodeOptions
+		want      string
+		wantError bool
+	}{
+		{
+			name:      "buffered partial utf-8 sequence with option set",
+			label:     UTF8EncodingFormat,
+			chunks:    [][]byte{{0x61, 0xE6}, {0xB0}},
+			options:   decodeOptions{ErrorOnTruncated: true},
+			wantError: true,
+		},
+		{
+			name:    "buffered partial utf-8 sequence without option set",
+			label:   UTF8EncodingFormat,
+			chunks:  [][]byte{{0x61, 0xE6}, {0xB0}},
+			options: decodeOptions{},
+			want:    "a\uFFFD",
+		},
+		{
+			name:    "complete utf-8 sequence with option set",
+			label:   UTF8EncodingFormat,
+			chunks:  [][]byte{{0x61, 0xE6}, {0xB0, 0xB4}},
+			options: decodeOptions{ErrorOnTruncated: true},
+			want:    "a\u6C34",
+		},
+		{
+			name:    "orphan continuation byte with option set",
+			label:   UTF8EncodingFormat,
+			chunks:  [][]byte{{0x61}, {0x80}},
+			options: decodeOptions{ErrorOnTruncated: true},
+			want:    "a\uFFFD",
+		},
+		{
+			name:      "buffered odd utf-16le byte with option set",
+			label:     UTF16LEEncodingFormat,
+			chunks:    [][]byte{{0x61, 0x00, 0x62}, {}},
+			options:   decodeOptions{ErrorOnTruncated: true},
+			wantError: true,
+		},
+		{
+			name:      "buffered utf-16le high surrogate with option set",
+			label:     UTF16LEEncodingFormat,
+			chunks:    [][]byte{{0x61, 0x00}, {0x34, 0xD8}},
+			options:   decodeOptions{ErrorOnTruncated: true},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(goja.New(), tc.label, textDecoderOptions{})
+			require.NoError(t, err)
+
+			var got string
+			for _, chunk := range tc.chunks[:len(tc.chunks)-1] {
+				decoded, err := td.Decode(chunk, decodeOptions{Stream: true})
+				require.NoError(t, err)
+
+				got += decoded
+			}
+
+			decoded, err := td.Decode(tc.chunks[len(tc.chunks)-1], tc.options)
+			if tc.wantError {
+				var encodingErr *Error
+				require.ErrorAs(t, err, &encodingErr)
+				assert.Equal(t, TypeError, encodingErr.Name)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got+decoded)
+		})
+	}
+}
+
+func BenchmarkTextDecoderDecodeStream(b *testing.B) {
+	// Small chunks of "a水", each ending with a split sequence
+	chunks := [][]byte{{0x61, 0xE6}, {0xB0, 0xB4, 0x61}, {0xE6, 0xB0, 0xB4}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for j := 0; j < 10000; j++ {
+			if _, err := td.Decode(chunks[j%len(chunks)], decodeOptions{Stream: true}); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		if _, err := td.Decode(nil, decodeOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTextDecoderDecodeTinyInput(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		encoding EncodingName
+		chunks   [][]byte
+		want     string
+	}{
+		{
+			name:     "empty input",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{}},
+			want:     "",
+		},
+		{
+			name:     "single invalid byte",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0xFF}},
+			want:     "\uFFFD",
+		},
+		{
+			name:     "single byte expanding to two",
+			encoding: Windows1252EncodingFormat,
+			chunks:   [][]byte{{0xE9}},
+			want:     "\u00E9",
+		},
+		{
+			name:     "empty flush after a truncated utf-8 sequence",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0xE6, 0xB0}, {}},
+			want:     "\uFFFD",
+		},
+		{
+			name:     "empty flush after a truncated utf-16 code unit",
+			encoding: UTF16LEEncodingFormat,
+			chunks:   [][]byte{{0x61}, {}},
+			want:     "\uFFFD",
+		},
+		{
+			name:     "empty chunks streamed around a sequence",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{}, {0xE6}, {}, {0xB0, 0xB4}, {}},
+			want:     "\u6C34",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+			require.NoError(t, err)
+
+			var got string
+			for i, chunk := range tc.chunks {
+				decoded, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+
+				got += decoded
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func BenchmarkTextDecoderDecodeTiny(b *testing.B) {
+	testCases := []struct {
+		name     string
+		encoding EncodingName
+		chunk    []byte
+	}{
+		{name: "empty utf-8", encoding: UTF8EncodingFormat, chunk: []byte{}},
+		{name: "one byte utf-8", encoding: UTF8EncodingFormat, chunk: []byte{0x61}},
+		{name: "empty windows-1252", encoding: Windows1252EncodingFormat, chunk: []byte{}},
+		{name: "one byte windows-1252", encoding: Windows1252EncodingFormat, chunk: []byte{0xE9}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		b.Run(tc.name, func(b *testing.B) {
+			for _, stream := range []bool{true, false} {
+				stream := stream
+
+				b.Run(fmt.Sprintf("stream=%t", stream), func(b *testing.B) {
+					td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+					if err != nil {
+						b.Fatal(err)
+					}
+
+					b.ReportAllocs()
+					b.ResetTimer()
+
+					for i := 0; i < b.N; i++ {
+						if _, err := td.Decode(tc.chunk, decodeOptions{Stream: stream}); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestTextDecoderDecodeASCII(t *testing.T) {
+	t.Parallel()
+
+	ascii := bytes.Repeat([]byte("GET /index.html\r\n"), 64)
+
+	// ASCII except for a single high byte, which must not be decoded as is
+	mixed := append(append([]byte{}, ascii...), 0xE9)
+
+	testCases := []struct {
+		name     string
+		encoding EncodingName
+		data     []byte
+		want     string
+	}{
+		{name: "utf-8", encoding: UTF8EncodingFormat, data: ascii, want: string(ascii)},
+		{name: "windows-1252", encoding: Windows1252EncodingFormat, data: ascii, want: string(ascii)},
+		{name: "x-user-defined", encoding: XUserDefinedEncodingFormat, data: ascii, want: string(ascii)},
+		{name: "utf-8 high byte", encoding: UTF8EncodingFormat, data: mixed, want: string(ascii) + "\uFFFD"},
+		{name: "windows-1252 high byte", encoding: Windows1252EncodingFormat, data: mixed, want: string(ascii) + "\u00E9"},
+		{name: "koi8-r high byte", encoding: KOI8REncodingFormat, data: mixed, want: string(ascii) + "\u0418"},
+		{name: "x-user-defined high byte", encoding: XUserDefinedEncodingFormat, data: mixed, want: string(ascii) + "\uF7E9"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+			require.NoError(t, err)
+
+			got, err := td.Decode(tc.data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+
+			// The decoded string must not share its memory with the input
+			data := append([]byte{}, tc.data...)
+			got, err = td.Decode(data, decodeOptions{})
+			require.NoError(t, err)
+			data[0] = 'X'
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("pending utf-8 sequence", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		got, err := td.Decode([]byte{0x61, 0xE6}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, "a", got)
+
+		// The ASCII chunk completes nothing, the pending bytes are substituted
+		got, err = td.Decode([]byte{0x62}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "\uFFFDb", got)
+		assert.Equal(t, 1, td.Substitutions())
+	})
+}
+
+func TestTransformUTF8Runs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: []byte{}},
+		{name: "ascii", data: []byte("abc")},
+		{name: "multi-byte", data: []byte("\u00E9\u6C34\U0001F600")},
+		{name: "ascii runs around multi-byte", data: []byte("ab\u00E9cd\u6C34ef\U0001F600gh")},
+		{name: "multi-byte at both ends", data: []byte("\u6C34abc\u00E9")},
+		{name: "invalid byte between runs", data: []byte{0x61, 0xFF, 0x62}},
+		{name: "truncated sequence before ascii", data: []byte{0x61, 0xE6, 0xB0, 0x62}},
+		{name: "truncated sequence at the end", data: []byte{0x61, 0xE6, 0xB0}},
+		{name: "lead byte followed by a valid sequence", data: []byte{0xE6, 0xC3, 0xA9, 0x61}},
+		{name: "surrogate encoded", data: []byte{0x61, 0xED, 0xA0, 0x80, 0x62}},
+		{name: "encoded replacement character", data: []byte("a\uFFFDb")},
+		{name: "invalid bytes expanding", data: bytes.Repeat([]byte{0x61, 0x80, 0x81}, 100)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, atEOF := range []bool{true, false} {
+				want, wantN, err := transformBytes(unicode.UTF8.NewDecoder(), nil, tc.data, atEOF)
+				require.NoError(t, err)
+
+				got, gotN, err := transformUTF8Runs(unicode.UTF8.NewDecoder(), nil, tc.data, atEOF)
+				require.NoError(t, err)
+
+				assert.Equal(t, string(want), string(got), "decoded should match the general path, atEOF: %t", atEOF)
+				assert.Equal(t, wantN, gotN, "consumed should match the general path, atEOF: %t", atEOF)
+			}
+		})
+	}
+
+	t.Run("every split of mixed content", func(t *testing.T) {
+		t.Parallel()
+
+		data := []byte("a\u00E9b\xFFc\u6C34\xE6\xB0d\U0001F600")
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		want, err := td.Decode(data, decodeOptions{})
+		require.NoError(t, err)
+
+		for i := 0; i <= len(data); i++ {
+			first, err := td.Decode(data[:i], decodeOptions{Stream: true})
+			require.NoError(t, err)
+
+			second, err := td.Decode(data[i:], decodeOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, want, first+second, "split at %d", i)
+		}
+	})
+}
+
+func BenchmarkTextDecoderDecodeASCII(b *testing.B) {
+	ascii := bytes.Repeat([]byte("0123456789abcdef"), 256*1024/16)
+
+	// A single high byte makes the whole buffer take the general path
+	mixed := append(append([]byte{}, ascii[:len(ascii)-1]...), 0xE9)
+
+	// 38 ASCII bytes out of 40, with a two-byte character in between
+	mostlyASCII := bytes.Repeat([]byte("0123456789abcdefghi\u00E90123456789abcdefghi"), 256*1024/40)
+
+	testCases := []struct {
+		name     string
+		encoding EncodingName
+		data     []byte
+	}{
+		{name: "ascii utf-8", encoding: UTF8EncodingFormat, data: ascii},
+		{name: "mixed utf-8", encoding: UTF8EncodingFormat, data: mixed},
+		{name: "mostly ascii utf-8", encoding: UTF8EncodingFormat, data: mostlyASCII},
+		{name: "ascii windows-1252", encoding: Windows1252EncodingFormat, data: ascii},
+		{name: "mixed windows-1252", encoding: Windows1252EncodingFormat, data: mixed},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		b.Run(tc.name, func(b *testing.B) {
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(int64(len(tc.data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := td.Decode(tc.data, decodeOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTextDecoderDecodeHalfWidthKatakana(t *testing.T) {
+	t.Parallel()
+
+	// Half-width katakana span from U+FF61 to U+FF9F, and are encoded
+	// as single bytes ranging from 0xA1 to 0xDF in Shift_JIS, and as
+	// the same bytes prefixed by the 0x8E (SS2) byte in EUC-JP.
+	var shiftJIS, eucJP []byte
+	var want []rune
+	for b := 0xA1; b <= 0xDF; b++ {
+		shiftJIS = append(shiftJIS, byte(b))
+		eucJP = append(eucJP, 0x8E, byte(b))
+		want = append(want, rune(0xFF61+b-0xA1))
+	}
+
+	testCases := []struct {
+		name  string
+		label string
+		data  []byte
+	}{
+		{
+			name:  "shift_jis",
+			label: ShiftJISEncodingFormat,
+			data:  shiftJIS,
+		},
+		{
+			name:  "euc-jp",
+			label: EUCJPEncodingFormat,
+			data:  eucJP,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(goja.New(), tc.label, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(tc.data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, string(want), decoded)
+		})
+
+		t.Run(tc.name+" streamed byte by byte", func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(goja.New(), tc.label, textDecoderOptions{})
+			require.NoError(t, err)
+
+			var decoded string
+			for _, b := range tc.data {
+				chunk, err := td.Decode([]byte{b}, decodeOptions{Stream: true})
+				require.NoError(t, err)
+
+				decoded += chunk
+			}
+
+			chunk, err := td.Decode(nil, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, string(want), decoded+chunk)
+		})
+	}
+}
+
+func TestTextDecoderDecodeShiftJISStream(t *testing.T) {
+	t.Parallel()
+
+	// "aあｱb水" in Shift_JIS: single-byte characters, ASCII and half-width
+	// katakana, interleaved with double-byte ones, whose lead bytes range
+	// from 0x81 to 0x9F and from 0xE0 to 0xFC.
+	data := []byte{0x61, 0x82, 0xA0, 0xB1, 0x62, 0x90, 0x85}
+
+	testCases := []struct {
+		name   string
+		chunks [][]byte
+		want   []string
+	}{
+		{
+			name:   "split after a lead byte",
+			chunks: [][]byte{data[:2], data[2:]},
+			want:   []string{"a", "\u3042\uFF71b\u6C34"},
+		},
+		{
+			name:   "split after a single-byte character",
+			chunks: [][]byte{data[:4], data[4:]},
+			want:   []string{"a\u3042\uFF71", "b\u6C34"},
+		},
+		{
+			name:   "split after a half-width katakana followed by a lead byte",
+			chunks: [][]byte{data[:4], data[4:6], data[6:]},
+			want:   []string{"a\u3042\uFF71", "b", "\u6C34"},
+		},
+		{
+			name:   "lead byte from the upper range",
+			chunks: [][]byte{{0x61, 0xE0}, {0x40, 0x62}},
+			want:   []string{"a", "\u6F3Eb"},
+		},
+		{
+			name:   "lead byte followed by an ascii byte",
+			chunks: [][]byte{{0x61, 0x81}, {0x20, 0x62}},
+			want:   []string{"a", "\uFFFD b"},
+		},
+		{
+			name:   "lead byte ending the stream",
+			chunks: [][]byte{{0x61, 0x81}, {}},
+			want:   []string{"a", "\uFFFD"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, ShiftJISEncodingFormat, textDecoderOptions{})
+			require.NoError(t, err)
+
+			for i, chunk := range tc.chunks {
+				decoded, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+				assert.Equal(t, tc.want[i], decoded, "chunk %d", i)
+			}
+
+			assert.False(t, td.Pending())
+		})
+	}
+}
+
+func TestTextDecoderDecodeISO2022JPEscapes(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		chunks      [][]byte
+		want        []string
+		wantPending []bool
+	}{
+		{
+			name:        "lone escape to ascii",
+			chunks:      [][]byte{{0x1B, 0x28, 0x42}},
+			want:        []string{""},
+			wantPending: []bool{false},
+		},
+		{
+			name:        "escapes only",
+			chunks:      [][]byte{{0x1B, 0x24, 0x42, 0x1B, 0x28, 0x4A, 0x1B, 0x28, 0x42}},
+			want:        []string{""},
+			wantPending: []bool{false},
+		},
+		{
+			name:        "escape ending the stream",
+			chunks:      [][]byte{{0x61, 0x1B, 0x24, 0x42}},
+			want:        []string{"a"},
+			wantPending: []bool{false},
+		},
+		{
+			name:        "escape-only chunks",
+			chunks:      [][]byte{{0x1B, 0x24, 0x42}, {0x24, 0x22}, {0x1B, 0x28, 0x42}, {}},
+			want:        []string{"", "\u3042", "", ""},
+			wantPending: []bool{false, false, false, false},
+		},
+		{
+			// As per the specification, the bytes following the escape
+			// byte are decoded anew once the escape sequence fails.
+			name:        "stream ending mid-escape sequence",
+			chunks:      [][]byte{{0x61, 0x1B, 0x24}, {}},
+			want:        []string{"a", "\uFFFD$"},
+			wantPending: []bool{true, false},
+		},
+		{
+			name:        "stream ending with an escape byte",
+			chunks:      [][]byte{{0x61, 0x1B}, {}},
+			want:        []string{"a", "\uFFFD"},
+			wantPending: []bool{true, false},
+		},
+		{
+			name:        "escape sequence split across chunks",
+			chunks:      [][]byte{{0x61, 0x1B, 0x24}, {0x42, 0x24, 0x22}, {}},
+			want:        []string{"a", "\u3042", ""},
+			wantPending: []bool{true, false, false},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, "csiso2022jp", textDecoderOptions{})
+			require.NoError(t, err)
+
+			for i, chunk := range tc.chunks {
+				decoded, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+				assert.Equal(t, tc.want[i], decoded, "chunk %d", i)
+				assert.Equal(t, tc.wantPending[i], td.Pending(), "chunk %d", i)
+			}
+		})
+	}
+}
+
+func TestTextDecoderDecodeBig5HKSCS(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "base big5 character",
+			data: []byte{0xA4, 0x40},
+			want: "\u4E00",
+		},
+		{
+			name: "hkscs character",
+			data: []byte{0x87, 0x40},
+			want: "\u43F0",
+		},
+		{
+			name: "hkscs character decoding to two code points",
+			data: []byte{0x88, 0x62},
+			want: "\u00CA\u0304",
+		},
+		{
+			name: "hkscs character surrounded by ascii",
+			data: []byte{0x61, 0x87, 0x40, 0x62},
+			want: "a\u43F0b",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, "big5-hkscs", textDecoderOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, Big5EncodingFormat, td.Encoding)
+
+			decoded, err := td.Decode(tc.data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+
+			// Decoding the same data one byte at a time yields the same text
+			var streamed string
+			for _, b := range tc.data {
+				chunk, err := td.Decode([]byte{b}, decodeOptions{Stream: true})
+				require.NoError(t, err)
+
+				streamed += chunk
+			}
+
+			flushed, err := td.Decode(nil, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, streamed+flushed)
+		})
+	}
+}
+
+func TestTextDecoderDecodeGBK(t *testing.T) {
+	t.Parallel()
+
+	for _, label := range []string{"gb2312", "csgb2312", "gb_2312", "gb_2312-80", "iso-ir-58", "chinese", "x-gbk", "gbk", "936"} {
+		label := label
+
+		t.Run(label, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, label, textDecoderOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, GBKEncodingFormat, td.Encoding)
+
+			// 0xD6 0xD0 encodes 中 in GB2312, and 0x81 0x40 encodes 丂 in GBK only
+			decoded, err := td.Decode([]byte{0x61, 0xD6, 0xD0, 0x81, 0x40}, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "a\u4E2D\u4E02", decoded)
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder("gb2312");
+			assert_equals(decoder.encoding, "gbk");
+			assert_equals(decoder.decode(new Uint8Array([0xd6, 0xd0, 0xce, 0xc4])), "\u4E2D\u6587");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeWindows1258CombiningMarks(t *testing.T) {
+	t.Parallel()
+
+	// Vietnamese tone marks are encoded as combining characters following
+	// the base letter in windows-1258, which the decoder must preserve as
+	// is, rather than normalize to their precomposed form.
+	testCases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "letter followed by a combining acute accent",
+			data: []byte{0x61, 0xEC},
+			want: "a\u0301",
+		},
+		{
+			name: "letter followed by a combining grave accent",
+			data: []byte{0x65, 0xCC},
+			want: "e\u0300",
+		},
+		{
+			name: "letter followed by a combining tilde",
+			data: []byte{0x6F, 0xDE},
+			want: "o\u0303",
+		},
+		{
+			name: "letter followed by a combining hook above",
+			data: []byte{0x75, 0xD2},
+			want: "u\u0309",
+		},
+		{
+			name: "letter followed by a combining dot below",
+			data: []byte{0x79, 0xF2},
+			want: "y\u0323",
+		},
+		{
+			name: "precomposed letter followed by a combining dot below",
+			data: []byte{0xE2, 0xF2},
+			want: "\u00E2\u0323",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, "windows-1258", textDecoderOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, Windows1258EncodingFormat, td.Encoding)
+
+			decoded, err := td.Decode(tc.data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, []rune(tc.want), []rune(decoded))
+		})
+	}
+}
+
+func TestTextDecoderDecodeReplacement(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		chunks [][]byte
+		want   string
+	}{
+		{
+			name:   "empty input",
+			chunks: [][]byte{{}},
+			want:   "",
+		},
+		{
+			name:   "single non-empty chunk",
+			chunks: [][]byte{{0x1B, 0x24, 0x29, 0x43, 0x61}},
+			want:   "\uFFFD",
+		},
+		{
+			name:   "two streamed chunks",
+			chunks: [][]byte{{0x1B, 0x24, 0x29, 0x43}, {0x61, 0x62, 0x63}, {}},
+			want:   "\uFFFD",
+		},
+		{
+			name:   "empty streamed chunks",
+			chunks: [][]byte{{}, {}, {}},
+			want:   "",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(goja.New(), "iso-2022-kr", textDecoderOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, ReplacementEncodingFormat, td.Encoding)
+
+			var got string
+			for i, chunk := range tc.chunks {
+				decoded, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+
+				got += decoded
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("decoder is reset after a non-streaming call", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(goja.New(), "replacement", textDecoderOptions{})
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			decoded, err := td.Decode([]byte{0x61}, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "\uFFFD", decoded)
+		}
+	})
+}
+
+func TestTextDecoderSpecialEncodingNames(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const expectations = [
+			["x-user-defined", "x-user-defined"],
+			["  X-User-Defined ", "x-user-defined"],
+			["replacement", "replacement"],
+			["csiso2022kr", "replacement"],
+			["hz-gb-2312", "replacement"],
+			["iso-2022-cn", "replacement"],
+			["iso-2022-cn-ext", "replacement"],
+			["ISO-2022-KR", "replacement"],
+		];
+
+		for (const [label, want] of expectations) {
+			const decoder = new TextDecoder(label);
+			assert_equals(decoder.encoding, want, "encoding of the " + label + " label");
+
+			decoder.encoding = "utf-8";
+			assert_equals(decoder.encoding, want, "encoding should be read-only");
+		}
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderReplacementAndUnknownLabels(t *testing.T) {
+	t.Parallel()
+
+	// Replacement labels resolve to the replacement encoding, which can be
+	// decoded with, while unknown labels resolve to no encoding at all.
+	testCases := []struct {
+		label     string
+		want      EncodingName
+		wantError bool
+	}{
+		{label: "iso-2022-kr", want: ReplacementEncodingFormat},
+		{label: "hz-gb-2312", want: ReplacementEncodingFormat},
+		{label: "replacement", want: ReplacementEncodingFormat},
+		{label: "bogus-label", wantError: true},
+		{label: "iso-2022-kr-bogus", wantError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.label, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(goja.New(), tc.label, textDecoderOptions{})
+			if tc.wantError {
+				assert.ErrorContains(t, err, RangeError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, td.Encoding)
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			assert_equals(new TextDecoder("iso-2022-kr").encoding, "replacement", "a replacement label should not throw");
+
+			let error;
+			try {
+				new TextDecoder("bogus-label");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof RangeError, "an unknown label should throw a RangeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeXUserDefined(t *testing.T) {
+	t.Parallel()
+
+	td, err := NewTextDecoder(goja.New(), "x-user-defined", textDecoderOptions{})
+	require.NoError(t, err)
+
+	decoded, err := td.Decode([]byte{0x00, 0x61, 0x7F, 0x80, 0xFF}, decodeOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "\x00a\x7F\uF780\uF7FF", decoded)
+}
+
+func TestTextDecoderDecodeAll(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		encoding EncodingName
+		chunks   [][]byte
+		want     string
+	}{
+		{
+			name:     "no chunks",
+			encoding: UTF8EncodingFormat,
+			chunks:   nil,
+			want:     "",
+		},
+		{
+			name:     "single chunk",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x61, 0xE6, 0xB0, 0xB4}},
+			want:     "a\u6C34",
+		},
+		{
+			name:     "utf-8 sequence straddling chunks",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x61, 0xE6}, {}, {0xB0}, {0xB4, 0xF0, 0x9D}, {0x84, 0x9E}},
+			want:     "a\u6C34\U0001D11E",
+		},
+		{
+			name:     "utf-16le code unit straddling chunks",
+			encoding: UTF16LEEncodingFormat,
+			chunks:   [][]byte{{0x61}, {0x00, 0x34}, {0xD8, 0x1E, 0xDD}},
+			want:     "a\U0001D11E",
+		},
+		{
+			name:     "truncated sequence in the last chunk",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x61}, {0xE6, 0xB0}},
+			want:     "a\uFFFD",
+		},
+		{
+			name:     "empty last chunk",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x61, 0xE6, 0xB0}, {}},
+			want:     "a\uFFFD",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.DecodeAll(tc.chunks)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+			assert.False(t, td.Pending())
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder();
+			const chunks = [new Uint8Array([0x61, 0xe6]), new Uint8Array([0xb0]).buffer, new DataView(new Uint8Array([0xb4]).buffer)];
+			assert_equals(decoder.decodeAll(chunks), "a\u6c34");
+			assert_equals(decoder.decodeAll([]), "");
+			assert_equals(decoder.decodeAll([new Uint8Array([0xe6, 0xb0])]), "\ufffd");
+
+			function* frames() {
+				yield new Uint8Array([0xe6]);
+				yield new Uint8Array([0xb0, 0xb4]);
+			}
+			assert_equals(decoder.decodeAll(frames()), "\u6c34");
+
+			let threw = false;
+			try {
+				decoder.decodeAll([new Uint8Array([0x61]), "not a buffer"]);
+			} catch (e) {
+				threw = true;
+			}
+			assert_true(threw, "decodeAll should throw on invalid chunks");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeConsumed(t *testing.T) {
+	t.Parallel()
+
+	type call struct {
+		chunk        []byte
+		stream       bool
+		wantDecoded  string
+		wantConsumed int
+	}
+
+	testCases := []struct {
+		name     string
+		encoding EncodingName
+		calls    []call
+	}{
+		{
+			name:     "chunk ending mid-sequence",
+			encoding: UTF8EncodingFormat,
+			calls: []call{
+				{chunk: []byte{0x61, 0x62, 0xE6, 0xB0}, stream: true, wantDecoded: "ab", wantConsumed: 2},
+				{chunk: []byte{0xB4, 0x63}, stream: true, wantDecoded: "\u6C34c", wantConsumed: 4},
+				{chunk: []byte{}, wantDecoded: "", wantConsumed: 0},
+			},
+		},
+		{
+			name:     "chunk holding only part of a sequence",
+			encoding: UTF8EncodingFormat,
+			calls: []call{
+				{chunk: []byte{0xF0, 0x9D}, stream: true, wantDecoded: "", wantConsumed: 0},
+				{chunk: []byte{0x84}, stream: true, wantDecoded: "", wantConsumed: 0},
+				{chunk: []byte{0x9E}, stream: true, wantDecoded: "\U0001D11E", wantConsumed: 4},
+			},
+		},
+		{
+			name:     "flush of a truncated sequence",
+			encoding: UTF8EncodingFormat,
+			calls: []call{
+				{chunk: []byte{0x61, 0xE6}, stream: true, wantDecoded: "a", wantConsumed: 1},
+				{chunk: []byte{0xB0}, wantDecoded: "\uFFFD", wantConsumed: 2},
+			},
+		},
+		{
+			name:     "utf-16le odd chunk",
+			encoding: UTF16LEEncodingFormat,
+			calls: []call{
+				{chunk: []byte{0x61, 0x00, 0x62}, stream: true, wantDecoded: "a", wantConsumed: 2},
+				{chunk: []byte{0x00}, wantDecoded: "b", wantConsumed: 2},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+			require.NoError(t, err)
+
+			for _, c := range tc.calls {
+				decoded, consumed, err := td.DecodeConsumed(c.chunk, decodeOptions{Stream: c.stream})
+				require.NoError(t, err)
+				assert.Equal(t, c.wantDecoded, decoded)
+				assert.Equal(t, c.wantConsumed, consumed)
+			}
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder();
+
+			let result = decoder.decode(new Uint8Array([0x61, 0x62, 0xe6, 0xb0]), { stream: true, withConsumed: true });
+			assert_equals(result.value, "ab");
+			assert_equals(result.consumed, 2, "consumed should exclude the buffered tail");
+
+			result = decoder.decode(new Uint8Array([0xb4]), { withConsumed: true, output: "codepoints" });
+			assert_equals(result.value.length, 1);
+			assert_equals(result.value[0], 0x6c34);
+			assert_equals(result.consumed, 3, "consumed should include the previously buffered bytes");
+
+			assert_equals(decoder.decode(new Uint8Array([0x61])), "a", "withConsumed should default to false");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderStrippedBOM(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		encoding    EncodingName
+		options     textDecoderOptions
+		data        []byte
+		wantDecoded string
+		wantBOM     EncodingName
+	}{
+		{
+			name:        "utf-8 bom",
+			encoding:    UTF8EncodingFormat,
+			data:        []byte{0xEF, 0xBB, 0xBF, 0x61},
+			wantDecoded: "a",
+			wantBOM:     UTF8EncodingFormat,
+		},
+		{
+			name:        "utf-16le bom",
+			encoding:    UTF16LEEncodingFormat,
+			data:        []byte{0xFF, 0xFE, 0x61, 0x00},
+			wantDecoded: "a",
+			wantBOM:     UTF16LEEncodingFormat,
+		},
+		{
+			name:        "no bom",
+			encoding:    UTF8EncodingFormat,
+			data:        []byte{0x61},
+			wantDecoded: "a",
+		},
+		{
+			name:        "ignored bom",
+			encoding:    UTF8EncodingFormat,
+			options:     textDecoderOptions{IgnoreBOM: true},
+			data:        []byte{0xEF, 0xBB, 0xBF, 0x61},
+			wantDecoded: "\uFEFFa",
+		},
+		{
+			name:        "bom of another encoding",
+			encoding:    UTF16BEEncodingFormat,
+			data:        []byte{0xFF, 0xFE, 0x00, 0x61},
+			wantDecoded: "\uFFFEa",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, tc.options)
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(tc.data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantDecoded, decoded)
+			assert.Equal(t, tc.wantBOM, td.StrippedBOM())
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			let result = new TextDecoder().decode(new Uint8Array([0xef, 0xbb, 0xbf, 0x61]), { reportBOM: true });
+			assert_equals(result.value, "a");
+			assert_equals(result.bom, "utf-8");
+
+			result = new TextDecoder("utf-16le").decode(new Uint8Array([0xff, 0xfe, 0x61, 0x00]), { reportBOM: true });
+			assert_equals(result.value, "a");
+			assert_equals(result.bom, "utf-16le");
+
+			result = new TextDecoder().decode(new Uint8Array([0x61]), { reportBOM: true, withConsumed: true });
+			assert_equals(result.value, "a");
+			assert_equals(result.bom, null);
+			assert_equals(result.consumed, 1);
+
+			// Only the call the stream starts with can strip a byte order mark
+			const decoder = new TextDecoder();
+			assert_equals(decoder.decode(new Uint8Array([0xef, 0xbb]), { stream: true, reportBOM: true }).bom, null);
+			assert_equals(decoder.decode(new Uint8Array([0xbf, 0x61]), { stream: true, reportBOM: true }).bom, "utf-8");
+			assert_equals(decoder.decode(new Uint8Array([0xef, 0xbb, 0xbf]), { reportBOM: true }).bom, null);
+
+			assert_equals(new TextDecoder().decode(new Uint8Array([0x61])), "a", "reportBOM should default to false");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	intPtr := func(i int) *int { return &i }
+
+	// "ab水c" encoded as utf-8
+	data := []byte{0x61, 0x62, 0xE6, 0xB0, 0xB4, 0x63}
+
+	testCases := []struct {
+		name         string
+		maxBytes     *int
+		stream       bool
+		wantDecoded  string
+		wantConsumed int
+		wantPending  bool
+	}{
+		{
+			name:         "exact boundary",
+			maxBytes:     intPtr(5),
+			stream:       true,
+			wantDecoded:  "ab水",
+			wantConsumed: 5,
+		},
+		{
+			name:         "boundary mid-sequence",
+			maxBytes:     intPtr(4),
+			stream:       true,
+			wantDecoded:  "ab",
+			wantConsumed: 2,
+			wantPending:  true,
+		},
+		{
+			name:         "boundary mid-sequence when flushing",
+			maxBytes:     intPtr(4),
+			wantDecoded:  "ab\uFFFD",
+			wantConsumed: 4,
+		},
+		{
+			name:         "zero bytes",
+			maxBytes:     intPtr(0),
+			stream:       true,
+			wantDecoded:  "",
+			wantConsumed: 0,
+		},
+		{
+			name:         "beyond the buffer",
+			maxBytes:     intPtr(10),
+			wantDecoded:  "ab水c",
+			wantConsumed: 6,
+		},
+		{
+			name:         "unset",
+			wantDecoded:  "ab水c",
+			wantConsumed: 6,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, consumed, err := td.DecodeConsumed(data, decodeOptions{Stream: tc.stream, MaxBytes: tc.maxBytes})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantDecoded, decoded)
+			assert.Equal(t, tc.wantConsumed, consumed)
+			assert.Equal(t, tc.wantPending, td.Pending())
+		})
+	}
+
+	t.Run("negative", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		_, err = td.Decode(data, decodeOptions{MaxBytes: intPtr(-1)})
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			// A 4 bytes long field, which splits "\u6c34", followed by the rest of the record
+			const record = new Uint8Array([0x61, 0x62, 0xe6, 0xb0, 0xb4, 0x63]);
+			const decoder = new TextDecoder();
+
+			let result = decoder.decode(record, { stream: true, maxBytes: 4, withConsumed: true });
+			assert_equals(result.value, "ab");
+			assert_equals(result.consumed, 2);
+
+			assert_equals(decoder.decode(record.subarray(4)), "\u6c34c");
+
+			let error;
+			try {
+				decoder.decode(record, { maxBytes: -1 });
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof RangeError, "a negative maxBytes should throw a RangeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeMidStreamBOM(t *testing.T) {
+	t.Parallel()
+
+	// A U+FEFF character following the start of the stream is a zero
+	// width no-break space, rather than a byte order mark, and is kept.
+	testCases := []struct {
+		name     string
+		encoding EncodingName
+		chunks   [][]byte
+		want     string
+	}{
+		{
+			name:     "utf-8",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x41, 0xEF, 0xBB, 0xBF, 0x42}},
+			want:     "A\uFEFFB",
+		},
+		{
+			name:     "utf-8 leading and mid-stream",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0xEF, 0xBB, 0xBF, 0x41, 0xEF, 0xBB, 0xBF, 0x42}},
+			want:     "A\uFEFFB",
+		},
+		{
+			name:     "utf-8 chunk starting with U+FEFF",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x41}, {0xEF, 0xBB, 0xBF, 0x42}},
+			want:     "A\uFEFFB",
+		},
+		{
+			name:     "utf-8 U+FEFF split across chunks",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x41, 0xEF}, {0xBB}, {0xBF, 0x42}},
+			want:     "A\uFEFFB",
+		},
+		{
+			name:     "utf-16le",
+			encoding: UTF16LEEncodingFormat,
+			chunks:   [][]byte{{0x41, 0x00, 0xFF, 0xFE, 0x42, 0x00}},
+			want:     "A\uFEFFB",
+		},
+		{
+			name:     "utf-16le chunk starting with U+FEFF",
+			encoding: UTF16LEEncodingFormat,
+			chunks:   [][]byte{{0x41, 0x00}, {0xFF, 0xFE, 0x42, 0x00}},
+			want:     "A\uFEFFB",
+		},
+		{
+			name:     "utf-16le first chunk shorter than a byte order mark",
+			encoding: UTF16LEEncodingFormat,
+			chunks:   [][]byte{{0x41}, {0x00, 0xFF, 0xFE, 0x42, 0x00}},
+			want:     "A\uFEFFB",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{IgnoreBOM: false})
+			require.NoError(t, err)
+
+			var decoded string
+			for i, chunk := range tc.chunks {
+				got, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+
+				decoded += got
+			}
+
+			assert.Equal(t, tc.want, decoded)
+		})
+	}
+}
+
+func TestTextDecoderDecodeAutoReBOM(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		encoding  EncodingName
+		autoReBOM bool
+		chunks    [][]byte
+		want      string
+	}{
+		{
+			name:      "utf-16le byte order mark",
+			encoding:  UTF8EncodingFormat,
+			autoReBOM: true,
+			chunks:    [][]byte{{0xFF, 0xFE, 0x68, 0x00, 0x69, 0x00}},
+			want:      "hi",
+		},
+		{
+			name:      "utf-16be byte order mark",
+			encoding:  UTF8EncodingFormat,
+			autoReBOM: true,
+			chunks:    [][]byte{{0xFE, 0xFF, 0x00, 0x68, 0x00, 0x69}},
+			want:      "hi",
+		},
+		{
+			name:      "utf-16le byte order mark split across chunks",
+			encoding:  UTF8EncodingFormat,
+			autoReBOM: true,
+			chunks:    [][]byte{{0xFF}, {0xFE, 0x68}, {0x00, 0x69, 0x00}},
+			want:      "hi",
+		},
+		{
+			name:      "utf-8 byte order mark",
+			encoding:  UTF8EncodingFormat,
+			autoReBOM: true,
+			chunks:    [][]byte{{0xEF, 0xBB, 0xBF, 0x68, 0x69}},
+			want:      "hi",
+		},
+		{
+			name:      "no byte order mark",
+			encoding:  UTF8EncodingFormat,
+			autoReBOM: true,
+			chunks:    [][]byte{{0x68, 0x69}},
+			want:      "hi",
+		},
+		{
+			name:      "utf-16le byte order mark past the start of the stream",
+			encoding:  UTF8EncodingFormat,
+			autoReBOM: true,
+			chunks:    [][]byte{{0x68}, {0xFF, 0xFE, 0x69}},
+			want:      "h\uFFFD\uFFFDi",
+		},
+		{
+			name:      "utf-16le byte order mark with the option off",
+			encoding:  UTF8EncodingFormat,
+			autoReBOM: false,
+			chunks:    [][]byte{{0xFF, 0xFE, 0x68, 0x00, 0x69, 0x00}},
+			want:      "\uFFFD\uFFFDh\x00i\x00",
+		},
+		{
+			name:      "utf-16le byte order mark with a non utf-8 decoder",
+			encoding:  Windows1252EncodingFormat,
+			autoReBOM: true,
+			chunks:    [][]byte{{0xFF, 0xFE, 0x68, 0x00}},
+			want:      "\u00FF\u00FEh\x00",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{AutoReBOM: tc.autoReBOM})
+			require.NoError(t, err)
+
+			var decoded string
+			for i, chunk := range tc.chunks {
+				got, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+
+				decoded += got
+			}
+
+			assert.Equal(t, tc.want, decoded)
+			assert.Equal(t, tc.encoding, td.Encoding, "the decoder should be back to its encoding once the stream ends")
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const utf16 = new Uint8Array([0xff, 0xfe, 0x68, 0x00, 0x69, 0x00]);
+
+			const decoder = new TextDecoder("utf-8", { autoReBOM: true });
+			assert_equals(decoder.decode(utf16), "hi", "a utf-16le stream should be decoded as such");
+			assert_equals(decoder.encoding, "utf-8", "the encoding should be left as is");
+			assert_equals(decoder.decode(new Uint8Array([0x68, 0x00])), "h\x00", "the next stream should be decoded as utf-8");
+
+			assert_equals(new TextDecoder("utf-8").decode(utf16), "\uFFFD\uFFFDh\x00i\x00", "the option should be off by default");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeBinaryString(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const decoder = new TextDecoder();
+
+		// "caf\u00e9\u6c34" encoded as utf-8, one byte per character
+		const binary = "caf\xc3\xa9\xe6\xb0\xb4";
+		assert_equals(decoder.decode(binary, { binaryString: true }), "caf\u00e9\u6c34");
+		assert_equals(decoder.decode("", { binaryString: true }), "");
+
+		// Binary strings can be streamed as any other input
+		assert_equals(decoder.decode("a\xe6", { binaryString: true, stream: true }), "a");
+		assert_equals(decoder.decode("\xb0\xb4", { binaryString: true }), "\u6c34");
+
+		assert_equals(new TextDecoder("windows-1252").decode("caf\xe9", { binaryString: true }), "caf\u00e9");
+
+		let error;
+		try {
+			decoder.decode("caf\u0100", { binaryString: true });
+		} catch (e) {
+			error = e;
+		}
+		assert_true(error instanceof TypeError, "a character beyond U+00FF should throw a TypeError");
+
+		error = undefined;
+		try {
+			decoder.decode(new Uint8Array([0x61]), { binaryString: true });
+		} catch (e) {
+			error = e;
+		}
+		assert_true(error instanceof TypeError, "a buffer source should throw a TypeError under the binaryString option");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderDecodeCodeUnits(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		// "a\u6c34\u{1d11e}" as UTF-16 code units
+		const units = [0x61, 0x6c34, 0xd834, 0xdd1e];
+
+		assert_equals(new TextDecoder("utf-16le").decode(new Uint16Array(units)), "a\u6c34\u{1d11e}");
+		assert_equals(new TextDecoder("utf-16be").decode(new Uint16Array(units)), "a\u6c34\u{1d11e}");
+		assert_equals(new TextDecoder("utf-16le").decode(new Int16Array(units)), "a\u6c34\u{1d11e}");
+
+		// Views over a portion of a buffer only decode the code units they hold
+		assert_equals(new TextDecoder("utf-16le").decode(new Uint16Array(units).subarray(1, 2)), "\u6c34");
+
+		// Surrogate pairs can be split across streamed chunks
+		const decoder = new TextDecoder("utf-16be");
+		assert_equals(decoder.decode(new Uint16Array([0x61, 0xd834]), { stream: true }), "a");
+		assert_equals(decoder.decode(new Uint16Array([0xdd1e])), "\u{1d11e}");
+
+		// Other encodings decode the bytes 16-bit TypedArrays view as is
+		assert_equals(new TextDecoder("utf-8").decode(new Uint16Array([0x6261])).length, 2);
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderDecodeWithCallback(t *testing.T) {
+	t.Parallel()
+
+	t.Run("chunk sizes", func(t *testing.T) {
+		t.Parallel()
+
+		data := []byte(strings.Repeat("a\u00E9\u6C34\U0001F600", 50))
+
+		for chunkSize := 1; chunkSize <= 8; chunkSize++ {
+			td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+			require.NoError(t, err)
+
+			var pieces []string
+			err = td.DecodeWithCallback(data, chunkSize, func(piece string) error {
+				assert.NotEmpty(t, piece, "empty pieces should not be passed")
+				pieces = append(pieces, piece)
+				return nil
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, string(data), strings.Join(pieces, ""), "chunk size %d", chunkSize)
+			assert.False(t, td.Pending(), "the decoder should be flushed")
+		}
+	})
+
+	t.Run("truncated sequence at the end", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		var pieces []string
+		err = td.DecodeWithCallback([]byte{0x61, 0xE6, 0xB0}, 2, func(piece string) error {
+			pieces = append(pieces, piece)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "\uFFFD"}, pieces)
+	})
+
+	t.Run("callback error", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		stop := errors.New("stop")
+
+		var calls int
+		err = td.DecodeWithCallback([]byte("abcdef\xE6"), 2, func(string) error {
+			calls++
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, calls, "decoding should stop")
+		assert.False(t, td.Pending(), "the decoder should be reset")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			name      string
+			encoding  EncodingName
+			data      []byte
+			chunkSize int
+			options   textDecoderOptions
+			want      ErrorName
+		}{
+			{name: "negative chunk size", encoding: UTF8EncodingFormat, data: []byte("a"), chunkSize: -1, want: RangeError},
+			{
+				name:      "fatal truncated code unit",
+				encoding:  UTF16LEEncodingFormat,
+				data:      []byte{0x61, 0x00, 0x62},
+				chunkSize: 1,
+				options:   textDecoderOptions{Fatal: true},
+				want:      TypeError,
+			},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				td, err := NewTextDecoder(nil, tc.encoding, tc.options)
+				require.NoError(t, err)
+
+				err = td.DecodeWithCallback(tc.data, tc.chunkSize, func(string) error { return nil })
+
+				var encodingErr *Error
+				require.ErrorAs(t, err, &encodingErr)
+				assert.Equal(t, tc.want, encodingErr.Name)
+			})
+		}
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const data = new TextEncoder().encode("a\u00e9\u6c34\u{1f600}".repeat(10000));
+
+			const pieces = [];
+			const decoder = new TextDecoder();
+			const result = decoder.decodeWithCallback(data, (piece) => pieces.push(piece), { chunkSize: 1000 });
+			assert_equals(result, undefined, "decodeWithCallback should return nothing");
+			assert_equals(pieces.length, Math.ceil(data.length / 1000), "the callback should be called for each chunk");
+			assert_equals(pieces.join(""), new TextDecoder().decode(data), "the pieces should concatenate to a normal decode");
+
+			const defaults = [];
+			decoder.decodeWithCallback(data.buffer, (piece) => defaults.push(piece));
+			assert_equals(defaults.join(""), pieces.join(""), "the default chunk size should decode the same");
+
+			let error;
+			try {
+				decoder.decodeWithCallback(data, () => { throw new Error("stop"); });
+			} catch (e) {
+				error = e;
+			}
+			assert_equals(error.message, "stop", "errors thrown by the callback should propagate");
+
+			error = undefined;
+			try {
+				decoder.decodeWithCallback(data, "not a function");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "a callback which is not a function should throw a TypeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeUTF16DataView(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		// "a\u6c34\u{1d11e}" written as UTF-16BE through a DataView
+		const units = [0x61, 0x6c34, 0xd834, 0xdd1e];
+		const view = new DataView(new ArrayBuffer(2 * units.length));
+		units.forEach((unit, i) => view.setUint16(2 * i, unit, false));
+
+		// Reading the view in little-endian order has no bearing on decoding
+		assert_equals(view.getUint16(0, true), 0x6100, "the view should be read in little-endian order");
+
+		assert_equals(new TextDecoder("utf-16be").decode(view), "a\u6c34\u{1d11e}", "the label should govern the byte order");
+		assert_equals(
+			new TextDecoder("utf-16le").decode(view),
+			"\u6100\u346c\u34d8\u1edd",
+			"a little-endian decoder should read the same bytes in its own order"
+		);
+
+		// Views over a portion of a buffer only decode the bytes they hold
+		assert_equals(new TextDecoder("utf-16be").decode(new DataView(view.buffer, 2, 2)), "\u6c34");
+
+		// Surrogate pairs can be split across streamed views
+		const decoder = new TextDecoder("utf-16be");
+		assert_equals(decoder.decode(new DataView(view.buffer, 0, 5), { stream: true }), "a\u6c34");
+		assert_equals(decoder.decode(new DataView(view.buffer, 5)), "\u{1d11e}");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderClone(t *testing.T) {
+	t.Parallel()
+
+	t.Run("clone mid-stream", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		decoded, err := td.Decode([]byte{0x61, 0xE6, 0xB0}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, "a", decoded)
+
+		clone := td.Clone()
+		assert.True(t, clone.Pending())
+
+		decoded, err = td.Decode([]byte{0xB4}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "\u6C34", decoded)
+
+		decoded, err = clone.Decode([]byte{0x62}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "\uFFFDb", decoded)
+	})
+
+	t.Run("clone carries the byte order mark state", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		_, err = td.Decode([]byte{0xEF, 0xBB, 0xBF, 0x61}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+
+		// The byte order mark was seen already, hence a second one is decoded as is
+		decoded, err := td.Clone().Decode([]byte{0xEF, 0xBB, 0xBF}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "\uFEFF", decoded)
+	})
+
+	t.Run("clone carries the replacement decoder state", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, "replacement", textDecoderOptions{})
+		require.NoError(t, err)
+
+		decoded, err := td.Decode([]byte{0x61}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, "\uFFFD", decoded)
+
+		decoded, err = td.Clone().Decode([]byte{0x62}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "", decoded)
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder("utf-16le", { ignoreBOM: true });
+			assert_equals(decoder.decode(new Uint8Array([0x61, 0x00, 0x62]), { stream: true }), "a");
+
+			const clone = decoder.clone();
+			assert_equals(clone.encoding, "utf-16le");
+			assert_true(clone.ignoreBOM, "clone should carry the options");
+			assert_true(clone.pending, "clone should carry the buffered bytes");
+
+			assert_equals(decoder.decode(new Uint8Array([0x00])), "b");
+			assert_equals(clone.decode(new Uint8Array([0x01])), "\u0162");
+			assert_false(decoder.pending);
+			assert_false(clone.pending);
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeBooleanOptions(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const decoder = new TextDecoder();
+
+		assert_equals(decoder.decode(new Uint8Array([0x61, 0xe6]), true), "a", "true should stream");
+		assert_true(decoder.pending, "true should buffer the trailing sequence");
+		assert_equals(decoder.decode(new Uint8Array([0xb0, 0xb4]), false), "\u6c34", "false should flush");
+		assert_false(decoder.pending);
+
+		assert_equals(decoder.decode(new Uint8Array([0x61, 0xe6]), { stream: true }), "a");
+		assert_true(decoder.pending, "the stream option should buffer the trailing sequence");
+		assert_equals(decoder.decode(new Uint8Array([0xb0, 0xb4])), "\u6c34", "no options should flush");
+		assert_false(decoder.pending);
+
+		assert_equals(decoder.decode(new Uint8Array([0x61, 0xe6])), "a\ufffd", "no options should not stream");
+		assert_equals(decoder.decode(new Uint8Array([0x61, 0xe6]), undefined), "a\ufffd", "undefined options should not stream");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderDefaultStream(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const decoder = new TextDecoder("utf-8", { defaultStream: true });
+
+		assert_equals(decoder.decode(new Uint8Array([0x61, 0xe6])), "a", "no options should stream");
+		assert_true(decoder.pending, "no options should buffer the trailing sequence");
+		assert_equals(decoder.decode(new Uint8Array([0xb0]), {}), "", "options omitting stream should stream");
+		assert_equals(decoder.decode(new Uint8Array([0xb4, 0xe6]), { stream: undefined }), "\u6c34", "an undefined stream option should stream");
+		assert_true(decoder.pending);
+
+		assert_equals(decoder.decode(new Uint8Array([]), { stream: false }), "\ufffd", "an explicit stream option should flush");
+		assert_false(decoder.pending);
+
+		assert_equals(decoder.decode(new Uint8Array([0x61, 0xe6]), { stream: true }), "a");
+		assert_equals(decoder.decode(new Uint8Array([0xb0, 0xb4]), false), "\u6c34", "false should flush");
+		assert_false(decoder.pending);
+
+		const clone = new TextDecoder("utf-8", { defaultStream: true }).clone();
+		assert_equals(clone.decode(new Uint8Array([0xe6])), "", "the clone should carry the option");
+
+		const streamless = new TextDecoder();
+		assert_equals(streamless.decode(new Uint8Array([0x61, 0xe6])), "a\ufffd", "the option should be off by default");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderResetOnError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		resetOnError bool
+		wantPending  bool
+	}{
+		{name: "reset", resetOnError: true, wantPending: false},
+		{name: "kept", resetOnError: false, wantPending: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{Fatal: true, NoResetOnError: !tc.resetOnError})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode([]byte{0x61, 0xE6, 0xB0}, decodeOptions{Stream: true})
+			require.NoError(t, err)
+			assert.Equal(t, "a", decoded)
+
+			// The buffered sequence is malformed, and the chunk
+			// ends with the first byte of another one.
+			_, err = td.Decode([]byte{0xFF, 0xE6}, decodeOptions{Stream: true})
+
+			var encodingErr *Error
+			require.ErrorAs(t, err, &encodingErr)
+			assert.Equal(t, TypeError, encodingErr.Name)
+			assert.Equal(t, tc.wantPending, td.Pending())
+
+			if !tc.resetOnError {
+				return
+			}
+
+			decoded, err = td.Decode([]byte{0xE6, 0xB0, 0xB4}, decodeOptions{Stream: true})
+			require.NoError(t, err)
+			assert.Equal(t, "\u6C34", decoded, "the decoder should start a new stream")
+		})
+	}
+
+	t.Run("reset by default", func(t *testing.T) {
+		t.Parallel()
+
+		newDecoders := map[string]func() (*TextDecoder, error){
+			"NewTextDecoder": func() (*TextDecoder, error) {
+				return NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{Fatal: true})
+			},
+			"GetDecoder": func() (*TextDecoder, error) {
+				return GetDecoder(nil, UTF8EncodingFormat, textDecoderOptions{Fatal: true})
+			},
+		}
+
+		for name, newDecoder := range newDecoders {
+			td, err := newDecoder()
+			require.NoError(t, err)
+			assert.True(t, td.ResetOnError, name)
+
+			_, err = td.Decode([]byte{0x61, 0xE6, 0xB0}, decodeOptions{Stream: true})
+			require.NoError(t, err)
+
+			_, err = td.Decode([]byte{0xFF, 0xE6}, decodeOptions{Stream: true})
+			require.Error(t, err, name)
+			assert.False(t, td.Pending(), name)
+		}
+	})
+
+	t.Run("byte order mark of the new stream", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{Fatal: true})
+		require.NoError(t, err)
+
+		_, err = td.Decode([]byte{0xEF, 0xBB, 0xBF, 0x61, 0xFF}, decodeOptions{Stream: true})
+		require.Error(t, err)
+
+		decoded, err := td.Decode([]byte{0xEF, 0xBB, 0xBF, 0x62}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "b", decoded, "the byte order mark of the new stream should be stripped")
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const fail = (decoder, bytes) => {
+				let error;
+				try {
+					decoder.decode(new Uint8Array(bytes), { stream: true });
+				} catch (e) {
+					error = e;
+				}
+				assert_true(error instanceof TypeError, "malformed input should throw a TypeError in fatal mode");
+			};
+
+			const decoder = new TextDecoder("utf-8", { fatal: true });
+			assert_equals(decoder.decode(new Uint8Array([0x61, 0xe6, 0xb0]), { stream: true }), "a");
+			fail(decoder, [0xff, 0xe6]);
+			assert_false(decoder.pending, "the decoder should be reset by default");
+			assert_equals(decoder.decode(new Uint8Array([0xe6, 0xb0, 0xb4])), "\u6c34", "the decoder should be reusable");
+
+			const undefinedOption = new TextDecoder("utf-8", { fatal: true, resetOnError: undefined });
+			fail(undefinedOption, [0xff, 0xe6]);
+			assert_false(undefinedOption.pending, "an undefined option should reset the decoder");
+
+			const keeping = new TextDecoder("utf-8", { fatal: true, resetOnError: false });
+			fail(keeping, [0xff, 0xe6]);
+			assert_true(keeping.pending, "the decoder should keep its state when told to");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeFatalOverride(t *testing.T) {
+	t.Parallel()
+
+	fatal, lenient := true, false
+
+	testCases := []struct {
+		name      string
+		label     string
+		fatal     bool
+		override  *bool
+		data      []byte
+		want      string
+		wantError bool
+	}{
+		{name: "lenient decoder", label: "utf-8", data: []byte{0x61, 0xFF}, want: "a\uFFFD"},
+		{name: "fatal call on a lenient decoder", label: "utf-8", override: &fatal, data: []byte{0x61, 0xFF}, wantError: true},
+		{name: "fatal decoder", label: "utf-8", fatal: true, data: []byte{0x61, 0xFF}, wantError: true},
+		{name: "lenient call on a fatal decoder", label: "utf-8", fatal: true, override: &lenient, data: []byte{0x61, 0xFF}, want: "a\uFFFD"},
+		{name: "fatal call on valid data", label: "utf-8", override: &fatal, data: []byte{0x61, 0x62}, want: "ab"},
+		{
+			name:      "fatal call on truncated utf-16",
+			label:     "utf-16le",
+			override:  &fatal,
+			data:      []byte{0x61, 0x00, 0x62},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.label, textDecoderOptions{Fatal: tc.fatal})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(tc.data, decodeOptions{Fatal: tc.override})
+			if tc.wantError {
+				var encodingErr *Error
+				require.ErrorAs(t, err, &encodingErr)
+				assert.Equal(t, TypeError, encodingErr.Name)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+			assert.Equal(t, tc.fatal, td.Fatal, "the override should not change the decoder's error mode")
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder("utf-8");
+			const malformed = new Uint8Array([0x61, 0xff]);
+
+			let error;
+			try {
+				decoder.decode(malformed, { fatal: true });
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "a fatal call should throw a TypeError on malformed data");
+			assert_false(decoder.fatal, "the fatal property should reflect the construction value");
+
+			assert_equals(decoder.decode(malformed), "a\ufffd", "the next call should be lenient again");
+			assert_equals(decoder.decode(malformed, { fatal: undefined }), "a\ufffd", "an undefined option should be lenient");
+			assert_equals(decoder.decode(malformed, { fatal: 0 }), "a\ufffd", "a falsy option should be lenient");
+
+			const fatalDecoder = new TextDecoder("utf-8", { fatal: true });
+			assert_equals(fatalDecoder.decode(malformed, { fatal: false }), "a\ufffd", "a lenient call should substitute");
+			assert_true(fatalDecoder.fatal, "the fatal property should reflect the construction value");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDescriptorLabel(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const data = new Uint8Array([0x63, 0x61, 0x66, 0xe9]);
+
+		for (const [descriptor, label] of [
+			[{ name: "windows-1252" }, "windows-1252"],
+			[{ label: "latin1" }, "latin1"],
+			[{ name: "utf-16le", label: "utf-8" }, "utf-16le"],
+			[new String("iso-8859-2"), "iso-8859-2"],
+		]) {
+			const fromDescriptor = new TextDecoder(descriptor);
+			const fromString = new TextDecoder(label);
+			assert_equals(fromDescriptor.encoding, fromString.encoding, "encoding of " + label);
+			assert_equals(fromDescriptor.decode(data), fromString.decode(data), "decoded with " + label);
+		}
+
+		let error;
+		try {
+			new TextDecoder({ encoding: "utf-8" });
+		} catch (e) {
+			error = e;
+		}
+		assert_true(error instanceof RangeError, "an object without name nor label should be coerced to a string");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderLoneSurrogates(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+		options textDecoderOptions
+	}{
+		{
+			name: "lone high surrogate replaced",
+			data: []byte{0x61, 0x00, 0x3D, 0xD8, 0x62, 0x00},
+			want: "a\uFFFDb",
+		},
+		{
+			name: "lone high surrogate at the end replaced",
+			data: []byte{0x61, 0x00, 0x3D, 0xD8},
+			want: "a\uFFFD",
+		},
+		{
+			name: "lone low surrogate replaced",
+			data: []byte{0x00, 0xDE, 0x61, 0x00},
+			want: "\uFFFDa",
+		},
+		{
+			name: "high surrogate followed by a pair",
+			data: []byte{0x3D, 0xD8, 0x3D, 0xD8, 0x00, 0xDE},
+			want: "\uFFFD\U0001F600",
+		},
+		{
+			name:    "lone high surrogate in fatal mode",
+			data:    []byte{0x61, 0x00, 0x3D, 0xD8, 0x62, 0x00},
+			options: textDecoderOptions{Fatal: true},
+			wantErr: true,
+		},
+		{
+			name:    "lone high surrogate with the error policy",
+			data:    []byte{0x61, 0x00, 0x3D, 0xD8},
+			options: textDecoderOptions{LoneSurrogates: LoneSurrogatesError},
+			wantErr: true,
+		},
+		{
+			name:    "truncated code unit with the error policy",
+			data:    []byte{0x61, 0x00, 0x62},
+			options: textDecoderOptions{LoneSurrogates: LoneSurrogatesError},
+			want:    "a\uFFFD",
+		},
+		{
+			name:    "surrogate pair with the error policy",
+			data:    []byte{0x3D, 0xD8, 0x00, 0xDE},
+			options: textDecoderOptions{LoneSurrogates: LoneSurrogatesError},
+			want:    "\U0001F600",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, UTF16LEEncodingFormat, tc.options)
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(tc.data, decodeOptions{})
+			if tc.wantErr {
+				var encodingErr *Error
+				require.ErrorAs(t, err, &encodingErr)
+				assert.Equal(t, TypeError, encodingErr.Name)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+		})
+	}
+
+	t.Run("streamed", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF16LEEncodingFormat, textDecoderOptions{LoneSurrogates: LoneSurrogatesError})
+		require.NoError(t, err)
+
+		// A high surrogate ending a chunk awaits the next one
+		decoded, err := td.Decode([]byte{0x61, 0x00, 0x3D, 0xD8}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, "a", decoded)
+
+		_, err = td.Decode([]byte{0x62, 0x00}, decodeOptions{})
+		assert.Error(t, err, "the high surrogate should turn out unpaired")
+	})
+
+	t.Run("unsupported policy", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewTextDecoder(nil, UTF16LEEncodingFormat, textDecoderOptions{LoneSurrogates: "ignore"})
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const loneHigh = new Uint8Array([0x61, 0x00, 0x3d, 0xd8, 0x62, 0x00]);
+
+			assert_equals(new TextDecoder("utf-16le").decode(loneHigh), "a\ufffdb", "a lone high surrogate should be replaced by default");
+			assert_equals(new TextDecoder("utf-16le", { loneSurrogates: "replace" }).decode(loneHigh), "a\ufffdb");
+
+			for (const [options, message] of [
+				[{ fatal: true }, "a lone high surrogate should throw in fatal mode"],
+				[{ loneSurrogates: "error" }, "a lone high surrogate should throw with the error policy"],
+			]) {
+				let error;
+				try {
+					new TextDecoder("utf-16le", options).decode(loneHigh);
+				} catch (e) {
+					error = e;
+				}
+				assert_true(error instanceof TypeError, message);
+			}
+
+			let error;
+			try {
+				new TextDecoder("utf-16le", { loneSurrogates: "ignore" });
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "an unsupported policy should throw a TypeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderNewline(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		label   string
+		newline NewlinePolicy
+		chunks  [][]byte
+		want    string
+	}{
+		{
+			name:    "none",
+			newline: NewlineNone,
+			chunks:  [][]byte{[]byte("a\r\nb\rc\nd")},
+			want:    "a\r\nb\rc\nd",
+		},
+		{
+			name:    "lf",
+			newline: NewlineLF,
+			chunks:  [][]byte{[]byte("a\r\nb\rc\nd\r\r\n")},
+			want:    "a\nb\nc\nd\n\n",
+		},
+		{
+			name:    "crlf",
+			newline: NewlineCRLF,
+			chunks:  [][]byte{[]byte("a\r\nb\rc\nd\n\r")},
+			want:    "a\r\nb\r\nc\r\nd\r\n\r\n",
+		},
+		{
+			name:    "lf with crlf split across chunks",
+			newline: NewlineLF,
+			chunks:  [][]byte{[]byte("a\r"), []byte("\nb")},
+			want:    "a\nb",
+		},
+		{
+			name:    "crlf with crlf split across chunks",
+			newline: NewlineCRLF,
+			chunks:  [][]byte{[]byte("a\r"), []byte("\nb")},
+			want:    "a\r\nb",
+		},
+		{
+			name:    "cr ending a chunk followed by text",
+			newline: NewlineLF,
+			chunks:  [][]byte{[]byte("a\r"), []byte("b\n")},
+			want:    "a\nb\n",
+		},
+		{
+			name:    "crlf split by an empty chunk",
+			newline: NewlineLF,
+			chunks:  [][]byte{[]byte("a\r"), {}, []byte("\nb")},
+			want:    "a\nb",
+		},
+		{
+			name:    "crlf split by a buffered sequence",
+			newline: NewlineLF,
+			chunks:  [][]byte{{0x61, 0x0D, 0xE6}, {0xB0, 0xB4, 0x0A}},
+			want:    "a\n\u6C34\n",
+		},
+		{
+			name:    "utf-16le crlf split across chunks",
+			label:   UTF16LEEncodingFormat,
+			newline: NewlineLF,
+			chunks:  [][]byte{{0x61, 0x00, 0x0D}, {0x00, 0x0A, 0x00}},
+			want:    "a\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.label, textDecoderOptions{Newline: tc.newline})
+			require.NoError(t, err)
+
+			var got string
+			for i, chunk := range tc.chunks {
+				decoded, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+
+				got += decoded
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("line feed starting a new stream", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{Newline: NewlineLF})
+		require.NoError(t, err)
+
+		decoded, err := td.Decode([]byte("a\r"), decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "a\n", decoded)
+
+		decoded, err = td.Decode([]byte("\nb"), decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "\nb", decoded, "the line feed of a new stream should be kept")
+	})
+
+	t.Run("unsupported policy", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{Newline: "cr"})
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder("utf-8", { newline: "lf" });
+			const first = decoder.decode(new Uint8Array([0x61, 0x0d]), { stream: true });
+			const second = decoder.decode(new Uint8Array([0x0a, 0x62, 0x0d, 0x63]));
+			assert_equals(first + second, "a\nb\nc", "a crlf split across chunks should be a single line feed");
+
+			const crlf = new TextDecoder("utf-8", { newline: "crlf" });
+			assert_equals(crlf.decode(new Uint8Array([0x61, 0x0a, 0x62])), "a\r\nb");
+
+			assert_equals(new TextDecoder().decode(new Uint8Array([0x61, 0x0d, 0x0a])), "a\r\n", "line endings should be kept by default");
+
+			let error;
+			try {
+				new TextDecoder("utf-8", { newline: "cr" });
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "an unsupported policy should throw a TypeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderStreamOptionCoercion(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		stream     string
+		wantStream bool
+	}{
+		{name: "one", stream: `1`, wantStream: true},
+		{name: "true string", stream: `"true"`, wantStream: true},
+		{name: "false string", stream: `"false"`, wantStream: true},
+		{name: "object", stream: `{}`, wantStream: true},
+		{name: "zero", stream: `0`, wantStream: false},
+		{name: "empty string", stream: `""`, wantStream: false},
+		{name: "null", stream: `null`, wantStream: false},
+		{name: "NaN", stream: `NaN`, wantStream: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := goja.New()
+
+			v, err := rt.RunString(`({ stream: ` + tc.stream + ` })`)
+			require.NoError(t, err)
+
+			// The coercion should not depend on the default
+			for _, defaultStream := range []bool{false, true} {
+				options, err := parseDecodeOptions(rt, v, defaultStream)
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantStream, options.Stream)
+			}
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder();
+
+			assert_equals(decoder.decode(new Uint8Array([0x61, 0xe6]), { stream: 1 }), "a", "1 should stream");
+			assert_true(decoder.pending, "1 should buffer the trailing sequence");
+			assert_equals(decoder.decode(new Uint8Array([0xb0]), { stream: "true" }), "", "\"true\" should stream");
+			assert_true(decoder.pending, "\"true\" should buffer the trailing sequence");
+			assert_equals(decoder.decode(new Uint8Array([0xb4, 0xe6]), { stream: 0 }), "\u6c34\ufffd", "0 should flush");
+			assert_false(decoder.pending, "0 should end the stream");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderBooleanOptions(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		assert_true(new TextDecoder("utf-8", true).fatal, "true should be fatal");
+		assert_false(new TextDecoder("utf-8", false).fatal, "false should not be fatal");
+		assert_true(newDecoder("utf-8", true).fatal, "true should be fatal with the factory");
+
+		const decoder = new TextDecoder("utf-8", { fatal: true, ignoreBOM: true });
+		assert_true(decoder.fatal, "the fatal option should be fatal");
+		assert_true(decoder.ignoreBOM, "the ignoreBOM option should ignore the byte order mark");
+
+		assert_false(new TextDecoder("utf-8", {}).fatal, "empty options should not be fatal");
+		assert_false(new TextDecoder("utf-8", undefined).fatal, "undefined options should not be fatal");
+		assert_false(new TextDecoder("utf-8").fatal, "no options should not be fatal");
+		assert_false(new TextDecoder("utf-8", true).ignoreBOM, "true should not ignore the byte order mark");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderSubstitutions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		encoding EncodingName
+		chunks   [][]byte
+		want     []int
+	}{
+		{
+			name:     "valid input",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x61, 0xE6, 0xB0, 0xB4}},
+			want:     []int{0},
+		},
+		{
+			name:     "encoded replacement character",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x61, 0xEF, 0xBF, 0xBD}},
+			want:     []int{0},
+		},
+		{
+			name:     "invalid bytes",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x61, 0xFF, 0x80, 0x62}},
+			want:     []int{2},
+		},
+		{
+			name:     "invalid byte next to an encoded replacement character",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0xE6, 0xEF, 0xBF, 0xBD}},
+			want:     []int{1},
+		},
+		{
+			name:     "overlong sequence",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0xC0, 0xAF}},
+			want:     []int{2},
+		},
+		{
+			name:     "truncated sequence flushed",
+			encoding: UTF8EncodingFormat,
+			chunks:   [][]byte{{0x61, 0xE6, 0xB0}, {}},
+			want:     []int{0, 1},
+		},
+		{
+			name:     "utf-16le odd byte flushed",
+			encoding: UTF16LEEncodingFormat,
+			chunks:   [][]byte{{0xFD, 0xFF, 0x61}},
+			want:     []int{1},
+		},
+		{
+			name:     "utf-16le unpaired surrogate",
+			encoding: UTF16LEEncodingFormat,
+			chunks:   [][]byte{{0x00, 0xDC, 0x61, 0x00}},
+			want:     []int{1},
+		},
+		{
+			name:     "utf-16be encoded replacement character",
+			encoding: UTF16BEEncodingFormat,
+			chunks:   [][]byte{{0xFF, 0xFD}},
+			want:     []int{0},
+		},
+		{
+			name:     "shift_jis invalid trail byte",
+			encoding: ShiftJISEncodingFormat,
+			chunks:   [][]byte{{0x81, 0x20}},
+			want:     []int{1},
+		},
+		{
+			name:     "replacement encoding",
+			encoding: ReplacementEncodingFormat,
+			chunks:   [][]byte{{0x61, 0x62}},
+			want:     []int{1},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+			require.NoError(t, err)
+
+			for i, chunk := range tc.chunks {
+				_, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+				assert.Equal(t, tc.want[i], td.Substitutions(), "chunk %d", i)
+			}
+		})
+	}
+
+	t.Run("chunked calls", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		_, err = td.DecodeAll([][]byte{{0x61, 0xFF}, {0xFE, 0x62}, {0xE6}})
+		require.NoError(t, err)
+		assert.Equal(t, 3, td.Substitutions(), "the chunks of DecodeAll should count as a single call")
+
+		err = td.DecodeWithCallback([]byte{0xFF, 0x61, 0xFE}, 1, func(string) error { return nil })
+		require.NoError(t, err)
+		assert.Equal(t, 2, td.Substitutions(), "the chunks of DecodeWithCallback should count as a single call")
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder();
+			assert_equals(decoder.decodeErrors, 0);
+
+			decoder.decode(new Uint8Array([0x61, 0xff, 0xfe]));
+			assert_equals(decoder.decodeErrors, 2);
+
+			decoder.decode(new Uint8Array([0x61]));
+			assert_equals(decoder.decodeErrors, 0, "decodeErrors should reflect the last call only");
+
+			decoder.decodeErrors = 3;
+			assert_equals(decoder.decodeErrors, 0, "decodeErrors should be read-only");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeFlagErrors(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const decoder = new TextDecoder();
+
+		const clean = decoder.decode(new Uint8Array([0x61, 0xe6, 0xb0, 0xb4]), { flagErrors: true });
+		assert_equals(clean.value, "a\u6c34", "clean value");
+		assert_false(clean.hadErrors, "a clean decode should have no errors");
+
+		const malformed = decoder.decode(new Uint8Array([0x61, 0xff, 0x62]), { flagErrors: true });
+		assert_equals(malformed.value, "a\ufffdb", "malformed value");
+		assert_true(malformed.hadErrors, "a malformed decode should have errors");
+
+		const legit = decoder.decode(new Uint8Array([0x61, 0xef, 0xbf, 0xbd]), { flagErrors: true });
+		assert_equals(legit.value, "a\ufffd", "legit replacement character value");
+		assert_false(legit.hadErrors, "an encoded replacement character should not be an error");
+
+		const truncated = decoder.decode(new Uint8Array([0x61, 0xe6]), { flagErrors: true });
+		assert_true(truncated.hadErrors, "a truncated sequence flushed should be an error");
+
+		assert_equals(decoder.decode(new Uint8Array([0x61])), "a", "the option should be off by default");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderDecodeValue(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		label     string
+		source    string
+		want      string
+		wantError bool
+	}{
+		{
+			name:   "ArrayBuffer",
+			source: `new Uint8Array([0x61, 0xc2, 0xa2]).buffer`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "Uint8Array",
+			source: `new Uint8Array([0x61, 0xc2, 0xa2])`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "Uint8Array viewing a portion of its buffer",
+			source: `new Uint8Array(new Uint8Array([0x78, 0x61, 0xc2, 0xa2, 0x78]).buffer, 1, 3)`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "Uint8Array subarray",
+			source: `new Uint8Array([0x78, 0x61, 0xc2, 0xa2, 0x78]).subarray(1, 4)`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "Int8Array",
+			source: `new Int8Array([0x61, -62, -94])`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "Uint8ClampedArray",
+			source: `new Uint8ClampedArray([0x61, 0xc2, 0xa2])`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "Uint8ClampedArray subarray",
+			source: `new Uint8ClampedArray([0x78, 0x61, 0xc2, 0xa2, 0x78]).subarray(1, 4)`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "Uint8ClampedArray holding clamped values",
+			label:  Windows1252EncodingFormat,
+			source: `new Uint8ClampedArray([-1, 0x61, 0x1e9])`,
+			want:   "\x00a\u00FF",
+		},
+		{
+			name:   "Uint16Array",
+			label:  UTF16LEEncodingFormat,
+			source: `new Uint16Array([0x0061, 0x00a2])`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "Uint32Array",
+			label:  UTF16LEEncodingFormat,
+			source: `new Uint32Array([0x00a20061])`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "Float64Array",
+			source: `new Float64Array(new Uint8Array([0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68]).buffer)`,
+			want:   "abcdefgh",
+		},
+		{
+			name:   "DataView",
+			source: `new DataView(new Uint8Array([0x61, 0xc2, 0xa2]).buffer)`,
+			want:   "a\u00A2",
+		},
+		{
+			name:   "DataView viewing a portion of its buffer",
+			source: `new DataView(new Uint8Array([0x78, 0x61, 0xc2, 0xa2, 0x78]).buffer, 1, 3)`,
+			want:   "a\u00A2",
+		},
+		{
+			name:      "plain array",
+			source:    `[0x61, 0x62]`,
+			wantError: true,
+		},
+		{
+			name:      "string",
+			source:    `"ab"`,
+			wantError: true,
+		},
+		{
+			name:      "null",
+			source:    `null`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := goja.New()
+			source, err := rt.RunString(tc.source)
+			require.NoError(t, err)
+
+			td, err := NewTextDecoder(rt, tc.label, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.DecodeValue(source, decodeOptions{})
+			if tc.wantError {
+				var encodingErr *Error
+				require.ErrorAs(t, err, &encodingErr)
+				assert.Equal(t, TypeError, encodingErr.Name)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+		})
+	}
+}
+
+func TestTextDecoderDecodeValueConsumed(t *testing.T) {
+	t.Parallel()
+
+	t.Run("streaming", func(t *testing.T) {
+		t.Parallel()
+
+		rt := goja.New()
+		source, err := rt.RunString(`new Uint8Array([0x61, 0xe6, 0xb0])`)
+		require.NoError(t, err)
+
+		td, err := NewTextDecoder(rt, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		decoded, consumed, err := td.DecodeValueConsumed(source, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, "a", decoded)
+		assert.Equal(t, 1, consumed, "the buffered tail should not count as consumed")
+	})
+
+	t.Run("binary string", func(t *testing.T) {
+		t.Parallel()
+
+		rt := goja.New()
+		td, err := NewTextDecoder(rt, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		decoded, consumed, err := td.DecodeValueConsumed(rt.ToValue("a\u00C2\u00A2"), decodeOptions{BinaryString: true})
+		require.NoError(t, err)
+		assert.Equal(t, "a\u00A2", decoded)
+		assert.Equal(t, 3, consumed)
+	})
+
+	t.Run("utf-16 code units", func(t *testing.T) {
+		t.Parallel()
+
+		rt := goja.New()
+		source, err := rt.RunString(`new Uint16Array([0x0061, 0x6c34])`)
+		require.NoError(t, err)
+
+		td, err := NewTextDecoder(rt, UTF16BEEncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		decoded, consumed, err := td.DecodeValueConsumed(source, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "a\u6C34", decoded, "code units should be read in the decoder's byte order")
+		assert.Equal(t, 4, consumed)
+	})
+}
+
+func TestTextDecoderSingleByteFlush(t *testing.T) {
+	t.Parallel()
+
+	// "a", followed by bytes which are the lead bytes
+	// of multi-byte sequences in other encodings.
+	data := []byte{0x61, 0xC3, 0xE6, 0xD8}
+
+	for _, label := range []string{
+		Windows1252EncodingFormat,
+		"iso-8859-2",
+		KOI8REncodingFormat,
+		IBM866EncodingFormat,
+		XUserDefinedEncodingFormat,
+	} {
+		label := label
+
+		t.Run(label, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, label, textDecoderOptions{Fatal: true})
+			require.NoError(t, err)
+
+			want, err := td.Decode(data, decodeOptions{})
+			require.NoError(t, err)
+
+			streamed, err := td.Decode(data, decodeOptions{Stream: true})
+			require.NoError(t, err)
+			assert.Equal(t, want, streamed, "each byte should be decoded right away")
+			assert.False(t, td.Pending(), "no bytes should be buffered")
+
+			flushed, err := td.Decode(nil, decodeOptions{ErrorOnTruncated: true})
+			require.NoError(t, err)
+			assert.Empty(t, flushed, "flushing should not emit a replacement character")
+			assert.Zero(t, td.Substitutions())
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder("windows-1252");
+			const streamed = decoder.decode(new Uint8Array([0x61, 0xc3]), { stream: true }) + decoder.decode(new Uint8Array([0xe6]), { stream: true });
+			assert_equals(streamed, "a\u00c3\u00e6");
+			assert_equals(decoder.decode(new Uint8Array([])), "", "flushing should not emit a replacement character");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeNUL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		label string
+		data  []byte
+	}{
+		{label: "utf-8", data: []byte{0x41, 0x00, 0x42}},
+		{label: "windows-1252", data: []byte{0x41, 0x00, 0x42}},
+		{label: "utf-16le", data: []byte{0x41, 0x00, 0x00, 0x00, 0x42, 0x00}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.label, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.label, textDecoderOptions{Fatal: true})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(tc.data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "A\u0000B", decoded, "NUL should be preserved rather than terminate the text")
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			for (const label of ["utf-8", "windows-1252"]) {
+				const decoded = new TextDecoder(label).decode(new Uint8Array([0x41, 0x00, 0x42]));
+				assert_equals(decoded.length, 3, label + " decoded length");
+				assert_equals(decoded.charCodeAt(1), 0, label + " decoded NUL");
+				assert_equals(decoded, "A\u0000B", label + " decoded text");
+			}
+
+			const decoder = new TextDecoder();
+			const streamed = decoder.decode(new Uint8Array([0x41, 0x00]), { stream: true }) + decoder.decode(new Uint8Array([0x00, 0x42]));
+			assert_equals(streamed, "A\u0000\u0000B", "NULs should be preserved across chunks");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeOutputCodePoints(t *testing.T) {
+	t.Parallel()
+
+	t.Run("astral code point", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoded = new TextDecoder().decode(new Uint8Array([0xf0, 0x9f, 0x98, 0x80]), { output: "codepoints" });
+			assert_true(Array.isArray(decoded), "decoded should be an array");
+			assert_equals(decoded.length, 1, "decoded should hold a single code point");
+			assert_equals(decoded[0], 0x1f600, "decoded code point");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("code units", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoded = new TextDecoder().decode(new Uint8Array([0xf0, 0x9f, 0x98, 0x80]), { output: "codeunits" });
+			assert_true(decoded instanceof Uint16Array, "decoded should be a Uint16Array");
+			assert_equals(decoded.length, 2, "decoded should hold a surrogate pair");
+			assert_equals(decoded[0], 0xd83d, "decoded high surrogate");
+			assert_equals(decoded[1], 0xde00, "decoded low surrogate");
+
+			const text = "a\u6c34\u{1d11e}";
+			const units = new TextDecoder().decode(new TextEncoder().encode(text), { output: "codeunits" });
+			assert_equals(String.fromCharCode(...units), text, "code units should match the JS string's");
+			assert_equals(new TextDecoder().decode(new Uint8Array([]), { output: "codeunits" }).length, 0, "empty input");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("string output by default", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const bytes = new Uint8Array([0xf0, 0x9f, 0x98, 0x80]);
+			assert_equals(new TextDecoder().decode(bytes), "\uD83D\uDE00", "default output");
+			assert_equals(new TextDecoder().decode(bytes, { output: "string" }), "\uD83D\uDE00", "string output");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupported output", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`new TextDecoder().decode(new Uint8Array([0x61]), { output: "bytes" })`)
+		assert.ErrorContains(t, err, TypeError)
+	})
+}
+
+func TestTextDecoderDecodeCaseFold(t *testing.T) {
+	t.Parallel()
+
+	// Turkish text holding a dotted capital I and a dotless small i, which
+	// the Unicode case mappings fold to ASCII letters, unlike ASCII folding.
+	text := "D\u0130yarbak\u0131r \u00C7I\u011EI"
+
+	testCases := []struct {
+		policy CaseFoldPolicy
+		want   string
+	}{
+		{policy: "", want: text},
+		{policy: CaseFoldNone, want: text},
+		{policy: CaseFoldLower, want: "diyarbak\u0131r \u00E7i\u011Fi"},
+		{policy: CaseFoldUpper, want: "D\u0130YARBAKIR \u00C7I\u011EI"},
+		{policy: CaseFoldASCIILower, want: "d\u0130yarbak\u0131r \u00C7i\u011Ei"},
+		{policy: CaseFoldASCIIUpper, want: "D\u0130YARBAK\u0131R \u00C7I\u011EI"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.policy, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode([]byte(text), decodeOptions{CaseFold: tc.policy})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+
+			// Folding each chunk of a stream equals folding the whole text
+			var streamed string
+			for i := 0; i < len(text); i++ {
+				piece, err := td.Decode([]byte{text[i]}, decodeOptions{Stream: true, CaseFold: tc.policy})
+				require.NoError(t, err)
+				streamed += piece
+			}
+			assert.Equal(t, tc.want, streamed)
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const data = new Uint8Array([0x48, 0x65, 0x4c, 0x6c, 0x4f, 0x20, 0xc9]);
+			const decoder = new TextDecoder("windows-1252");
+
+			assert_equals(decoder.decode(data), "HeLlO \u00c9", "default");
+			assert_equals(decoder.decode(data, { caseFold: "none" }), "HeLlO \u00c9");
+			assert_equals(decoder.decode(data, { caseFold: "lower" }), "hello \u00e9");
+			assert_equals(decoder.decode(data, { caseFold: "upper" }), "HELLO \u00c9");
+			assert_equals(decoder.decode(data, { caseFold: "ascii-lower" }), "hello \u00c9", "non-ASCII letters should be left as is");
+
+			let error;
+			try {
+				decoder.decode(data, { caseFold: "title" });
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "an unsupported case fold should throw a TypeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeControlBytes(t *testing.T) {
+	t.Parallel()
+
+	// "a", BEL, "b", tab, NEL, line feed and DEL
+	data := []byte{0x61, 0x07, 0x62, 0x09, 0xC2, 0x85, 0x0A, 0x7F}
+
+	testCases := []struct {
+		policy ControlBytesPolicy
+		want   string
+	}{
+		{policy: "", want: "a\u0007b\t\u0085\n\u007F"},
+		{policy: ControlBytesKeep, want: "a\u0007b\t\u0085\n\u007F"},
+		{policy: ControlBytesStrip, want: "ab\t\n"},
+		{policy: ControlBytesReplace, want: "a\uFFFDb\t\uFFFD\n\uFFFD"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.policy, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(data, decodeOptions{ControlBytes: tc.policy})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder();
+			const bell = new Uint8Array([0x61, 0x07, 0x62]);
+			assert_equals(decoder.decode(bell), "a\x07b");
+			assert_equals(decoder.decode(bell, { controlBytes: "keep" }), "a\x07b");
+			assert_equals(decoder.decode(bell, { controlBytes: "strip" }), "ab");
+			assert_equals(decoder.decode(bell, { controlBytes: "replace" }), "a\ufffdb");
+
+			let threw = false;
+			try {
+				decoder.decode(bell, { controlBytes: "escape" });
+			} catch (e) {
+				threw = true;
+			}
+			assert_true(threw, "an unknown policy should throw");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeUTF16Tail(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		label  string
+		chunks [][]byte
+		want   string
+	}{
+		{
+			name:   "utf-16le 1-byte tail",
+			label:  UTF16LEEncodingFormat,
+			chunks: [][]byte{{0x61, 0x00, 0x62}},
+			want:   "a\uFFFD",
+		},
+		{
+			name:   "utf-16le 3-byte tail",
+			label:  UTF16LEEncodingFormat,
+			chunks: [][]byte{{0x61, 0x00, 0x34, 0xD8, 0x00}},
+			want:   "a\uFFFD",
+		},
+		{
+			name:   "utf-16le streamed 1-byte tail",
+			label:  UTF16LEEncodingFormat,
+			chunks: [][]byte{{0x61, 0x00, 0x62}, {}},
+			want:   "a\uFFFD",
+		},
+		{
+			name:   "utf-16le streamed 3-byte tail",
+			label:  UTF16LEEncodingFormat,
+			chunks: [][]byte{{0x61, 0x00, 0x34}, {0xD8}, {0x00}},
+			want:   "a\uFFFD",
+		},
+		{
+			name:   "utf-16le unpaired surrogates followed by a 3-byte tail",
+			label:  UTF16LEEncodingFormat,
+			chunks: [][]byte{{0x34, 0xD8, 0x34, 0xD8, 0x00}},
+			want:   "\uFFFD\uFFFD",
+		},
+		{
+			name:   "utf-16be 1-byte tail",
+			label:  UTF16BEEncodingFormat,
+			chunks: [][]byte{{0x00, 0x61, 0x00}},
+			want:   "a\uFFFD",
+		},
+		{
+			name:   "utf-16be 3-byte tail",
+			label:  UTF16BEEncodingFormat,
+			chunks: [][]byte{{0x00, 0x61, 0xD8, 0x34, 0xDD}},
+			want:   "a\uFFFD",
+		},
+		{
+			name:   "utf-16be no tail",
+			label:  UTF16BEEncodingFormat,
+			chunks: [][]byte{{0x00, 0x61, 0xD8, 0x34}, {0xDD, 0x1E}},
+			want:   "a\U0001D11E",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(goja.New(), tc.label, textDecoderOptions{})
+			require.NoError(t, err)
+
+			var got string
+			for i, chunk := range tc.chunks {
+				decoded, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1})
+				require.NoError(t, err)
+
+				got += decoded
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("fatal mode", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			name   string
+			label  string
+			chunks [][]byte
+		}{
+			{
+				name:   "utf-16le 1-byte tail",
+				label:  UTF16LEEncodingFormat,
+				chunks: [][]byte{{0x61, 0x00, 0x62}},
+			},
+			{
+				name:   "utf-16le 3-byte tail",
+				label:  UTF16LEEncodingFormat,
+				chunks: [][]byte{{0x61, 0x00, 0x34, 0xD8, 0x00}},
+			},
+			{
+				name:   "utf-16le streamed 1-byte tail",
+				label:  UTF16LEEncodingFormat,
+				chunks: [][]byte{{0x61, 0x00, 0x62}, {}},
+			},
+			{
+				name:   "utf-16be streamed 3-byte tail",
+				label:  UTF16BEEncodingFormat,
+				chunks: [][]byte{{0x00, 0x61, 0xD8}, {0x34, 0xDD}},
+			},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				td, err := NewTextDecoder(goja.New(), tc.label, textDecoderOptions{Fatal: true})
+				require.NoError(t, err)
+
+				for _, chunk := range tc.chunks[:len(tc.chunks)-1] {
+					_, err := td.Decode(chunk, decodeOptions{Stream: true})
+					require.NoError(t, err)
+				}
+
+				_, err = td.Decode(tc.chunks[len(tc.chunks)-1], decodeOptions{})
+
+				var encodingErr *Error
+				require.ErrorAs(t, err, &encodingErr)
+				assert.Equal(t, TypeError, encodingErr.Name)
+
+				// The failed flush ends the stream nonetheless
+				assert.False(t, td.Pending())
+				decoded, err := td.Decode([]byte{0x61, 0x61}, decodeOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, "\u6161", decoded)
+			})
+		}
+	})
+}
+
+func TestTextDecoderDecodeUTF16AstralCharacters(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		label string
+		data  string
+	}{
+		{
+			name:  "utf-16le",
+			label: UTF16LEEncodingFormat,
+			data:  "0x34, 0xd8, 0x1e, 0xdd",
+		},
+		{
+			name:  "utf-16be",
+			label: UTF16BEEncodingFormat,
+			data:  "0xd8, 0x34, 0xdd, 0x1e",
+		},
+		{
+			name:  "utf-16le surrounded by bmp characters",
+			label: UTF16LEEncodingFormat,
+			data:  "0x61, 0x00, 0x34, 0xd8, 0x1e, 0xdd, 0x34, 0x6c",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := newTestSetup(t)
+
+			_, err := ts.rt.RunString(`
+				const bytes = new Uint8Array([` + tc.data + `]);
+				const decoded = new TextDecoder("` + tc.label + `").decode(bytes);
+
+				// U+1D11E is represented as the surrogate pair it is encoded as
+				const index = decoded.indexOf("\uD834\uDD1E");
+				assert_not_equals(index, -1, "decoded text should hold the surrogate pair");
+				assert_equals(decoded.length, bytes.length / 2, "decoded text length in code units");
+				assert_equals(decoded.codePointAt(index), 0x1d11e, "decoded code point");
+				assert_equals(Array.from(decoded).length, bytes.length / 2 - 1, "decoded text length in code points");
+
+				const encoded = new TextEncoder("` + tc.label + `").encode(decoded);
+				assert_equals(encoded.join(","), bytes.join(","), "re-encoded bytes");
+			`)
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("streamed one byte at a time", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const bytes = [0x34, 0xd8, 0x1e, 0xdd];
+			const decoder = new TextDecoder("utf-16le");
+
+			let decoded = "";
+			for (const b of bytes) {
+				decoded += decoder.decode(new Uint8Array([b]), { stream: true });
+			}
+			decoded += decoder.decode(new Uint8Array([]));
+
+			assert_equals(decoded.length, 2, "decoded text length in code units");
+			assert_equals(decoded.charCodeAt(0), 0xd834, "high surrogate");
+			assert_equals(decoded.charCodeAt(1), 0xdd1e, "low surrogate");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeFatal(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		encoding EncodingName
+		data     []byte
+		wantErr  bool
+	}{
+		{name: "utf-8 valid", encoding: UTF8EncodingFormat, data: []byte("a\u00E9\U0001F600")},
+		{name: "utf-8 replacement character", encoding: UTF8EncodingFormat, data: []byte("a\uFFFDb")},
+		{name: "utf-8 invalid byte", encoding: UTF8EncodingFormat, data: []byte{0x61, 0xFF, 0x62}, wantErr: true},
+		{name: "utf-8 overlong sequence", encoding: UTF8EncodingFormat, data: []byte{0xC0, 0xAF}, wantErr: true},
+		{name: "utf-8 truncated sequence", encoding: UTF8EncodingFormat, data: []byte{0x61, 0xE6, 0xB0}, wantErr: true},
+		{name: "utf-16le replacement character", encoding: UTF16LEEncodingFormat, data: []byte{0xFD, 0xFF}},
+		{name: "utf-16le lone surrogate", encoding: UTF16LEEncodingFormat, data: []byte{0x61, 0x00, 0x00, 0xD8, 0x62, 0x00}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{Fatal: true})
+			require.NoError(t, err)
+
+			_, err = td.Decode(tc.data, decodeOptions{})
+			if !tc.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			var encodingErr *Error
+			require.ErrorAs(t, err, &encodingErr)
+			assert.Equal(t, TypeError, encodingErr.Name)
+
+			// The replacement mode substitutes the malformed sequences instead
+			td, err = NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(tc.data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Contains(t, decoded, "\uFFFD")
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder("utf-8", { fatal: true });
+			assert_equals(decoder.decode(new Uint8Array([0x61, 0xef, 0xbf, 0xbd])), "a\ufffd", "a valid replacement character should decode");
+
+			let error;
+			try {
+				decoder.decode(new Uint8Array([0x61, 0xff]));
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "malformed input should throw a TypeError in fatal mode");
+
+			assert_equals(new TextDecoder().decode(new Uint8Array([0x61, 0xff])), "a\ufffd", "replacement mode should substitute");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderPending(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const decoder = new TextDecoder();
+		assert_false(decoder.pending, "pending should be false initially");
+
+		decoder.decode(new Uint8Array([0x61]), { stream: true });
+		assert_false(decoder.pending, "pending should be false after a complete sequence");
+
+		decoder.decode(new Uint8Array([0xe6, 0xb0]), { stream: true });
+		assert_true(decoder.pending, "pending should be true after a partial sequence");
+
+		decoder.decode(new Uint8Array([0xb4]), { stream: true });
+		assert_false(decoder.pending, "pending should be false once the sequence is completed");
+
+		decoder.decode(new Uint8Array([0xe6]), { stream: true });
+		assert_true(decoder.pending, "pending should be true after another partial sequence");
+
+		decoder.decode(new Uint8Array([]));
+		assert_false(decoder.pending, "pending should be false after a flush");
+
+		decoder.pending = true;
+		assert_false(decoder.pending, "pending should be read-only");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextDecoderDecodeReturnRemainder(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		encoding      EncodingName
+		chunks        [][]byte
+		want          []string
+		wantRemainder [][]byte
+	}{
+		{
+			name:          "utf-8 character straddling chunks",
+			encoding:      UTF8EncodingFormat,
+			chunks:        [][]byte{{0x61, 0xE6, 0xB0}, {0xB4, 0x62}},
+			want:          []string{"a", "\u6C34b"},
+			wantRemainder: [][]byte{{0xE6, 0xB0}, {}},
+		},
+		{
+			name:          "utf-8 character spread over three chunks",
+			encoding:      UTF8EncodingFormat,
+			chunks:        [][]byte{{0xF0}, {0x9F, 0x98}, {0x80}},
+			want:          []string{"", "", "\U0001F600"},
+			wantRemainder: [][]byte{{0xF0}, {0xF0, 0x9F, 0x98}, {}},
+		},
+		{
+			name:          "utf-16le code unit straddling chunks",
+			encoding:      UTF16LEEncodingFormat,
+			chunks:        [][]byte{{0x61, 0x00, 0x34}, {0x6C}},
+			want:          []string{"a", "\u6C34"},
+			wantRemainder: [][]byte{{0x34}, {}},
+		},
+		{
+			name:          "shift_jis lead byte ending a chunk",
+			encoding:      ShiftJISEncodingFormat,
+			chunks:        [][]byte{{0x61, 0x82}, {0xA0}},
+			want:          []string{"a", "\u3042"},
+			wantRemainder: [][]byte{{0x82}, {}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+			require.NoError(t, err)
+
+			var remainder []byte
+			for i, chunk := range tc.chunks {
+				// The caller prepends the remainder to the next chunk
+				chunk = append(remainder, chunk...)
+
+				decoded, err := td.Decode(chunk, decodeOptions{ReturnRemainder: true})
+				require.NoError(t, err)
+				assert.Equal(t, tc.want[i], decoded)
+
+				remainder = td.TakeRemainder()
+				assert.Equal(t, tc.wantRemainder[i], append([]byte{}, remainder...))
+				assert.False(t, td.Pending(), "the decoder should buffer nothing")
+			}
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = new TextDecoder();
+
+			const first = decoder.decode(new Uint8Array([0x61, 0xe6, 0xb0]), { returnRemainder: true });
+			assert_equals(first.value, "a", "first value");
+			assert_true(first.remainder instanceof Uint8Array, "remainder should be a Uint8Array");
+			assert_equals(Array.from(first.remainder).join(), "230,176", "first remainder");
+			assert_false(decoder.pending, "the decoder should buffer nothing");
+
+			const frame = new Uint8Array([0xb4, 0x62]);
+			const next = new Uint8Array(first.remainder.length + frame.length);
+			next.set(first.remainder);
+			next.set(frame, first.remainder.length);
+
+			const second = decoder.decode(next, { returnRemainder: true });
+			assert_equals(second.value, "\u6C34b", "second value");
+			assert_equals(second.remainder.length, 0, "second remainder");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderReset(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		encoding  EncodingName
+		options   textDecoderOptions
+		stream    []byte
+		data      []byte
+		wantFirst string
+		want      string
+	}{
+		{
+			name:      "utf-8 byte order mark stripped",
+			encoding:  UTF8EncodingFormat,
+			stream:    []byte{0x61, 0xE6, 0xB0},
+			data:      []byte{0xEF, 0xBB, 0xBF, 0x62},
+			wantFirst: "b",
+			want:      "b",
+		},
+		{
+			name:      "utf-8 byte order mark ignored",
+			encoding:  UTF8EncodingFormat,
+			options:   textDecoderOptions{IgnoreBOM: true},
+			stream:    []byte{0x61, 0xE6, 0xB0},
+			data:      []byte{0xEF, 0xBB, 0xBF, 0x62},
+			wantFirst: "\uFEFFb",
+			want:      "\uFEFFb",
+		},
+		{
+			name:      "utf-16le odd byte discarded",
+			encoding:  UTF16LEEncodingFormat,
+			stream:    []byte{0xFF, 0xFE, 0x61, 0x00, 0x62},
+			data:      []byte{0xFF, 0xFE, 0x63, 0x00},
+			wantFirst: "c",
+			want:      "c",
+		},
+		{
+			name:      "iso-2022-jp shift state discarded",
+			encoding:  ISO2022JPEncodingFormat,
+			stream:    []byte{0x1B, 0x24, 0x42, 0x24, 0x22},
+			data:      []byte{0x24, 0x22},
+			wantFirst: "$\"",
+			want:      "$\"",
+		},
+		{
+			name:      "utf-16 byte order mark switch discarded",
+			encoding:  UTF8EncodingFormat,
+			options:   textDecoderOptions{AutoReBOM: true},
+			stream:    []byte{0xFF, 0xFE, 0x61, 0x00},
+			data:      []byte{0x61, 0x62},
+			wantFirst: "ab",
+			want:      "ab",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// The first call on a new decoder
+			td, err := NewTextDecoder(nil, tc.encoding, tc.options)
+			require.NoError(t, err)
+
+			first, err := td.Decode(tc.data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFirst, first)
+
+			// The first call on a decoder reset in the middle of a stream
+			_, err = td.Decode(tc.stream, decodeOptions{Stream: true})
+			require.NoError(t, err)
+
+			td.Reset()
+			assert.False(t, td.Pending())
+
+			decoded, err := td.Decode(tc.data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, decoded)
+			assert.Equal(t, tc.encoding, td.Encoding)
+		})
+	}
+}
+
+func TestTextDecoderConcurrentDecode(t *testing.T) {
+	t.Parallel()
+
+	// Run with the race detector to surface unsynchronized
+	// accesses to the state of the shared decoder.
+	td, err := NewTextDecoder(nil, ShiftJISEncodingFormat, textDecoderOptions{})
+	require.NoError(t, err)
+
+	const (
+		workers    = 8
+		iterations = 100
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < iterations; j++ {
+				// Non-streaming calls are self-contained, and must
+				// thus decode the same regardless of interleaving.
+				decoded, err := td.Decode([]byte{0x61, 0x82, 0xA0}, decodeOptions{})
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				assert.Equal(t, "a\u3042", decoded)
+
+				_, consumed, err := td.DecodeConsumed([]byte{0x61, 0x82, 0xA0}, decodeOptions{})
+				assert.NoError(t, err)
+				assert.Equal(t, 3, consumed)
+
+				assert.False(t, td.Pending())
+				assert.Zero(t, td.Substitutions())
+				td.Clone()
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestTextDecoderDecodeNonStreamingCallMidStream(t *testing.T) {
+	t.Parallel()
+
+	t.Run("buffered bytes are flushed along with the input", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(goja.New(), UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		decoded, err := td.Decode([]byte{0x61, 0xE6}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, "a", decoded)
+
+		decoded, err = td.Decode([]byte{0xB0, 0xB4}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "\u6C34", decoded)
+		assert.False(t, td.Pending())
+	})
+
+	t.Run("buffered bytes do not carry over to the next stream", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(goja.New(), UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		decoded, err := td.Decode([]byte{0x61, 0xE6, 0xB0}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, "a", decoded)
+
+		decoded, err = td.Decode([]byte{}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "\uFFFD", decoded)
+
+		decoded, err = td.Decode([]byte{0xB4}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, "\uFFFD", decoded)
+	})
+
+	t.Run("shift state does not carry over to the next stream", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(goja.New(), ISO2022JPEncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		// ESC $ B switches to JIS X 0208, in which 0x24 0x22 encodes あ
+		decoded, err := td.Decode([]byte{0x1B, 0x24, 0x42}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, "", decoded)
+
+		decoded, err = td.Decode([]byte{0x24, 0x22}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "\u3042", decoded)
+
+		// The next stream starts over in ASCII
+		decoded, err = td.Decode([]byte{0x24, 0x22}, decodeOptions{Stream: true})
+		require.NoError(t, err)
+		assert.Equal(t, `$"`, decoded)
+	})
+
+	t.Run("byte order mark is stripped at the start of the next stream", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(goja.New(), UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			decoded, err := td.Decode([]byte{0xEF, 0xBB, 0xBF, 0x61}, decodeOptions{Stream: true})
+			require.NoError(t, err)
+			assert.Equal(t, "a", decoded)
+
+			decoded, err = td.Decode([]byte{0xEF, 0xBB, 0xBF}, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "\uFEFF", decoded)
+		}
+	})
+}
+
+func TestNewDecoder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("factory returns the same object as the constructor", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const constructed = new TextDecoder("latin1", { fatal: true, ignoreBOM: true });
+			const created = newDecoder("latin1", { fatal: true, ignoreBOM: true });
+
+			assert_equals(created.encoding, constructed.encoding, "encoding property");
+			assert_equals(created.fatal, constructed.fatal, "fatal property");
+			assert_equals(created.ignoreBOM, constructed.ignoreBOM, "ignoreBOM property");
+
+			const bytes = new Uint8Array([0x63, 0x61, 0x66, 0xe9]);
+			assert_equals(created.decode(bytes), constructed.decode(bytes), "decoded text");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("factory defaults to utf-8", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const decoder = newDecoder();
+			assert_equals(decoder.encoding, "utf-8");
+			assert_false(decoder.fatal, "fatal property should not be set");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("factory throws on an unknown label", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			let constructorError, factoryError;
+			try {
+				new TextDecoder("not-an-encoding");
+			} catch (e) {
+				constructorError = e;
+			}
+			try {
+				newDecoder("not-an-encoding");
+			} catch (e) {
+				factoryError = e;
+			}
+
+			assert_true(factoryError instanceof RangeError, "error should be a RangeError");
+			assert_equals(factoryError.message, constructorError.message, "error message");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderMalformedRegions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		encoding    EncodingName
+		chunks      [][]byte
+		wantDecoded string
+		wantRegions []MalformedRegion
+	}{
+		{
+			name:        "utf-8 malformed regions",
+			encoding:    UTF8EncodingFormat,
+			chunks:      [][]byte{{0x61, 0xF0, 0x80, 0x62, 0xE6, 0xB0, 0x63, 0xFF}},
+			wantDecoded: "a��b�c�",
+			wantRegions: []MalformedRegion{
+				{Offset: 1, ByteLength: 1},
+				{Offset: 2, ByteLength: 1},
+				{Offset: 4, ByteLength: 2},
+				{Offset: 7, ByteLength: 1},
+			},
+		},
+		{
+			name:        "utf-8 truncated sequence ending the input",
+			encoding:    UTF8EncodingFormat,
+			chunks:      [][]byte{{0x61, 0xE6, 0xB0}},
+			wantDecoded: "a�",
+			wantRegions: []MalformedRegion{{Offset: 1, ByteLength: 2}},
+		},
+		{
+			name:        "utf-8 offsets account for the stripped byte order mark",
+			encoding:    UTF8EncodingFormat,
+			chunks:      [][]byte{{0xEF, 0xBB, 0xBF, 0x61, 0x80}},
+			wantDecoded: "a�",
+			wantRegions: []MalformedRegion{{Offset: 4, ByteLength: 1}},
+		},
+		{
+			name:        "utf-8 replacement characters encoded as such are not reported",
+			encoding:    UTF8EncodingFormat,
+			chunks:      [][]byte{{0xEF, 0xBF, 0xBD, 0x80}},
+			wantDecoded: "��",
+			wantRegions: []MalformedRegion{{Offset: 3, ByteLength: 1}},
+		},
+		{
+			name:        "utf-8 sequence started in the previous chunk",
+			encoding:    UTF8EncodingFormat,
+			chunks:      [][]byte{{0x61, 0xE6}, {0x62}},
+			wantDecoded: "a�b",
+			wantRegions: []MalformedRegion{{Offset: -1, ByteLength: 1}},
+		},
+		{
+			name:        "utf-16le lone surrogate and truncated code unit",
+			encoding:    UTF16LEEncodingFormat,
+			chunks:      [][]byte{{0x41, 0x00, 0x00, 0xD8, 0x42, 0x00, 0x43}},
+			wantDecoded: "A�B�",
+			wantRegions: []MalformedRegion{
+				{Offset: 2, ByteLength: 2},
+				{Offset: 6, ByteLength: 1},
+			},
+		},
+		{
+			name:        "shift_jis invalid trail bytes",
+			encoding:    ShiftJISEncodingFormat,
+			chunks:      [][]byte{{0x61, 0x81, 0x20, 0x82, 0xA0, 0xA1, 0x81}},
+			wantDecoded: "a� あ｡�",
+			wantRegions: []MalformedRegion{
+				{Offset: 1, ByteLength: 1},
+				{Offset: 6, ByteLength: 1},
+			},
+		},
+		{
+			name:        "iso-2022-jp escape sequences",
+			encoding:    ISO2022JPEncodingFormat,
+			chunks:      [][]byte{{0x1B, 0x24, 0x42, 0x24, 0x22, 0x7F, 0x24, 0x24, 0x22}},
+			wantDecoded: "あ�あ",
+			wantRegions: []MalformedRegion{{Offset: 5, ByteLength: 2}},
+		},
+		{
+			name:        "replacement encoding",
+			encoding:    ReplacementEncodingFormat,
+			chunks:      [][]byte{{0x61, 0x62, 0x63}},
+			wantDecoded: "�",
+			wantRegions: []MalformedRegion{{Offset: 0, ByteLength: 3}},
+		},
+		{
+			name:        "well-formed input",
+			encoding:    UTF8EncodingFormat,
+			chunks:      [][]byte{{0x63, 0x61, 0x66, 0xC3, 0xA9}},
+			wantDecoded: "café",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, tc.encoding, textDecoderOptions{})
+			require.NoError(t, err)
+
+			var decoded string
+			for i, chunk := range tc.chunks {
+				got, err := td.Decode(chunk, decodeOptions{Stream: i < len(tc.chunks)-1, ReportErrors: true})
+				require.NoError(t, err)
+
+				decoded += got
+			}
+
+			assert.Equal(t, tc.wantDecoded, decoded)
+			assert.Equal(t, tc.wantRegions, td.MalformedRegions())
+			assert.Equal(t, len(tc.wantRegions), td.Substitutions())
+		})
+	}
+
+	t.Run("regions are not looked for unless requested", func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+
+		_, err = td.Decode([]byte{0x61, 0x80}, decodeOptions{})
+		require.NoError(t, err)
+		assert.Nil(t, td.MalformedRegions())
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const bytes = new Uint8Array([0x61, 0x80, 0x62, 0xe6, 0xb0, 0x63, 0xf0, 0x9f, 0x98]);
+			const result = new TextDecoder().decode(bytes, { reportErrors: true });
+			assert_equals(result.value, "a�b�c�");
+			assert_equals(result.errors.length, 3, "errors length");
+			assert_equals(result.errors.map((e) => e.offset).join(","), "1,3,6", "errors offsets");
+			assert_equals(result.errors.map((e) => e.byteLength).join(","), "1,2,3", "errors byte lengths");
+
+			const clean = new TextDecoder().decode(new Uint8Array([0x61]), { reportErrors: true, withConsumed: true });
+			assert_equals(clean.value, "a");
+			assert_equals(clean.errors.length, 0, "well-formed input should report no errors");
+			assert_equals(clean.consumed, 1);
+
+			assert_equals(new TextDecoder().decode(new Uint8Array([0x61])), "a", "reportErrors should default to false");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextDecoderDecodeDetachedBuffer(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		label  string
+		source string
+	}{
+		{
+			name:   "detached ArrayBuffer",
+			label:  "utf-8",
+			source: `buffer`,
+		},
+		{
+			name:   "Uint8Array viewing a detached ArrayBuffer",
+			label:  "utf-8",
+			source: `new Uint8Array(buffer, 1, 2)`,
+		},
+		{
+			name:   "DataView viewing a detached ArrayBuffer",
+			label:  "utf-8",
+			source: `new DataView(buffer)`,
+		},
+		{
+			name:   "Uint16Array of code units viewing a detached ArrayBuffer",
+			label:  "utf-16le",
+			source: `new Uint16Array(buffer)`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := newTestSetup(t)
+
+			// Views are created beforehand, as a detached buffer cannot be viewed
+			_, err := ts.rt.RunString(`
+				var buffer = new Uint8Array([0x61, 0x62, 0x63, 0x64]).buffer;
+				var source = ` + tc.source + `;
+			`)
+			require.NoError(t, err)
+
+			ab, ok := ts.rt.Get("buffer").Export().(goja.ArrayBuffer)
+			require.True(t, ok)
+			require.True(t, ab.Detach())
+
+			_, err = ts.rt.RunString(`
+				try {
+					new TextDecoder("` + tc.label + `").decode(source);
+					assert_true(false, "decoding a detached buffer should throw");
+				} catch (e) {
+					assert_true(e instanceof TypeError, "error should be a TypeError");
+					assert_equals(e.message, "cannot decode detached ArrayBuffer");
+				}
+			`)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestTextDecoderDecodeSingleByteFullRange(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	t.Run(Windows1251EncodingFormat, func(t *testing.T) {
+		t.Parallel()
+
+		td, err := NewTextDecoder(nil, Windows1251EncodingFormat, textDecoderOptions{})
+		require.NoError(t, err)
+		require.True(t, td.singleByte)
+
+		var want strings.Builder
+		for _, b := range data {
+			want.WriteRune(charmap.Windows1251.DecodeByte(b))
+		}
+
+		decoded, err := td.Decode(data, decodeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, want.String(), decoded, "no character should be truncated")
+	})
+
+	for _, label := range []string{
+		Windows1252EncodingFormat,
+		Windows1256EncodingFormat,
+		KOI8REncodingFormat,
+		IBM866EncodingFormat,
+		XUserDefinedEncodingFormat,
+	} {
+		label := label
+
+		t.Run(label, func(t *testing.T) {
+			t.Parallel()
+
+			td, err := NewTextDecoder(nil, label, textDecoderOptions{})
+			require.NoError(t, err)
+			require.True(t, td.singleByte)
+
+			decoded, err := td.Decode(data, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, len(data), utf8.RuneCountInString(decoded), "each byte should decode to a character")
+
+			var want string
+			for _, b := range data {
+				decoded, err := td.Decode([]byte{b}, decodeOptions{})
+				require.NoError(t, err)
+
+				want += decoded
+			}
+			assert.Equal(t, want, decoded)
+		})
+	}
+}
+
+func TestDecodeAsync(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{
+			name:   "promise of utf-8 bytes",
+			script: `decodeAsync(Promise.resolve(new Uint8Array([0x63, 0x61, 0x66, 0xc3, 0xa9])), "utf-8")`,
+			want:   "caf\u00E9",
+		},
+		{
+			name:   "promise of an ArrayBuffer",
+			script: `decodeAsync(Promise.resolve(new Uint8Array([0xff, 0xfe, 0x34, 0x6c]).buffer), "utf-16le")`,
+			want:   "\u6C34",
+		},
+		{
+			name:   "plain value",
+			script: `decodeAsync(new Uint8Array([0x61, 0x62]), "utf-8")`,
+			want:   "ab",
+		},
+		{
+			name: "promise resolved later",
+			script: `new Promise((resolve) => resolve()).then(() => {
+				return decodeAsync(new Promise((resolve) => resolve(new Uint8Array([0x61]))), "utf-8");
+			})`,
+			want: "a",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := newTestSetup(t)
+
+			err := ts.ev.Start(func() error {
+				_, err := ts.rt.RunString(tc.script + `.then((value) => { globalThis.result = value; })`)
+				return err
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, ts.rt.Get("result").String())
+		})
+	}
+
+	t.Run("rejections", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		err := ts.ev.Start(func() error {
+			_, err := ts.rt.RunString(`
+				globalThis.errors = {};
+
+				const record = (name) => (e) => { globalThis.errors[name] = e; };
+
+				decodeAsync(Promise.reject(new Error("boom")), "utf-8").catch(record("rejected"));
+				decodeAsync(Promise.resolve("not bytes"), "utf-8").catch(record("notBytes"));
+				decodeAsync(Promise.resolve(new Uint8Array([0x61])), "bogus-label").catch(record("label"));
+				decodeAsync(Promise.resolve(new Uint8Array([0x61])), "utf-16le", { fatal: true }).catch(record("fatal"));
+			`)
+			return err
+		})
+		require.NoError(t, err)
+
+		_, err = ts.rt.RunString(`
+			assert_equals(errors.rejected.message, "boom", "the rejection reason should be propagated");
+			assert_true(errors.notBytes instanceof TypeError, "a non-buffer value should reject with a TypeError");
+			assert_true(errors.label instanceof RangeError, "an unsupported label should reject with a RangeError");
+			assert_true(errors.fatal instanceof TypeError, "malformed data should reject with a TypeError in fatal mode");
+		`)
+		assert.NoError(t, err)
+	})
+}
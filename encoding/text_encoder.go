@@ -1,29 +1,82 @@
 package encoding
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/text/encoding"
-	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 )
 
 // TextEncoder represents an encoder that will generate a byte stream
-// with UTF-8 encoding.
+// with a specific encoding, UTF-8 by default.
 type TextEncoder struct {
-	// Encoding always holds the `utf-8` value.
+	// Encoding holds the name of the encoder which is a string describing
+	// the method the `TextEncoder` will use.
 	// FIXME: this should be TextEncoder.prototype.encoding instead
 	Encoding EncodingName
 
+	// Strict holds a boolean indicating whether encoding a value which
+	// is not a string, or a string holding lone surrogates, should fail,
+	// rather than coerce it to a string, and substitute them with
+	// replacement characters.
+	Strict bool
+
+	// Unmappable holds the policy applied when encoding characters
+	// the encoding cannot represent.
+	Unmappable UnmappablePolicy
+
+	// EscapeInvalid holds a boolean indicating whether the code points
+	// a TextDecoder escapes bytes of malformed sequences to are encoded
+	// as the bytes they escape.
+	EscapeInvalid bool
+
 	encoder encoding.Encoding
+
+	// shared holds the grow-only buffer EncodeShared
+	// writes the encoded bytes to.
+	shared []byte
 }
 
 // NewTextEncoder returns a new TextEncoder object instance that will
-// generate a byte stream with UTF-8 encoding.
-func NewTextEncoder() *TextEncoder {
-	return &TextEncoder{
-		encoder:  unicode.UTF8,
-		Encoding: UTF8EncodingFormat,
+// generate a byte stream with a specific encoding.
+func NewTextEncoder(label string, options textEncoderOptions) (*TextEncoder, error) {
+	// An empty label defaults to the utf-8 encoding
+	if strings.TrimSpace(label) == "" {
+		label = UTF8EncodingFormat
+	}
+
+	entry, ok := lookupEncoding(label)
+	if !ok {
+		return nil, NewError(RangeError, fmt.Sprintf("unsupported encoding: %s", label))
 	}
+
+	// Every encoding is supported for decoding, tell those
+	// expecting symmetric support why the label is rejected.
+	if !entry.encodable {
+		return nil, NewError(RangeError, fmt.Sprintf("%s is supported for decoding but not encoding", entry.name))
+	}
+
+	switch options.Unmappable {
+	case "":
+		options.Unmappable = UnmappableError
+	case UnmappableError, UnmappableHTML:
+	default:
+		return nil, NewError(TypeError, fmt.Sprintf("unsupported unmappable policy: %s", options.Unmappable))
+	}
+
+	return &TextEncoder{
+		Encoding:      entry.name,
+		Strict:        options.Strict,
+		Unmappable:    options.Unmappable,
+		EscapeInvalid: options.EscapeInvalid,
+
+		encoder: entry.newEncoding(),
+	}, nil
 }
 
 // Encode takes a string as input and returns an encoded byte stream.
@@ -32,11 +85,333 @@ func (te *TextEncoder) Encode(text string) ([]byte, error) {
 		return nil, errors.New("encoding not set")
 	}
 
-	enc := te.encoder.NewEncoder()
-	encoded, err := enc.Bytes([]byte(text))
+	if te.EscapeInvalid {
+		return te.encodeEscaped(text)
+	}
+
+	encoded, err := te.newEncoder().Bytes([]byte(text))
+	if err != nil {
+		return nil, NewError(TypeError, "unable to encode text; reason: "+err.Error())
+	}
+
+	return encoded, nil
+}
+
+// ByteLength returns the number of bytes the given text encodes to, using
+// the encoding the given label resolves to, without producing them.
+//
+// The UTF-8 length of the text is its length as a Go string, and is thus
+// computed without allocating. For other encodings, the text is encoded into
+// a fixed size buffer, which is overwritten as the encoding goes, and the
+// bytes written to it are counted.
+func ByteLength(text string, label string) (int, error) {
+	te, err := NewTextEncoder(label, textEncoderOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	if te.Encoding == UTF8EncodingFormat {
+		return len(text), nil
+	}
+
+	return te.encodeChunks(text, func([]byte) {})
+}
+
+// EncodeToHash writes the byte stream the given text encodes to into the
+// given hash, and returns the number of bytes written.
+//
+// The text is encoded into a fixed size buffer, which is written to the hash
+// and overwritten as the encoding goes, sparing the allocation of the whole
+// encoded byte stream. Only the text encoders escaping invalid sequences
+// encode the text at once, before writing it to the hash.
+func (te *TextEncoder) EncodeToHash(text string, h hash.Hash) (int, error) {
+	if te.encoder == nil {
+		return 0, errors.New("encoding not set")
+	}
+
+	if te.EscapeInvalid {
+		encoded, err := te.encodeEscaped(text)
+		if err != nil {
+			return 0, err
+		}
+
+		return h.Write(encoded)
+	}
+
+	return te.encodeChunks(text, func(chunk []byte) {
+		// Writing to a hash never returns an error
+		_, _ = h.Write(chunk)
+	})
+}
+
+// encodeChunks encodes the given text into a fixed size buffer, passing the
+// given function each chunk of the byte stream written to it, before it is
+// overwritten, and returns the number of bytes the text encodes to.
+func (te *TextEncoder) encodeChunks(text string, fn func(chunk []byte)) (int, error) {
+	var (
+		dst    [512]byte
+		src    = []byte(text)
+		length int
+		enc    = te.newEncoder()
+	)
+	for {
+		nDst, nSrc, err := enc.Transform(dst[:], src, true)
+		fn(dst[:nDst])
+		length += nDst
+		src = src[nSrc:]
+
+		switch {
+		case errors.Is(err, transform.ErrShortDst):
+			continue
+		case err != nil:
+			return 0, NewError(TypeError, "unable to encode text; reason: "+err.Error())
+		default:
+			return length, nil
+		}
+	}
+}
+
+// EncodeToBase64 takes a string as input, and returns the base64 encoding
+// of the byte stream it encodes to, using the given base64 variant.
+//
+// An empty variant defaults to the standard, padded, base64 encoding.
+func (te *TextEncoder) EncodeToBase64(text string, variant Base64Variant) (string, error) {
+	var b64 *base64.Encoding
+	switch variant {
+	case "", Base64Std:
+		b64 = base64.StdEncoding
+	case Base64RawStd:
+		b64 = base64.RawStdEncoding
+	case Base64URL:
+		b64 = base64.URLEncoding
+	case Base64RawURL:
+		b64 = base64.RawURLEncoding
+	default:
+		return "", NewError(TypeError, fmt.Sprintf("unsupported base64 variant: %s", variant))
+	}
+
+	encoded, err := te.Encode(text)
+	if err != nil {
+		return "", err
+	}
+
+	return b64.EncodeToString(encoded), nil
+}
+
+// EncodeAll takes chunks of text as input, and returns the byte stream
+// their concatenation encodes to.
+//
+// Converting a JS string to a Go string substitutes its lone surrogates with
+// replacement characters. Hence, as with TextEncoderStream.Write, the first
+// and last UTF-16 code units of each chunk must be given too, as its edges,
+// for surrogate pairs split across chunks to be reassembled.
+func (te *TextEncoder) EncodeAll(chunks []string, edges [][2]uint16) ([]byte, error) {
+	if len(edges) != len(chunks) {
+		return nil, fmt.Errorf("got the edges of %d chunks, for %d chunks", len(edges), len(chunks))
+	}
+
+	// Reassemble the text as UTF-8 first, with its surrogate pairs
+	stream := NewTextEncoderStream()
+
+	var text []byte
+	for i, chunk := range chunks {
+		text = append(text, stream.Write(chunk, edges[i][0], edges[i][1])...)
+	}
+	text = append(text, stream.Flush()...)
+
+	if te.Encoding == UTF8EncodingFormat && !te.EscapeInvalid {
+		return text, nil
+	}
+
+	return te.Encode(string(text))
+}
+
+// EncodeShared takes a string as input and returns an encoded byte stream,
+// written to a buffer owned by the text encoder, and reused across calls.
+//
+// The returned slice is only valid until the next call to EncodeShared, which
+// overwrites it, and must thus not be retained. When retaining the encoded
+// bytes is needed, Encode should be used instead.
+func (te *TextEncoder) EncodeShared(text string) ([]byte, error) {
+	if te.encoder == nil {
+		return nil, errors.New("encoding not set")
+	}
+
+	if te.EscapeInvalid {
+		encoded, err := te.encodeEscaped(text)
+		if err != nil {
+			return nil, err
+		}
+
+		te.shared = append(te.shared[:0], encoded...)
+
+		return te.shared, nil
+	}
+
+	encoded, _, err := transformBytes(te.newEncoder(), te.shared, []byte(text), true)
 	if err != nil {
 		return nil, NewError(TypeError, "unable to encode text; reason: "+err.Error())
 	}
 
+	te.shared = encoded
+
 	return encoded, nil
 }
+
+// EncodeInto takes a string as input, encodes it into the given destination
+// buffer, and returns the number of UTF-16 code units of the string read, and
+// the number of bytes written to the destination.
+//
+// Encoding stops as soon as the next character does not fit the space left
+// in the destination, as characters are never partially written. The shift
+// state of stateful encodings, such as iso-2022-jp, is carried from one
+// character to the next, the escape sequence switching back to the initial
+// state being only written at the end, provided it fits.
+func (te *TextEncoder) EncodeInto(text string, destination []byte) (read, written int, err error) {
+	if te.encoder == nil {
+		return 0, 0, errors.New("encoding not set")
+	}
+
+	// Each character is first encoded by the probe, and only fed to the
+	// encoder once it is known to fit, so that the encoder's shift state
+	// never advances past the characters written to the destination.
+	enc, probe := te.newEncoder(), te.newEncoder()
+
+	// Holds a single encoded character, the longest being the HTML
+	// numeric character reference of U+10FFFF, preceded by the escape
+	// sequence switching back to the initial shift state.
+	var encoded, discarded [16]byte
+	var source [utf8.UTFMax]byte
+
+	for _, r := range text {
+		// Every character is encoded as at least one byte
+		if written == len(destination) {
+			break
+		}
+
+		src := source[:utf8.EncodeRune(source[:], r)]
+		atEOF := false
+
+		b, escaped := unescapeByte(r)
+		escaped = escaped && te.EscapeInvalid
+		if escaped {
+			// As with Encode, escaped bytes are written as is,
+			// once switched back to the initial shift state.
+			src, atEOF = nil, true
+		}
+
+		size := len(src)
+		transformed := te.Encoding != UTF8EncodingFormat || escaped
+		if transformed {
+			size, _, err = probe.Transform(encoded[:], src, atEOF)
+			if err != nil {
+				return read, written, NewError(TypeError, "unable to encode text; reason: "+err.Error())
+			}
+		} else {
+			copy(encoded[:], src)
+		}
+
+		if escaped {
+			encoded[size] = b
+			size++
+		}
+
+		if written+size > len(destination) {
+			break
+		}
+
+		// Bring the encoder on par with the probe, now that the character fits
+		if transformed {
+			if _, _, err = enc.Transform(discarded[:], src, atEOF); err != nil {
+				return read, written, NewError(TypeError, "unable to encode text; reason: "+err.Error())
+			}
+		}
+
+		written += copy(destination[written:], encoded[:size])
+
+		// Code points outside the BMP are encoded as a surrogate pair
+		read++
+		if r > 0xFFFF {
+			read++
+		}
+	}
+
+	// Switch back to the initial shift state, should the space left allow it
+	size, _, err := enc.Transform(encoded[:], nil, true)
+	if err == nil && written+size <= len(destination) {
+		written += copy(destination[written:], encoded[:size])
+	}
+
+	return read, written, nil
+}
+
+// newEncoder returns a new encoder of the text encoder's encoding,
+// applying the text encoder's unmappable characters policy.
+func (te *TextEncoder) newEncoder() *encoding.Encoder {
+	enc := te.encoder.NewEncoder()
+	if te.Unmappable == UnmappableHTML {
+		enc = encoding.HTMLEscapeUnsupported(enc)
+	}
+
+	return enc
+}
+
+// UnmappablePolicy is a type alias for the policy applied when encoding
+// characters the encoding cannot represent.
+type UnmappablePolicy = string
+
+const (
+	// UnmappableError makes encoding fail with a TypeError when
+	// a character cannot be represented in the encoding.
+	UnmappableError UnmappablePolicy = "error"
+
+	// UnmappableHTML substitutes characters that cannot be represented in
+	// the encoding with their HTML numeric character reference, e.g. `&#8364;`.
+	UnmappableHTML UnmappablePolicy = "html"
+)
+
+// Base64Variant is a type alias for the variant of the base64 encoding,
+// named after the ones of the k6/encoding module.
+type Base64Variant = string
+
+const (
+	// Base64Std is the standard base64 encoding, as defined in RFC 4648.
+	Base64Std Base64Variant = "std"
+
+	// Base64RawStd is the standard base64 encoding, without padding.
+	Base64RawStd Base64Variant = "rawstd"
+
+	// Base64URL is the URL and filename safe base64 encoding, as defined
+	// in RFC 4648, substituting '-' and '_' for '+' and '/'.
+	Base64URL Base64Variant = "url"
+
+	// Base64RawURL is the URL and filename safe base64 encoding, without padding.
+	Base64RawURL Base64Variant = "rawurl"
+)
+
+type textEncoderOptions struct {
+	// Strict holds a boolean value indicating if the
+	// `TextEncoder.encode()` method must throw a `TypeError`
+	// when encoding a value which is not a string, or a string
+	// holding lone surrogates.
+	//
+	// It defaults to `false`, which means that, as per the
+	// specification, the encoder will coerce values to strings,
+	// and substitute lone surrogates with a replacement character.
+	Strict bool `js:"strict"`
+
+	// Unmappable holds the policy applied when encoding characters
+	// the encoding cannot represent, either "error" or "html".
+	//
+	// It defaults to "error", which means that the `TextEncoder.encode()`
+	// method will throw a `TypeError`.
+	Unmappable UnmappablePolicy `js:"unmappable"`
+
+	// EscapeInvalid holds a boolean value indicating whether the code
+	// points U+F700 to U+F7FF, which a `TextDecoder` decoding with the
+	// escapeInvalid option escapes the bytes of malformed sequences to,
+	// are encoded as the bytes they escape.
+	//
+	// Note that these code points are then never encoded as themselves.
+	EscapeInvalid bool `js:"escapeInvalid"`
+}
@@ -0,0 +1,87 @@
+package encoding
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// TextEncoderStream encodes a stream of text, written chunk by chunk, to UTF-8.
+//
+// As k6 does not implement the Streams API, it does not expose readable and
+// writable sides as its specification counterpart does: chunks are written to
+// it, and the bytes they encode to are returned right away.
+//
+// Surrogate pairs can be split across chunks: a high surrogate a chunk ends with
+// is held back until the next chunk is written, or the stream is flushed.
+type TextEncoderStream struct {
+	// Encoding holds the name of the encoding the stream encodes
+	// text with, which is always utf-8.
+	Encoding EncodingName
+
+	// pendingHighSurrogate holds the high surrogate the last
+	// written chunk ended with, if any, or zero otherwise.
+	pendingHighSurrogate uint16
+}
+
+// NewTextEncoderStream returns a new TextEncoderStream instance.
+func NewTextEncoderStream() *TextEncoderStream {
+	return &TextEncoderStream{Encoding: UTF8EncodingFormat}
+}
+
+// Write encodes the given chunk of text, and returns the encoded bytes.
+//
+// Converting a JS string to a Go string substitutes its lone surrogates with
+// replacement characters. Hence, the first and last UTF-16 code units of the
+// chunk must be given too, for surrogate pairs split across chunks to be
+// reassembled. Empty chunks have zero as their first and last code units.
+func (s *TextEncoderStream) Write(text string, first, last uint16) []byte {
+	// Empty chunks leave a pending high surrogate pending
+	if text == "" {
+		return []byte{}
+	}
+
+	var encoded []byte
+
+	if s.pendingHighSurrogate != 0 {
+		if isLowSurrogate(first) {
+			// The replacement character substituted for the
+			// low surrogate is superseded by the paired code point.
+			r := utf16.DecodeRune(rune(s.pendingHighSurrogate), rune(first))
+			encoded = utf8.AppendRune(encoded, r)
+			text = text[utf8.RuneLen(utf8.RuneError):]
+		} else {
+			encoded = utf8.AppendRune(encoded, utf8.RuneError)
+		}
+
+		s.pendingHighSurrogate = 0
+	}
+
+	if isHighSurrogate(last) && text != "" {
+		text = text[:len(text)-utf8.RuneLen(utf8.RuneError)]
+		s.pendingHighSurrogate = last
+	}
+
+	return append(encoded, text...)
+}
+
+// Flush ends the stream, and returns the bytes left to encode, that is, the
+// replacement character standing for a pending high surrogate, if any.
+func (s *TextEncoderStream) Flush() []byte {
+	if s.pendingHighSurrogate == 0 {
+		return []byte{}
+	}
+
+	s.pendingHighSurrogate = 0
+
+	return utf8.AppendRune(nil, utf8.RuneError)
+}
+
+// isHighSurrogate returns true if the given UTF-16 code unit is a high surrogate.
+func isHighSurrogate(unit uint16) bool {
+	return unit >= 0xD800 && unit < 0xDC00
+}
+
+// isLowSurrogate returns true if the given UTF-16 code unit is a low surrogate.
+func isLowSurrogate(unit uint16) bool {
+	return unit >= 0xDC00 && unit < 0xE000
+}
@@ -0,0 +1,77 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextEncoderStream(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		function concat(...arrays) {
+			return arrays.reduce((acc, a) => acc.concat(Array.from(a)), []);
+		}
+
+		function assert_bytes(actual, expected, description) {
+			assert_equals(JSON.stringify(actual), JSON.stringify(expected), description);
+		}
+
+		let stream = new TextEncoderStream();
+		assert_equals(stream.encoding, "utf-8");
+
+		// A trailing high surrogate is only resolved on flush
+		let written = stream.write("a\uD83D");
+		assert_true(written instanceof Uint8Array, "write should return a Uint8Array");
+		assert_bytes(Array.from(written), [0x61], "the trailing high surrogate should be held back");
+		assert_bytes(Array.from(stream.flush()), [0xef, 0xbf, 0xbd], "flush should emit U+FFFD");
+		assert_bytes(Array.from(stream.flush()), [], "a second flush should emit nothing");
+
+		// A surrogate pair split across chunks is reassembled
+		stream = new TextEncoderStream();
+		assert_bytes(
+			concat(stream.write("\uD83D"), stream.write("\uDE00"), stream.flush()),
+			[0xf0, 0x9f, 0x98, 0x80],
+			"split surrogate pair should encode to U+1F600"
+		);
+
+		// A high surrogate followed by anything but a low surrogate is substituted
+		stream = new TextEncoderStream();
+		assert_bytes(
+			concat(stream.write("\uD83D"), stream.write("b"), stream.flush()),
+			[0xef, 0xbf, 0xbd, 0x62],
+			"unpaired high surrogate should encode to U+FFFD"
+		);
+
+		// Empty chunks leave a pending high surrogate pending
+		stream = new TextEncoderStream();
+		assert_bytes(
+			concat(stream.write("x\uD83D"), stream.write(""), stream.write("\uDE00y"), stream.flush()),
+			[0x78, 0xf0, 0x9f, 0x98, 0x80, 0x79],
+			"empty chunk should not resolve the pending high surrogate"
+		);
+
+		// Consecutive high surrogates, and lone low surrogates
+		stream = new TextEncoderStream();
+		assert_bytes(
+			concat(stream.write("\uD83D"), stream.write("\uD83D"), stream.write("\uDE00\uDE00"), stream.flush()),
+			[0xef, 0xbf, 0xbd, 0xf0, 0x9f, 0x98, 0x80, 0xef, 0xbf, 0xbd],
+			"only the last high surrogate should pair"
+		);
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextEncoderStreamWrite(t *testing.T) {
+	t.Parallel()
+
+	s := NewTextEncoderStream()
+
+	// "a" followed by the lone high surrogate of U+1F600, substituted when converted to a Go string
+	assert.Equal(t, []byte("a"), s.Write("a\uFFFD", 'a', 0xD83D))
+	assert.Equal(t, []byte("\U0001F600b"), s.Write("\uFFFDb", 0xDE00, 'b'))
+	assert.Equal(t, []byte{}, s.Flush())
+}
@@ -0,0 +1,1219 @@
+package encoding
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextEncoderStrict(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lone surrogate throws in strict mode", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`new TextEncoder("utf-8", { strict: true }).encode("a\uD800b")`)
+		assert.ErrorContains(t, err, TypeError)
+	})
+
+	t.Run("lone trailing surrogate throws in strict mode", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`new TextEncoder("utf-8", { strict: true }).encode("\uDC00")`)
+		assert.ErrorContains(t, err, TypeError)
+	})
+
+	t.Run("well-formed strings encode in strict mode", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoder = new TextEncoder("utf-8", { strict: true });
+			assert_true(encoder.strict, "strict property should be set");
+
+			const encoded = encoder.encode("\uFFFD\uD834\uDD1E");
+			assert_equals(encoded.length, 7, "encoded length");
+			assert_equals(encoded[0], 0xef, "replacement character first byte");
+			assert_equals(encoded[3], 0xf0, "surrogate pair first byte");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("lone surrogate is substituted by default", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoder = new TextEncoder();
+			assert_false(encoder.strict, "strict property should not be set");
+
+			const encoded = encoder.encode("a\uD800b");
+			assert_equals(encoded.length, 5, "encoded length");
+			assert_equals(encoded[1], 0xef, "replacement character first byte");
+			assert_equals(encoded[2], 0xbf, "replacement character second byte");
+			assert_equals(encoded[3], 0xbd, "replacement character third byte");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("non-string inputs throw in strict mode", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoder = new TextEncoder("utf-8", { strict: true });
+
+			for (const input of [123, {}]) {
+				for (const encode of [
+					() => encoder.encode(input),
+					() => encoder.encodeToBase64(input),
+					() => encoder.encodeInto(input, new Uint8Array(16)),
+				]) {
+					let error;
+					try {
+						encode();
+					} catch (e) {
+						error = e;
+					}
+					assert_true(error instanceof TypeError, "a non-string input should throw a TypeError");
+				}
+			}
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("non-string inputs are coerced to strings by default", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoder = new TextEncoder();
+			const decoder = new TextDecoder();
+
+			assert_equals(decoder.decode(encoder.encode(123)), "123", "a number should be coerced");
+			assert_equals(decoder.decode(encoder.encode({})), "[object Object]", "an object should be coerced");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextEncoderRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		label string
+		text  string
+		want  []byte
+	}{
+		{
+			name:  "windows-1257",
+			label: "windows-1257",
+			text:  "ā",
+			want:  []byte{0xE2},
+		},
+		{
+			name:  "windows-1250",
+			label: "cp1250",
+			text:  "ő",
+			want:  []byte{0xF5},
+		},
+		{
+			name:  "windows-1251",
+			label: "x-cp1251",
+			text:  "\u0416\u0451",
+			want:  []byte{0xC6, 0xB8},
+		},
+		{
+			name:  "windows-1253",
+			label: "windows-1253",
+			text:  "\u03B1\u03A9",
+			want:  []byte{0xE1, 0xD9},
+		},
+		{
+			name:  "windows-1254",
+			label: "latin5",
+			text:  "\u0130\u0131iI",
+			want:  []byte{0xDD, 0xFD, 0x69, 0x49},
+		},
+		{
+			name:  "windows-1255",
+			label: "windows-1255",
+			text:  "\u05D0\u05B8",
+			want:  []byte{0xE0, 0xC8},
+		},
+		{
+			name:  "windows-1256",
+			label: "cp1256",
+			text:  "\u0627\u0644",
+			want:  []byte{0xC7, 0xE1},
+		},
+		{
+			name:  "koi8-r",
+			label: "koi8",
+			text:  "\u0430",
+			want:  []byte{0xC1},
+		},
+		{
+			name:  "koi8-u",
+			label: "koi8-u",
+			text:  "\u0430\u0457",
+			want:  []byte{0xC1, 0xA7},
+		},
+		{
+			name:  "iso-8859-2",
+			label: "latin2",
+			text:  "\u017E\u0159",
+			want:  []byte{0xBE, 0xF8},
+		},
+		{
+			name:  "iso-8859-15",
+			label: "l9",
+			text:  "\u20AC\u0153",
+			want:  []byte{0xA4, 0xBD},
+		},
+		{
+			name:  "utf-16be",
+			label: "utf-16be",
+			text:  "a\U0001D11E",
+			want:  []byte{0x00, 0x61, 0xD8, 0x34, 0xDD, 0x1E},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			te, err := NewTextEncoder(tc.label, textEncoderOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.name, te.Encoding)
+
+			encoded, err := te.Encode(tc.text)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, encoded)
+
+			td, err := NewTextDecoder(goja.New(), tc.label, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(encoded, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.text, decoded)
+		})
+	}
+}
+
+func TestTextEncoderUTF16(t *testing.T) {
+	t.Parallel()
+
+	for _, label := range []string{"utf-16", "unicode", "ucs-2", "utf-16le"} {
+		label := label
+
+		t.Run(label, func(t *testing.T) {
+			t.Parallel()
+
+			te, err := NewTextEncoder(label, textEncoderOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, UTF16LEEncodingFormat, te.Encoding)
+
+			encoded, err := te.Encode("A")
+			require.NoError(t, err)
+			assert.Equal(t, []byte{0x41, 0x00}, encoded)
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoder = new TextEncoder("utf-16");
+			assert_equals(encoder.encoding, "utf-16le");
+
+			const encoded = encoder.encode("A\u6C34");
+			assert_equals(encoded.join(","), "65,0,52,108", "encoded bytes");
+			assert_equals(new TextDecoder("utf-16").decode(encoded), "A\u6C34");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextEncoderISO2022JP(t *testing.T) {
+	t.Parallel()
+
+	var (
+		escapeToASCII   = []byte{0x1B, 0x28, 0x42}
+		escapeToJIS0208 = []byte{0x1B, 0x24, 0x42}
+	)
+
+	testCases := []struct {
+		name string
+		text string
+		want []byte
+	}{
+		{
+			name: "returns to ascii at the end of the output",
+			text: "\u3042A",
+			want: concatBytes(escapeToJIS0208, []byte{0x24, 0x22}, escapeToASCII, []byte("A")),
+		},
+		{
+			name: "returns to ascii after a trailing jis x 0208 character",
+			text: "A\u3042",
+			want: concatBytes([]byte("A"), escapeToJIS0208, []byte{0x24, 0x22}, escapeToASCII),
+		},
+		{
+			name: "pure ascii emits no escape sequence",
+			text: "hello, world",
+			want: []byte("hello, world"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			te, err := NewTextEncoder("iso-2022-jp", textEncoderOptions{})
+			require.NoError(t, err)
+
+			encoded, err := te.Encode(tc.text)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, encoded)
+
+			// Successive calls each produce a self-contained output
+			encoded, err = te.Encode(tc.text)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, encoded)
+
+			td, err := NewTextDecoder(nil, "iso-2022-jp", textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(encoded, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.text, decoded)
+		})
+	}
+
+	t.Run("encodeInto returns to ascii", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder("iso-2022-jp", textEncoderOptions{})
+		require.NoError(t, err)
+
+		destination := make([]byte, 16)
+		read, written, err := te.EncodeInto("\u3042A", destination)
+		require.NoError(t, err)
+		assert.Equal(t, 2, read)
+		assert.Equal(t, concatBytes(escapeToJIS0208, []byte{0x24, 0x22}, escapeToASCII, []byte("A")), destination[:written])
+	})
+}
+
+// concatBytes returns the concatenation of the given byte slices.
+func concatBytes(slices ...[]byte) []byte {
+	var b []byte
+	for _, s := range slices {
+		b = append(b, s...)
+	}
+
+	return b
+}
+
+func TestTextEncoderUnmappable(t *testing.T) {
+	t.Parallel()
+
+	t.Run("error policy", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder("windows-1257", textEncoderOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, UnmappableError, te.Unmappable)
+
+		_, err = te.Encode("aő")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+	})
+
+	t.Run("html policy", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder("windows-1257", textEncoderOptions{Unmappable: UnmappableHTML})
+		require.NoError(t, err)
+
+		encoded, err := te.Encode("aő")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a&#337;"), encoded)
+	})
+
+	t.Run("greek character encoded to windows-1254", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder("windows-1254", textEncoderOptions{Unmappable: UnmappableHTML})
+		require.NoError(t, err)
+
+		encoded, err := te.Encode("\u0131\u03B1")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("\xFD&#945;"), encoded)
+	})
+
+	t.Run("arabic presentation form encoded to windows-1256", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder("windows-1256", textEncoderOptions{})
+		require.NoError(t, err)
+
+		_, err = te.Encode("\uFEFB")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+
+		te, err = NewTextEncoder("windows-1256", textEncoderOptions{Unmappable: UnmappableHTML})
+		require.NoError(t, err)
+
+		encoded, err := te.Encode("\u0627\uFEFB")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("\xC7&#65275;"), encoded)
+	})
+
+	t.Run("koi8-u only character encoded to koi8-r", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder("koi8-r", textEncoderOptions{})
+		require.NoError(t, err)
+
+		_, err = te.Encode("\u0457")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+
+		te, err = NewTextEncoder("koi8-r", textEncoderOptions{Unmappable: UnmappableHTML})
+		require.NoError(t, err)
+
+		encoded, err := te.Encode("\u0430\u0457")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("\xC1&#1111;"), encoded)
+	})
+
+	t.Run("euro sign encoded to iso-8859-2", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder("iso-8859-2", textEncoderOptions{})
+		require.NoError(t, err)
+
+		_, err = te.Encode("\u20AC")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+
+		te, err = NewTextEncoder("iso-8859-2", textEncoderOptions{Unmappable: UnmappableHTML})
+		require.NoError(t, err)
+
+		encoded, err := te.Encode("\u017E\u20AC")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("\xBE&#8364;"), encoded)
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewTextEncoder("windows-1257", textEncoderOptions{Unmappable: "ignore"})
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+	})
+
+	t.Run("from JS", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoder = new TextEncoder("windows-1250", { unmappable: "html" });
+			assert_equals(encoder.encoding, "windows-1250", "encoding");
+
+			const encoded = encoder.encode("őā");
+			const decoded = new TextDecoder("windows-1250").decode(encoded);
+			assert_equals(decoded, "ő&#257;", "unmappable character should be escaped");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextEncoderUnsupportedEncoding(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		label       string
+		wantMessage string
+	}{
+		{label: "shift_jis", wantMessage: "shift_jis is supported for decoding but not encoding"},
+		{label: "sjis", wantMessage: "shift_jis is supported for decoding but not encoding"},
+		{label: "big5", wantMessage: "big5 is supported for decoding but not encoding"},
+		{label: "csiso2022kr", wantMessage: "replacement is supported for decoding but not encoding"},
+		{label: "bogus-label", wantMessage: "unsupported encoding: bogus-label"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.label, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewTextEncoder(tc.label, textEncoderOptions{})
+
+			var encodingErr *Error
+			require.ErrorAs(t, err, &encodingErr)
+			assert.Equal(t, RangeError, encodingErr.Name)
+			assert.Equal(t, tc.wantMessage, encodingErr.Message)
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			assert_equals(new TextDecoder("big5").encoding, "big5", "big5 should be supported for decoding");
+
+			let error;
+			try {
+				new TextEncoder("big5");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof RangeError, "error should be a RangeError");
+			assert_equals(error.message, "big5 is supported for decoding but not encoding");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextEncoderEncodeInto(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		label       string
+		options     textEncoderOptions
+		text        string
+		size        int
+		wantRead    int
+		wantWritten int
+	}{
+		{
+			name:        "utf-8 destination fitting the whole text",
+			label:       UTF8EncodingFormat,
+			text:        "a¢水",
+			size:        8,
+			wantRead:    3,
+			wantWritten: 6,
+		},
+		{
+			name:        "utf-8 destination one byte short of a character",
+			label:       UTF8EncodingFormat,
+			text:        "a¢水",
+			size:        5,
+			wantRead:    2,
+			wantWritten: 3,
+		},
+		{
+			name:        "utf-8 surrogate pair read as two code units",
+			label:       UTF8EncodingFormat,
+			text:        "a\U0001D11E",
+			size:        5,
+			wantRead:    3,
+			wantWritten: 5,
+		},
+		{
+			name:        "windows-1252 destination one byte short of the text",
+			label:       Windows1252EncodingFormat,
+			text:        "café",
+			size:        3,
+			wantRead:    3,
+			wantWritten: 3,
+		},
+		{
+			name:        "windows-1252 destination one byte short of a character reference",
+			label:       Windows1252EncodingFormat,
+			options:     textEncoderOptions{Unmappable: UnmappableHTML},
+			text:        "aő",
+			size:        6,
+			wantRead:    1,
+			wantWritten: 1,
+		},
+		{
+			name:        "windows-1252 destination fitting a character reference",
+			label:       Windows1252EncodingFormat,
+			options:     textEncoderOptions{Unmappable: UnmappableHTML},
+			text:        "aő",
+			size:        7,
+			wantRead:    2,
+			wantWritten: 7,
+		},
+		{
+			name:        "iso-2022-jp destination fitting the whole text",
+			label:       ISO2022JPEncodingFormat,
+			text:        "\u65E5\u672Ca",
+			size:        16,
+			wantRead:    3,
+			wantWritten: 11,
+		},
+		{
+			name:        "iso-2022-jp destination one byte short of the text",
+			label:       ISO2022JPEncodingFormat,
+			text:        "\u65E5\u672Ca",
+			size:        10,
+			wantRead:    2,
+			wantWritten: 10,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			te, err := NewTextEncoder(tc.label, tc.options)
+			require.NoError(t, err)
+
+			destination := make([]byte, tc.size)
+			read, written, err := te.EncodeInto(tc.text, destination)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantRead, read)
+			assert.Equal(t, tc.wantWritten, written)
+
+			// What is written must match the encoding of what is read
+			encoded, err := te.Encode(string(utf16.Decode(utf16.Encode([]rune(tc.text))[:read])))
+			require.NoError(t, err)
+			assert.Equal(t, encoded, destination[:written])
+		})
+	}
+
+	t.Run("stateful encoding written as encode writes it", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder(ISO2022JPEncodingFormat, textEncoderOptions{})
+		require.NoError(t, err)
+
+		destination := make([]byte, 32)
+		_, written, err := te.EncodeInto("\u65E5\u672Ca", destination)
+		require.NoError(t, err)
+
+		encoded, err := te.Encode("\u65E5\u672Ca")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("\x1b$BF|K\\\x1b(Ba"), encoded)
+		assert.Equal(t, encoded, destination[:written])
+	})
+
+	t.Run("unmappable character with the error policy", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder(Windows1252EncodingFormat, textEncoderOptions{})
+		require.NoError(t, err)
+
+		_, _, err = te.EncodeInto("aő", make([]byte, 8))
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+	})
+
+	t.Run("from JS", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const buffer = new Uint8Array(6);
+			const destination = buffer.subarray(1, 5);
+
+			const result = new TextEncoder("windows-1252").encodeInto("café!", destination);
+			assert_equals(result.read, 4, "read");
+			assert_equals(result.written, 4, "written");
+			assert_equals(buffer[0], 0, "bytes before the destination should be left untouched");
+			assert_equals(buffer[4], 0xe9, "encoded character");
+			assert_equals(buffer[5], 0, "bytes after the destination should be left untouched");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("from JS with a destination other than a Uint8Array", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`new TextEncoder().encodeInto("a", new Uint16Array(2))`)
+		assert.ErrorContains(t, err, TypeError)
+	})
+
+	t.Run("from JS with a detached destination", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`var destination = new Uint8Array(4);`)
+		require.NoError(t, err)
+
+		ab, ok := ts.rt.Get("destination").ToObject(ts.rt).Get("buffer").Export().(goja.ArrayBuffer)
+		require.True(t, ok)
+		require.True(t, ab.Detach())
+
+		_, err = ts.rt.RunString(`new TextEncoder().encodeInto("a", destination)`)
+		assert.ErrorContains(t, err, "cannot encode into detached ArrayBuffer")
+	})
+}
+
+func TestNewEncoder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("factory returns the same object as the constructor", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const constructed = new TextEncoder("windows-1252", { strict: true });
+			const created = newEncoder("windows-1252", { strict: true });
+
+			assert_equals(created.encoding, constructed.encoding, "encoding property");
+			assert_equals(created.strict, constructed.strict, "strict property");
+
+			const encoded = created.encode("caf\u00e9");
+			const expected = constructed.encode("caf\u00e9");
+			assert_equals(encoded.join(","), expected.join(","), "encoded bytes");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("factory throws on an unknown label", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			let constructorError, factoryError;
+			try {
+				new TextEncoder("not-an-encoding");
+			} catch (e) {
+				constructorError = e;
+			}
+			try {
+				newEncoder("not-an-encoding");
+			} catch (e) {
+				factoryError = e;
+			}
+
+			assert_true(factoryError instanceof RangeError, "error should be a RangeError");
+			assert_equals(factoryError.message, constructorError.message, "error message");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func BenchmarkTextEncoderEncodeInto(b *testing.B) {
+	for _, size := range []int{1 << 10, 1 << 20} {
+		size := size
+
+		b.Run(fmt.Sprintf("%d bytes source into 16 bytes destination", size), func(b *testing.B) {
+			ts := newTestSetup(b)
+
+			require.NoError(b, ts.rt.Set("size", size))
+			encodeInto, err := ts.rt.RunString(`
+				const source = "\u00e9".repeat(size);
+				const encoder = new TextEncoder();
+				const destination = new Uint8Array(16);
+				(function () { return encoder.encodeInto(source, destination); });
+			`)
+			require.NoError(b, err)
+
+			fn, ok := goja.AssertFunction(encodeInto)
+			require.True(b, ok)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := fn(goja.Undefined()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTextEncoderEncodeShared(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		const encoder = new TextEncoder();
+
+		function assert_same_bytes(actual, expected, description) {
+			assert_equals(actual.length, expected.length, description + " length");
+			for (let i = 0; i < expected.length; i++) {
+				assert_equals(actual[i], expected[i], description + " byte " + i);
+			}
+		}
+
+		for (const text of ["", "a", "café 水 𝄞", "a".repeat(1024), "b"]) {
+			const shared = encoder.encodeShared(text);
+			assert_true(shared instanceof Uint8Array, "encodeShared should return a Uint8Array");
+			assert_same_bytes(shared, encoder.encode(text), JSON.stringify(text.slice(0, 16)));
+		}
+
+		// The returned view is overwritten by the next call
+		const first = encoder.encodeShared("abc");
+		const second = encoder.encodeShared("xyz");
+		assert_equals(first.buffer, second.buffer, "views should share the same buffer");
+		assert_equals(String.fromCharCode(first[0]), "x", "first view should be overwritten");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestTextEncoderEncodeToBase64(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		label    string
+		text     string
+		variant  Base64Variant
+		want     string
+		decodeAs *base64.Encoding
+	}{
+		{name: "empty", label: UTF8EncodingFormat, text: "", want: "", decodeAs: base64.StdEncoding},
+		{name: "ascii", label: UTF8EncodingFormat, text: "hello", want: "aGVsbG8=", decodeAs: base64.StdEncoding},
+		{
+			name:     "multi-byte",
+			label:    UTF8EncodingFormat,
+			text:     "caf\u00E9 \u6C34 \U0001D11E",
+			want:     "Y2Fmw6kg5rC0IPCdhJ4=",
+			decodeAs: base64.StdEncoding,
+		},
+		{
+			name:     "raw standard",
+			label:    UTF8EncodingFormat,
+			text:     "caf\u00E9 \u6C34 \U0001D11E",
+			variant:  Base64RawStd,
+			want:     "Y2Fmw6kg5rC0IPCdhJ4",
+			decodeAs: base64.RawStdEncoding,
+		},
+		{
+			name:     "url safe",
+			label:    UTF8EncodingFormat,
+			text:     "\u00FF\u00FE?",
+			variant:  Base64URL,
+			want:     "w7_Dvj8=",
+			decodeAs: base64.URLEncoding,
+		},
+		{
+			name:     "raw url safe",
+			label:    UTF8EncodingFormat,
+			text:     "\u00FF\u00FE?",
+			variant:  Base64RawURL,
+			want:     "w7_Dvj8",
+			decodeAs: base64.RawURLEncoding,
+		},
+		{
+			name:     "windows-1252",
+			label:    Windows1252EncodingFormat,
+			text:     "caf\u00E9 \u20AC",
+			want:     "Y2Fm6SCA",
+			decodeAs: base64.StdEncoding,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			te, err := NewTextEncoder(tc.label, textEncoderOptions{})
+			require.NoError(t, err)
+
+			got, err := te.EncodeToBase64(tc.text, tc.variant)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+
+			// Decoding the base64 string back yields the original text
+			encoded, err := tc.decodeAs.DecodeString(got)
+			require.NoError(t, err)
+
+			td, err := NewTextDecoder(nil, tc.label, textDecoderOptions{})
+			require.NoError(t, err)
+
+			decoded, err := td.Decode(encoded, decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.text, decoded)
+		})
+	}
+
+	t.Run("unsupported variant", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder(UTF8EncodingFormat, textEncoderOptions{})
+		require.NoError(t, err)
+
+		_, err = te.EncodeToBase64("a", "base32")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoder = new TextEncoder();
+
+			assert_equals(encoder.encodeToBase64("caf\u00e9 \u6c34 \u{1d11e}"), "Y2Fmw6kg5rC0IPCdhJ4=");
+			assert_equals(encoder.encodeToBase64("\u00ff\u00fe?", "url"), "w7_Dvj8=");
+			assert_equals(encoder.encodeToBase64("\u00ff\u00fe?", "rawurl"), "w7_Dvj8");
+
+			let error;
+			try {
+				new TextEncoder("utf-8", { strict: true }).encodeToBase64("\ud800");
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "lone surrogates should throw a TypeError in strict mode");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextEncoderEncodeAll(t *testing.T) {
+	t.Parallel()
+
+	t.Run("go", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder(UTF8EncodingFormat, textEncoderOptions{})
+		require.NoError(t, err)
+
+		// "a\U0001D11E" split between its surrogates, which the
+		// conversion to Go strings substitutes.
+		encoded, err := te.EncodeAll(
+			[]string{"a\uFFFD", "\uFFFDb"},
+			[][2]uint16{{0x61, 0xD834}, {0xDD1E, 0x62}},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a\U0001D11Eb"), encoded)
+
+		_, err = te.EncodeAll([]string{"a"}, nil)
+		assert.Error(t, err, "edges should be given for each chunk")
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoder = new TextEncoder();
+			const decoder = new TextDecoder();
+
+			const encoded = encoder.encodeAll(["a", "\u6c34", "\ud834", "\udd1e", "b"]);
+			assert_true(encoded instanceof Uint8Array, "encodeAll should return a Uint8Array");
+			assert_equals(decoder.decode(encoded), "a\u6c34\u{1d11e}b", "a surrogate pair split across elements should be reassembled");
+			assert_equals(encoded.length, 9);
+
+			assert_equals(decoder.decode(encoder.encodeAll(["\ud834", "", "\udd1e"])), "\u{1d11e}", "empty elements should not split a pair");
+			assert_equals(decoder.decode(encoder.encodeAll(["a\ud834", "b"])), "a\ufffdb", "a lone high surrogate should be substituted");
+			assert_equals(decoder.decode(encoder.encodeAll(["a\ud834"])), "a\ufffd", "a trailing high surrogate should be substituted");
+			assert_equals(encoder.encodeAll([]).length, 0, "no elements should encode to no bytes");
+			assert_equals(decoder.decode(encoder.encodeAll([1, "a"])), "1a", "elements should be coerced to strings");
+
+			const windows1252 = new TextEncoder("windows-1252");
+			assert_equals(windows1252.encodeAll(["caf", "\u00e9"]).join(), "99,97,102,233", "other encodings should encode the concatenation");
+
+			const strict = new TextEncoder("utf-8", { strict: true });
+			assert_equals(decoder.decode(strict.encodeAll(["\ud834", "\udd1e"])), "\u{1d11e}", "a split pair should not be lone in strict mode");
+
+			for (const [texts, message] of [
+				[["a\ud834", "b"], "a lone high surrogate should throw in strict mode"],
+				[["a", "\udd1eb"], "a lone low surrogate should throw in strict mode"],
+				[["\ud834", "\ud834"], "consecutive high surrogates should throw in strict mode"],
+				[["a", 1], "a non-string element should throw in strict mode"],
+			]) {
+				let error;
+				try {
+					strict.encodeAll(texts);
+				} catch (e) {
+					error = e;
+				}
+				assert_true(error instanceof TypeError, message);
+			}
+
+			let error;
+			try {
+				encoder.encodeAll(undefined);
+			} catch (e) {
+				error = e;
+			}
+			assert_true(error instanceof TypeError, "undefined should throw a TypeError");
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTextEncoderEncodeToHash(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		label     string
+		options   textEncoderOptions
+		text      string
+		algorithm HashAlgorithm
+	}{
+		{name: "empty", label: UTF8EncodingFormat, text: "", algorithm: HashSHA256},
+		{name: "utf-8", label: UTF8EncodingFormat, text: "caf\u00E9 \u6C34 \U0001D11E", algorithm: HashSHA256},
+		{name: "md5", label: UTF8EncodingFormat, text: "hello", algorithm: HashMD5},
+		{name: "sha1", label: UTF8EncodingFormat, text: "hello", algorithm: HashSHA1},
+		{name: "sha384", label: UTF8EncodingFormat, text: "hello", algorithm: HashSHA384},
+		{name: "sha512", label: UTF8EncodingFormat, text: "hello", algorithm: HashSHA512},
+		{name: "sha512_224", label: UTF8EncodingFormat, text: "hello", algorithm: HashSHA512_224},
+		{name: "sha512_256", label: UTF8EncodingFormat, text: "hello", algorithm: HashSHA512_256},
+		{
+			name:      "longer than the encoding buffer",
+			label:     UTF8EncodingFormat,
+			text:      strings.Repeat("a\u00E9\u6C34", 1000),
+			algorithm: HashSHA256,
+		},
+		{
+			name:      "iso-2022-jp longer than the encoding buffer",
+			label:     "iso-2022-jp",
+			text:      strings.Repeat("a\u3042", 500),
+			algorithm: HashSHA256,
+		},
+		{name: "utf-16le", label: UTF16LEEncodingFormat, text: "a\U0001D11E", algorithm: HashSHA256},
+		{
+			name:      "html unmappable policy",
+			label:     Windows1252EncodingFormat,
+			options:   textEncoderOptions{Unmappable: UnmappableHTML},
+			text:      "caf\u00E9 \u6C34",
+			algorithm: HashSHA256,
+		},
+		{
+			name:      "invalid sequences escaped",
+			label:     UTF8EncodingFormat,
+			options:   textEncoderOptions{EscapeInvalid: true},
+			text:      "a\xFFb",
+			algorithm: HashSHA256,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			te, err := NewTextEncoder(tc.label, tc.options)
+			require.NoError(t, err)
+
+			h, err := NewHash(tc.algorithm)
+			require.NoError(t, err)
+
+			written, err := te.EncodeToHash(tc.text, h)
+			require.NoError(t, err)
+
+			// The digest is the one of the bytes Encode returns
+			encoded, err := te.Encode(tc.text)
+			require.NoError(t, err)
+
+			want, err := NewHash(tc.algorithm)
+			require.NoError(t, err)
+
+			_, err = want.Write(encoded)
+			require.NoError(t, err)
+
+			assert.Equal(t, len(encoded), written)
+			assert.Equal(t, want.Sum(nil), h.Sum(nil))
+		})
+	}
+
+	t.Run("unmappable character", func(t *testing.T) {
+		t.Parallel()
+
+		te, err := NewTextEncoder(Windows1252EncodingFormat, textEncoderOptions{})
+		require.NoError(t, err)
+
+		h, err := NewHash(HashSHA256)
+		require.NoError(t, err)
+
+		_, err = te.EncodeToHash("\u6C34", h)
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewHash("md4")
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, TypeError, encodingErr.Name)
+	})
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			const encoder = new TextEncoder();
+
+			assert_equals(
+				encoder.encodeToHash("hello", "sha256"),
+				"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+				"the digest should be hexadecimal by default"
+			);
+			assert_equals(encoder.encodeToHash("hello", "md5", "hex"), "5d41402abc4b2a76b9719d911017c592");
+			assert_equals(encoder.encodeToHash("hello", "sha1", "base64"), "qvTGHdzF6KLavt4PO0gs2a6pQ00=");
+
+			const digest = encoder.encodeToHash("caf\u00e9", "sha256", "binary");
+			assert_true(digest instanceof ArrayBuffer, "binary digests should be ArrayBuffers");
+			assert_equals(digest.byteLength, 32);
+
+			assert_true(
+				new TextEncoder("windows-1252").encodeToHash("caf\u00e9", "sha256") !== encoder.encodeToHash("caf\u00e9", "sha256"),
+				"the digest should depend on the encoding"
+			);
+
+			for (const [args, message] of [
+				[["hello", "md4"], "an unsupported algorithm should throw a TypeError"],
+				[["hello", "sha256", "base32"], "an unsupported output encoding should throw a TypeError"],
+			]) {
+				let error;
+				try {
+					encoder.encodeToHash(...args);
+				} catch (e) {
+					error = e;
+				}
+				assert_true(error instanceof TypeError, message);
+			}
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func BenchmarkTextEncoderEncode(b *testing.B) {
+	for _, method := range []string{"encode", "encodeShared"} {
+		method := method
+
+		b.Run(method, func(b *testing.B) {
+			ts := newTestSetup(b)
+
+			encode, err := ts.rt.RunString(`
+				const encoder = new TextEncoder();
+				(function () { return encoder.` + method + `("café 水 𝄞"); });
+			`)
+			require.NoError(b, err)
+
+			fn, ok := goja.AssertFunction(encode)
+			require.True(b, ok)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := fn(goja.Undefined()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestByteLength(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		text      string
+		label     string
+		wantError ErrorName
+	}{
+		{name: "empty text", text: "", label: "utf-8"},
+		{name: "ascii", text: "hello", label: "utf-8"},
+		{name: "multi-byte utf-8", text: "a\u00E9\u6C34\U0001F600", label: "utf-8"},
+		{name: "windows-1252", text: "caf\u00E9", label: "windows-1252"},
+		{name: "iso-2022-jp escapes", text: "a\u3042\u6F22b", label: "iso-2022-jp"},
+		{name: "iso-2022-jp longer than the counting buffer", text: strings.Repeat("a\u3042", 500), label: "iso-2022-jp"},
+		{name: "utf-16le", text: "a\U0001F600", label: "utf-16le"},
+		{name: "unmappable character", text: "\u6C34", label: "windows-1252", wantError: TypeError},
+		{name: "unsupported label", text: "a", label: "bogus-label", wantError: RangeError},
+		{name: "encoding the encoder does not support", text: "a", label: "shift_jis", wantError: RangeError},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ByteLength(tc.text, tc.label)
+			if tc.wantError != "" {
+				assert.ErrorContains(t, err, tc.wantError)
+				return
+			}
+
+			require.NoError(t, err)
+
+			te, err := NewTextEncoder(tc.label, textEncoderOptions{})
+			require.NoError(t, err)
+
+			encoded, err := te.Encode(tc.text)
+			require.NoError(t, err)
+			assert.Equal(t, len(encoded), got)
+		})
+	}
+
+	t.Run("js", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+
+		_, err := ts.rt.RunString(`
+			assert_equals(byteLength("hello", "utf-8"), 5, "ascii");
+			assert_equals(byteLength("\u6C34", "utf-8"), 3, "multi-byte utf-8");
+			assert_equals(byteLength("\ud800", "utf-8"), 3, "lone surrogates encode to replacement characters");
+			assert_equals(byteLength("a\u3042", "iso-2022-jp"), new TextEncoder("iso-2022-jp").encode("a\u3042").length, "iso-2022-jp");
+		`)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,117 @@
+package encoding
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// MalformedInput describes the first malformed sequence of decoded data.
+type MalformedInput struct {
+	// Offset holds the offset, in bytes, of the malformed
+	// sequence from the start of the data.
+	Offset int `js:"offset"`
+
+	// Byte holds the first byte of the malformed sequence.
+	Byte byte `js:"byte"`
+
+	// Reason holds a human-readable description of the issue.
+	Reason string `js:"reason"`
+}
+
+// TryDecode decodes the given data using the encoding the given label resolves
+// to, and returns the decoded text, along with a description of the first
+// malformed sequence of the data, if any.
+//
+// Unlike decoding in fatal mode, malformed data is not an error, which spares
+// validation loops the cost of handling one. The returned text holds replacement
+// characters in place of the malformed sequences, as in non-fatal mode.
+func TryDecode(data []byte, label string, options tryDecodeOptions) (string, *MalformedInput, error) {
+	td, err := NewTextDecoder(nil, label, textDecoderOptions{IgnoreBOM: options.IgnoreBOM})
+	if err != nil {
+		return "", nil, err
+	}
+
+	decoded, err := td.Decode(data, decodeOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Only look for the malformed sequence when there is one
+	if td.Substitutions() == 0 {
+		return decoded, nil, nil
+	}
+
+	return decoded, td.findMalformedInput(data), nil
+}
+
+type tryDecodeOptions struct {
+	// IgnoreBOM holds a boolean value indicating
+	// whether the byte order mark is ignored.
+	IgnoreBOM bool `js:"ignoreBOM"`
+}
+
+// findMalformedInput returns a description of the first malformed sequence
+// of the given data, or nil if the data is well-formed.
+//
+// The data is fed to a fresh transformer one byte at a time, so that the first
+// call substituting a replacement character tells where the malformed sequence
+// starts: at the first of the bytes the transformer had not consumed yet.
+func (td *TextDecoder) findMalformedInput(data []byte) *MalformedInput {
+	start := 0
+	if bom := byteOrderMark(td.Encoding); !td.IgnoreBOM && bytes.HasPrefix(data, bom) {
+		start = len(bom)
+	}
+
+	if td.Encoding == ReplacementEncodingFormat && start < len(data) {
+		return &MalformedInput{Offset: start, Byte: data[start], Reason: "the encoding is not decodable"}
+	}
+
+	t := td.decoder.NewDecoder()
+
+	var dest []byte
+	consumed := start
+	for end := start + 1; end <= len(data); end++ {
+		decoded, n, err := transformBytes(t, dest, data[consumed:end], false)
+		if err != nil {
+			return &MalformedInput{Offset: consumed, Byte: data[consumed], Reason: err.Error()}
+		}
+
+		if bytes.Count(decoded, []byte(string(utf8.RuneError))) > td.countReplacementCharacters(data[consumed:consumed+n]) {
+			return &MalformedInput{Offset: consumed, Byte: data[consumed], Reason: td.malformedReason(data[consumed:])}
+		}
+
+		dest = decoded
+		consumed += n
+	}
+
+	// Bytes left unconsumed once all the data is fed are a truncated sequence,
+	// unless the transformer was merely waiting for more of them to tell
+	// a utf-8 sequence it had started is malformed.
+	if consumed < len(data) {
+		reason := "truncated sequence"
+		if rest := data[consumed:]; td.Encoding == UTF8EncodingFormat && utf8.FullRune(rest) {
+			reason = td.malformedReason(rest)
+		}
+
+		return &MalformedInput{Offset: consumed, Byte: data[consumed], Reason: reason}
+	}
+
+	return nil
+}
+
+// malformedReason returns a human-readable description of the
+// malformed sequence the given data starts with.
+func (td *TextDecoder) malformedReason(data []byte) string {
+	if td.Encoding != UTF8EncodingFormat {
+		return "invalid sequence"
+	}
+
+	switch c := data[0]; {
+	case c >= 0x80 && c <= 0xBF:
+		return "unexpected continuation byte"
+	case c == 0xC0 || c == 0xC1 || c >= 0xF5:
+		return "invalid lead byte"
+	default:
+		return "invalid continuation byte"
+	}
+}
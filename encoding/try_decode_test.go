@@ -0,0 +1,135 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryDecode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		data          []byte
+		label         string
+		options       tryDecodeOptions
+		wantDecoded   string
+		wantMalformed *MalformedInput
+	}{
+		{
+			name:        "well-formed utf-8",
+			data:        []byte{0x61, 0xE6, 0xB0, 0xB4, 0xEF, 0xBF, 0xBD},
+			label:       UTF8EncodingFormat,
+			wantDecoded: "a水�",
+		},
+		{
+			name:          "invalid utf-8 byte",
+			data:          []byte{0x61, 0x62, 0xFF, 0x63},
+			label:         UTF8EncodingFormat,
+			wantDecoded:   "ab�c",
+			wantMalformed: &MalformedInput{Offset: 2, Byte: 0xFF, Reason: "invalid lead byte"},
+		},
+		{
+			name:          "unexpected utf-8 continuation byte",
+			data:          []byte{0x61, 0xE6, 0xB0, 0xB4, 0x80},
+			label:         UTF8EncodingFormat,
+			wantDecoded:   "a水�",
+			wantMalformed: &MalformedInput{Offset: 4, Byte: 0x80, Reason: "unexpected continuation byte"},
+		},
+		{
+			name:          "invalid utf-8 continuation byte",
+			data:          []byte{0x61, 0xE6, 0x62},
+			label:         UTF8EncodingFormat,
+			wantDecoded:   "a�b",
+			wantMalformed: &MalformedInput{Offset: 1, Byte: 0xE6, Reason: "invalid continuation byte"},
+		},
+		{
+			name:          "truncated utf-8 sequence",
+			data:          []byte{0x61, 0xE6, 0xB0},
+			label:         UTF8EncodingFormat,
+			wantDecoded:   "a�",
+			wantMalformed: &MalformedInput{Offset: 1, Byte: 0xE6, Reason: "truncated sequence"},
+		},
+		{
+			name:          "offset includes the byte order mark",
+			data:          []byte{0xEF, 0xBB, 0xBF, 0x61, 0xFF},
+			label:         UTF8EncodingFormat,
+			wantDecoded:   "a�",
+			wantMalformed: &MalformedInput{Offset: 4, Byte: 0xFF, Reason: "invalid lead byte"},
+		},
+		{
+			name:          "utf-16le unpaired surrogate",
+			data:          []byte{0x61, 0x00, 0x00, 0xDC, 0x62, 0x00},
+			label:         UTF16LEEncodingFormat,
+			wantDecoded:   "a�b",
+			wantMalformed: &MalformedInput{Offset: 2, Byte: 0x00, Reason: "invalid sequence"},
+		},
+		{
+			name:          "utf-16le odd byte",
+			data:          []byte{0xFD, 0xFF, 0x61},
+			label:         UTF16LEEncodingFormat,
+			wantDecoded:   "��",
+			wantMalformed: &MalformedInput{Offset: 2, Byte: 0x61, Reason: "truncated sequence"},
+		},
+		{
+			name:          "shift_jis invalid trail byte",
+			data:          []byte{0x61, 0x81, 0x20},
+			label:         ShiftJISEncodingFormat,
+			wantDecoded:   "a� ",
+			wantMalformed: &MalformedInput{Offset: 1, Byte: 0x81, Reason: "invalid sequence"},
+		},
+		{
+			name:          "replacement encoding",
+			data:          []byte{0x61},
+			label:         "iso-2022-kr",
+			wantDecoded:   "�",
+			wantMalformed: &MalformedInput{Offset: 0, Byte: 0x61, Reason: "the encoding is not decodable"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoded, malformed, err := TryDecode(tc.data, tc.label, tc.options)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantDecoded, decoded)
+			assert.Equal(t, tc.wantMalformed, malformed)
+		})
+	}
+
+	t.Run("unknown label", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := TryDecode([]byte{0x61}, "not-an-encoding", tryDecodeOptions{})
+
+		var encodingErr *Error
+		require.ErrorAs(t, err, &encodingErr)
+		assert.Equal(t, RangeError, encodingErr.Name)
+	})
+}
+
+func TestTryDecodeJS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		let result = tryDecode(new Uint8Array([0x61, 0xe6, 0xb0, 0xb4]), "utf-8");
+		assert_true(result.ok, "well-formed data should be ok");
+		assert_equals(result.value, "a水");
+		assert_equals(result.error, null);
+
+		result = tryDecode(new Uint8Array([0x61, 0x62, 0xff]).buffer, "utf-8", {});
+		assert_false(result.ok, "malformed data should not be ok");
+		assert_equals(result.value, "ab�");
+		assert_equals(result.error.offset, 2);
+		assert_equals(result.error.byte, 0xff);
+		assert_equals(result.error.reason, "invalid lead byte");
+	`)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,31 @@
+package encoding
+
+import "unicode/utf16"
+
+// separateIncompleteUTF16Sequences splits the given UTF-16 encoded buffer in two
+// parts: the leading part, made of complete code units, and the trailing incomplete
+// part, if any, made of the bytes which cannot be decoded without more input.
+//
+// The trailing part holds the odd trailing byte, if any, preceded by the last
+// complete code unit when it is a leading surrogate, which is necessarily unpaired.
+func separateIncompleteUTF16Sequences(buffer []byte, bigEndian bool) (complete, incomplete []byte) {
+	end := len(buffer) - len(buffer)%2
+
+	if end >= 2 {
+		unit := rune(buffer[end-2]) | rune(buffer[end-1])<<8
+		if bigEndian {
+			unit = rune(buffer[end-2])<<8 | rune(buffer[end-1])
+		}
+
+		// A leading surrogate lies in the first half of the surrogates range
+		if utf16.IsSurrogate(unit) && unit < 0xDC00 {
+			end -= 2
+		}
+	}
+
+	if end == len(buffer) {
+		return buffer, nil
+	}
+
+	return buffer[:end], buffer[end:]
+}
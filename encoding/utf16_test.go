@@ -0,0 +1,75 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeparateIncompleteUTF16Sequences(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		buffer         []byte
+		bigEndian      bool
+		wantComplete   []byte
+		wantIncomplete []byte
+	}{
+		{
+			name:           "complete code units",
+			buffer:         []byte{0x61, 0x00, 0x62, 0x00},
+			wantComplete:   []byte{0x61, 0x00, 0x62, 0x00},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "complete surrogate pair",
+			buffer:         []byte{0x34, 0xD8, 0x1E, 0xDD},
+			wantComplete:   []byte{0x34, 0xD8, 0x1E, 0xDD},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "odd trailing byte",
+			buffer:         []byte{0x61, 0x00, 0x62},
+			wantComplete:   []byte{0x61, 0x00},
+			wantIncomplete: []byte{0x62},
+		},
+		{
+			name:           "trailing leading surrogate",
+			buffer:         []byte{0x61, 0x00, 0x34, 0xD8},
+			wantComplete:   []byte{0x61, 0x00},
+			wantIncomplete: []byte{0x34, 0xD8},
+		},
+		{
+			name:           "trailing leading surrogate and odd byte",
+			buffer:         []byte{0x61, 0x00, 0x34, 0xD8, 0x1E},
+			wantComplete:   []byte{0x61, 0x00},
+			wantIncomplete: []byte{0x34, 0xD8, 0x1E},
+		},
+		{
+			name:           "trailing trailing surrogate",
+			buffer:         []byte{0x61, 0x00, 0x1E, 0xDD},
+			wantComplete:   []byte{0x61, 0x00, 0x1E, 0xDD},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "big endian trailing leading surrogate and odd byte",
+			buffer:         []byte{0x00, 0x61, 0xD8, 0x34, 0xDD},
+			bigEndian:      true,
+			wantComplete:   []byte{0x00, 0x61},
+			wantIncomplete: []byte{0xD8, 0x34, 0xDD},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotComplete, gotIncomplete := separateIncompleteUTF16Sequences(tc.buffer, tc.bigEndian)
+			assert.Equal(t, tc.wantComplete, gotComplete)
+			assert.Equal(t, tc.wantIncomplete, gotIncomplete)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package encoding
+
+import "unicode/utf8"
+
+// IsValidUTF8 returns true if the given data consists entirely of valid
+// UTF-8 encoded code points, that is, if decoding it in fatal mode would
+// succeed, without decoding it.
+//
+// Overlong encodings, surrogate code points, stray continuation bytes and
+// truncated trailing sequences all make the data invalid.
+func IsValidUTF8(data []byte) bool {
+	return utf8.Valid(data)
+}
+
+// ScanUTF8 scans the given buffer as a chunk of a UTF-8 stream, as a streaming
+// decoder would, so that custom streaming logic can be built upon it.
+//
+// It splits the buffer in two parts: the complete part, which can be decoded
+// right away, and the trailing incomplete sequence, if any, which could still
+// be completed by the bytes of the next chunk, and thus needs to be held back.
+// It also returns the ill-formed sequences the complete part holds, in order,
+// each of which a decoder substitutes with a single replacement character.
+//
+// Ill-formed sequences are reported as the maximal subparts the WHATWG
+// Encoding Standard substitutes: a lead byte followed by the continuation
+// bytes it allows, up to the first byte it does not, or a single byte that
+// cannot start a sequence. The returned slices share their memory with the
+// given buffer, and the incomplete part is nil when the buffer ends on a
+// sequence boundary.
+func ScanUTF8(buffer []byte) (complete, incomplete []byte, invalid [][]byte) {
+	complete, incomplete = separateIncompleteUTF8Sequences(buffer)
+
+	for i := 0; i < len(complete); {
+		r, size := utf8.DecodeRune(complete[i:])
+		if r != utf8.RuneError || size > 1 {
+			i += size
+			continue
+		}
+
+		// Extend the ill-formed sequence for as long as it is
+		// the prefix of a sequence some bytes could complete.
+		n := 1
+		for i+n < len(complete) && canCompleteUTF8Sequence(complete[i:i+n+1]) {
+			n++
+		}
+
+		invalid = append(invalid, complete[i:i+n])
+		i += n
+	}
+
+	return complete, incomplete, invalid
+}
+
+// separateIncompleteUTF8Sequences splits the given buffer in two parts: the
+// leading part, which can be decoded right away, and the trailing incomplete
+// UTF-8 sequence, if any, which needs more bytes to be decoded.
+//
+// Only a trailing sequence that is a valid prefix of a UTF-8 encoded code point
+// is considered incomplete. Continuation bytes that are not preceded by a lead byte
+// within reach (orphan continuation bytes) are invalid, rather than incomplete, and
+// are thus left in the leading part for the decoder to substitute.
+func separateIncompleteUTF8Sequences(buffer []byte) (complete, incomplete []byte) {
+	// A sequence is at most utf8.UTFMax bytes long, hence an incomplete one can only
+	// start within the last utf8.UTFMax-1 bytes of the buffer.
+	for i := len(buffer) - 1; i >= 0 && i > len(buffer)-utf8.UTFMax; i-- {
+		c := buffer[i]
+
+		// An ASCII byte terminates any preceding sequence.
+		if c < utf8.RuneSelf {
+			break
+		}
+
+		// A continuation byte, keep looking for the lead byte.
+		if c < 0xC0 {
+			continue
+		}
+
+		// A lead byte, the trailing sequence is incomplete only if
+		// it could still be completed by the upcoming bytes.
+		if canCompleteUTF8Sequence(buffer[i:]) {
+			return buffer[:i], buffer[i:]
+		}
+
+		break
+	}
+
+	return buffer, nil
+}
+
+// canCompleteUTF8Sequence returns true if the given sequence is a valid, yet
+// incomplete, prefix of a UTF-8 encoded code point. That is, if it starts
+// with a lead byte, and is followed by fewer continuation bytes than the lead
+// byte announces, all of which are within the ranges allowed by the lead byte.
+//
+// This is precisely the non-empty sequences [utf8.FullRune] reports as not
+// holding a full rune, as it considers invalid sequences full ones, and it
+// checks them with lookups in the tables of lead byte sizes and ranges of
+// the second byte, rather than with branches.
+func canCompleteUTF8Sequence(sequence []byte) bool {
+	return len(sequence) > 0 && !utf8.FullRune(sequence)
+}
@@ -0,0 +1,329 @@
+package encoding
+
+import (
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeparateIncompleteUTF8Sequences(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		buffer         []byte
+		wantComplete   []byte
+		wantIncomplete []byte
+	}{
+		{
+			name:           "empty buffer",
+			buffer:         []byte{},
+			wantComplete:   []byte{},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "ascii only",
+			buffer:         []byte("abc"),
+			wantComplete:   []byte("abc"),
+			wantIncomplete: nil,
+		},
+		{
+			name:           "complete multi-byte sequence",
+			buffer:         []byte{0x61, 0xE6, 0xB0, 0xB4},
+			wantComplete:   []byte{0x61, 0xE6, 0xB0, 0xB4},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "trailing lead byte",
+			buffer:         []byte{0x61, 0xE6},
+			wantComplete:   []byte{0x61},
+			wantIncomplete: []byte{0xE6},
+		},
+		{
+			name:           "trailing incomplete three bytes sequence",
+			buffer:         []byte{0x61, 0xE6, 0xB0},
+			wantComplete:   []byte{0x61},
+			wantIncomplete: []byte{0xE6, 0xB0},
+		},
+		{
+			name:           "trailing incomplete four bytes sequence",
+			buffer:         []byte{0x61, 0xF0, 0x9D, 0x84},
+			wantComplete:   []byte{0x61},
+			wantIncomplete: []byte{0xF0, 0x9D, 0x84},
+		},
+		{
+			name:           "one orphan continuation byte",
+			buffer:         []byte{0x61, 0x80},
+			wantComplete:   []byte{0x61, 0x80},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "two orphan continuation bytes",
+			buffer:         []byte{0x61, 0x80, 0xBF},
+			wantComplete:   []byte{0x61, 0x80, 0xBF},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "three orphan continuation bytes",
+			buffer:         []byte{0x61, 0x80, 0x80, 0x80},
+			wantComplete:   []byte{0x61, 0x80, 0x80, 0x80},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "continuation bytes following a complete sequence",
+			buffer:         []byte{0xC2, 0xA2, 0x80, 0x80},
+			wantComplete:   []byte{0xC2, 0xA2, 0x80, 0x80},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "too many continuation bytes for the lead byte",
+			buffer:         []byte{0xC2, 0x80, 0x80},
+			wantComplete:   []byte{0xC2, 0x80, 0x80},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "lead byte with out of range second byte",
+			buffer:         []byte{0x61, 0xE0, 0x80},
+			wantComplete:   []byte{0x61, 0xE0, 0x80},
+			wantIncomplete: nil,
+		},
+		{
+			name:           "invalid lead byte",
+			buffer:         []byte{0x61, 0xF5, 0x80},
+			wantComplete:   []byte{0x61, 0xF5, 0x80},
+			wantIncomplete: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotComplete, gotIncomplete := separateIncompleteUTF8Sequences(tc.buffer)
+			assert.Equal(t, tc.wantComplete, gotComplete)
+			assert.Equal(t, tc.wantIncomplete, gotIncomplete)
+		})
+	}
+}
+
+func TestScanUTF8(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		buffer         []byte
+		wantComplete   []byte
+		wantIncomplete []byte
+		wantInvalid    [][]byte
+	}{
+		{
+			name:         "empty",
+			buffer:       []byte{},
+			wantComplete: []byte{},
+		},
+		{
+			name:         "valid",
+			buffer:       []byte{0x61, 0xE6, 0xB0, 0xB4, 0xF0, 0x9D, 0x84, 0x9E},
+			wantComplete: []byte{0x61, 0xE6, 0xB0, 0xB4, 0xF0, 0x9D, 0x84, 0x9E},
+		},
+		{
+			name:         "encoded replacement character",
+			buffer:       []byte{0xEF, 0xBF, 0xBD},
+			wantComplete: []byte{0xEF, 0xBF, 0xBD},
+		},
+		{
+			name:           "trailing incomplete sequence",
+			buffer:         []byte{0x61, 0xE6, 0xB0},
+			wantComplete:   []byte{0x61},
+			wantIncomplete: []byte{0xE6, 0xB0},
+		},
+		{
+			name:         "truncated sequence followed by ascii",
+			buffer:       []byte{0xE6, 0xB0, 0x61},
+			wantComplete: []byte{0xE6, 0xB0, 0x61},
+			wantInvalid:  [][]byte{{0xE6, 0xB0}},
+		},
+		{
+			name:         "orphan continuation bytes",
+			buffer:       []byte{0x61, 0x80, 0x81},
+			wantComplete: []byte{0x61, 0x80, 0x81},
+			wantInvalid:  [][]byte{{0x80}, {0x81}},
+		},
+		{
+			name:         "overlong sequence",
+			buffer:       []byte{0xC0, 0xAF},
+			wantComplete: []byte{0xC0, 0xAF},
+			wantInvalid:  [][]byte{{0xC0}, {0xAF}},
+		},
+		{
+			name:         "surrogate code point",
+			buffer:       []byte{0xED, 0xA0, 0x80},
+			wantComplete: []byte{0xED, 0xA0, 0x80},
+			wantInvalid:  [][]byte{{0xED}, {0xA0}, {0x80}},
+		},
+		{
+			name:           "invalid sequences and trailing incomplete sequence",
+			buffer:         []byte{0xFF, 0x61, 0xF0, 0x9D, 0x84, 0x62, 0xF0, 0x9D},
+			wantComplete:   []byte{0xFF, 0x61, 0xF0, 0x9D, 0x84, 0x62},
+			wantIncomplete: []byte{0xF0, 0x9D},
+			wantInvalid:    [][]byte{{0xFF}, {0xF0, 0x9D, 0x84}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			complete, incomplete, invalid := ScanUTF8(tc.buffer)
+			assert.Equal(t, tc.wantComplete, complete)
+			assert.Equal(t, tc.wantIncomplete, incomplete)
+			assert.Equal(t, tc.wantInvalid, invalid)
+		})
+	}
+
+	t.Run("one replacement character per invalid sequence", func(t *testing.T) {
+		t.Parallel()
+
+		// Every combination of three bytes among the lead, continuation and
+		// invalid bytes, surrounded by ascii, exercises the boundaries.
+		alphabet := []byte{0x61, 0x80, 0x9F, 0xA0, 0xBF, 0xC2, 0xE0, 0xE6, 0xED, 0xF0, 0xF4, 0xF5, 0xFF}
+
+		for _, a := range alphabet {
+			for _, b := range alphabet {
+				for _, c := range alphabet {
+					buffer := []byte{0x78, a, b, c, 0x78}
+
+					complete, incomplete, invalid := ScanUTF8(buffer)
+					assert.Empty(t, incomplete, "a buffer ending with ascii holds no incomplete sequence")
+					assert.Equal(t, buffer, complete)
+
+					td, err := NewTextDecoder(nil, UTF8EncodingFormat, textDecoderOptions{})
+					require.NoError(t, err)
+
+					_, err = td.Decode(buffer, decodeOptions{})
+					require.NoError(t, err)
+					assert.Equal(t, td.Substitutions(), len(invalid), "buffer % X", buffer)
+				}
+			}
+		}
+	})
+}
+
+func TestIsValidUTF8(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "empty", data: []byte{}, want: true},
+		{name: "ascii", data: []byte("abc"), want: true},
+		{name: "multi-byte sequences", data: []byte{0x61, 0xC2, 0xA2, 0xE6, 0xB0, 0xB4, 0xF0, 0x9D, 0x84, 0x9E}, want: true},
+		{name: "overlong sequence", data: []byte{0xC0, 0xAF}, want: false},
+		{name: "overlong three bytes sequence", data: []byte{0xE0, 0x80, 0xAF}, want: false},
+		{name: "surrogate code point", data: []byte{0xED, 0xA0, 0x80}, want: false},
+		{name: "lone continuation byte", data: []byte{0x61, 0x80, 0x62}, want: false},
+		{name: "truncated trailing sequence", data: []byte{0x61, 0xE6, 0xB0}, want: false},
+		{name: "invalid byte", data: []byte{0x61, 0xFF}, want: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, IsValidUTF8(tc.data))
+		})
+	}
+}
+
+func TestIsValidUTF8JS(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+
+	_, err := ts.rt.RunString(`
+		assert_true(isValidUTF8(new Uint8Array([0x61, 0xe6, 0xb0, 0xb4])), "valid utf-8");
+		assert_true(isValidUTF8(new Uint8Array([0x61, 0xc2, 0xa2]).buffer), "valid utf-8 ArrayBuffer");
+		assert_false(isValidUTF8(new Uint8Array([0xc0, 0xaf])), "overlong sequence");
+		assert_false(isValidUTF8(new Uint8Array([0x80])), "lone continuation byte");
+		assert_false(isValidUTF8(new DataView(new Uint8Array([0x61, 0xe6, 0xb0]).buffer)), "truncated sequence");
+	`)
+	assert.NoError(t, err)
+}
+
+func TestCanCompleteUTF8Sequence(t *testing.T) {
+	t.Parallel()
+
+	// The sequences which can be completed are the strict
+	// prefixes of the encodings of the Unicode scalar values.
+	prefixes := make(map[string]bool)
+	for r := rune(0); r <= unicode.MaxRune; r++ {
+		if !utf8.ValidRune(r) {
+			continue
+		}
+
+		encoded := utf8.AppendRune(nil, r)
+		for i := 1; i < len(encoded); i++ {
+			prefixes[string(encoded[:i])] = true
+		}
+	}
+
+	for b0 := 0; b0 <= 0xFF; b0++ {
+		sequence := []byte{byte(b0)}
+		assert.Equal(t, prefixes[string(sequence)], canCompleteUTF8Sequence(sequence), "sequence % X", sequence)
+
+		for b1 := 0; b1 <= 0xFF; b1++ {
+			sequence := []byte{byte(b0), byte(b1)}
+			assert.Equal(t, prefixes[string(sequence)], canCompleteUTF8Sequence(sequence), "sequence % X", sequence)
+
+			// Third bytes only matter following a lead byte,
+			// and a second byte around the continuation range.
+			if b0 < 0xC0 || b1 < 0x70 || b1 > 0xCF {
+				continue
+			}
+
+			for b2 := 0; b2 <= 0xFF; b2++ {
+				sequence := []byte{byte(b0), byte(b1), byte(b2)}
+				if prefixes[string(sequence)] != canCompleteUTF8Sequence(sequence) {
+					t.Errorf("sequence % X: want %t", sequence, prefixes[string(sequence)])
+				}
+			}
+		}
+	}
+
+	assert.False(t, canCompleteUTF8Sequence(nil), "empty sequence")
+	assert.False(t, canCompleteUTF8Sequence([]byte{0xF0, 0x9F, 0x98, 0x80}), "complete sequence")
+}
+
+func BenchmarkSeparateIncompleteUTF8Sequences(b *testing.B) {
+	// Small chunks ending with each kind of boundary: complete, split
+	// after the lead byte, split mid-sequence, and orphan continuation
+	// bytes.
+	chunks := [][]byte{
+		{0x61, 0x62, 0x63, 0xC2, 0xA2},
+		{0x61, 0x62, 0x63, 0xE6},
+		{0x61, 0x62, 0xE6, 0xB0},
+		{0x61, 0xF0, 0x9F, 0x98},
+		{0x61, 0x62, 0x80, 0x80},
+		{0x61, 0x62, 0x63, 0xF5},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, chunk := range chunks {
+			separateIncompleteUTF8Sequences(chunk)
+		}
+	}
+}
@@ -4,6 +4,7 @@ go 1.19
 
 require (
 	github.com/dop251/goja v0.0.0-20230427124612-428fc442ff5f
+	github.com/rivo/uniseg v0.4.7
 	github.com/stretchr/testify v1.8.2
 	go.k6.io/k6 v0.44.1
 	golang.org/x/text v0.8.0